@@ -0,0 +1,490 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states of the agent
+const (
+	jobQueued      = "queued"
+	jobRunning     = "running"
+	jobPassed      = "passed"
+	jobFailed      = "failed"
+	jobCancelled   = "cancelled"
+	jobInterrupted = "interrupted" // the agent stopped while it ran
+)
+
+// AgentJob -- a benchmark submitted to the agent, kept as job.json in its
+// directory
+type AgentJob struct {
+	ID       int
+	Args     []string
+	State    string
+	ExitCode *int `json:",omitempty"`
+	Queued   time.Time
+	Started  *time.Time `json:",omitempty"`
+	Finished *time.Time `json:",omitempty"`
+}
+
+// agent -- the job queue of "hsbench agent". Jobs run one at a time, in the
+// order they were submitted, each in a directory of its own.
+type agent struct {
+	dir   string
+	token string
+	exe   string
+
+	mu      sync.Mutex
+	jobs    map[int]*AgentJob
+	queue   []int
+	nextID  int
+	running *exec.Cmd
+	wake    chan struct{}
+}
+
+// agentRefusedFlags -- options a job may not use, with why: they run
+// commands or load code on the agent's host, or serve an API without the
+// agent's token
+var agentRefusedFlags = map[string]string{
+	"pre-phase-cmd":  "it runs commands on the agent's host",
+	"post-phase-cmd": "it runs commands on the agent's host",
+	"cred-command":   "it runs commands on the agent's host",
+	"shard-command":  "it runs commands on the agent's host",
+	"plugin":         "it loads code on the agent's host",
+	"control":        "it serves the run's control API without the agent's token",
+}
+
+// agentJobEnv -- the environment variables a job gets from the agent, so
+// its HSBENCH_ settings and credentials do not reach the jobs
+var agentJobEnv = []string{"PATH", "HOME", "TMPDIR", "TZ", "LANG", "LC_ALL", "SSL_CERT_FILE", "SSL_CERT_DIR"}
+
+// agentPathFlags -- options naming files, with the prefix that marks a path
+// in their value: "" when the value is a path, "kind:" for a -sink target
+var agentPathFlags = map[string]string{
+	"o": "", "j": "", "report": "", "md": "", "heatmap": "", "summary": "",
+	"manifest-out": "", "manifest-in": "", "history-db": "", "workers": "",
+	"placement": "", "bucket-file": "", "policy": "", "profile": "",
+	"cred-file": "", "source": "", "sink": "kind:", "script": "file:",
+	"dialer": "unix:", "a": "file:", "s": "file:",
+	"minio-admin-a": "file:", "minio-admin-s": "file:",
+	"rgw-admin-a": "file:", "rgw-admin-s": "file:",
+}
+
+// agentDevices -- the devices a job's -source may read
+var agentDevices = map[string]bool{"/dev/zero": true, "/dev/urandom": true, "/dev/random": true}
+
+// checkJobArgs -- refuse the options of a job reaching outside of its
+// directory: commands or plugins run on the agent's host, a -control
+// listener, and files named
+// by an absolute path or leaving the directory with ... Every argument
+// looking like an option is checked, wherever the flag package would stop
+// parsing, since the agent does not know which options take a value.
+func checkJobArgs(args []string) error {
+	for i, arg := range args {
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, inline := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if why, ok := agentRefusedFlags[name]; ok {
+			return fmt.Errorf("-%s is not allowed in agent jobs, %s", name, why)
+		}
+		prefix, ok := agentPathFlags[name]
+		if !ok {
+			continue
+		}
+		if !inline {
+			if i+1 == len(args) {
+				continue
+			}
+			value = args[i+1]
+		}
+		path := value
+		switch {
+		case prefix == "kind:":
+			_, path, _ = strings.Cut(value, ":")
+			// Pushgateway and influx write URLs are not files
+			if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+				continue
+			}
+		case prefix != "":
+			if !strings.HasPrefix(value, prefix) {
+				continue
+			}
+			path = strings.TrimPrefix(value, prefix)
+		}
+		if path == "" || name == "source" && agentDevices[path] {
+			continue
+		}
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("-%s %s is not a file in the job's directory", name, value)
+		}
+	}
+	return nil
+}
+
+// jobEnv -- the environment of a job, the agentJobEnv variables that are set
+func jobEnv() []string {
+	env := make([]string, 0, len(agentJobEnv))
+	for _, name := range agentJobEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// runAgent -- serve the job API until killed
+func runAgent(args []string) int {
+	var listen string
+	a := &agent{jobs: make(map[int]*AgentJob), nextID: 1, wake: make(chan struct{}, 1)}
+	aflag := flag.NewFlagSet("agent", flag.ContinueOnError)
+	aflag.StringVar(&listen, "listen", "127.0.0.1:7481", "Address to serve the job API on")
+	aflag.StringVar(&a.token, "token", "", "Bearer token every request must carry, better set through HSBENCH_AGENT_TOKEN")
+	aflag.StringVar(&a.dir, "dir", "hsbench-agent", "Directory keeping the jobs and their results")
+	aflag.Usage = func() {
+		fmt.Fprintf(aflag.Output(), "\nUSAGE: %s agent [OPTIONS]\n\n", os.Args[0])
+		fmt.Fprintf(aflag.Output(), "OPTIONS:\n")
+		aflag.PrintDefaults()
+		fmt.Fprintf(aflag.Output(), `
+NOTES:
+  - The agent runs the jobs submitted to it one at a time, each as
+    "hsbench <args>" in -dir/<id>, so relative -o and -j files are kept
+    there along with the output of the run and its -summary:
+
+        POST /jobs                {"Args": ["-u", "...", "-m", "cxipgdx"]}
+        GET  /jobs                all jobs
+        GET  /jobs/<id>           one job
+        GET  /jobs/<id>/log       output of the run
+        GET  /jobs/<id>/summary   -summary JSON of the run
+        GET  /jobs/<id>/files/<f> a file the run wrote
+        POST /jobs/<id>/cancel    drop a queued job or stop a running one
+
+    Requests carry "Authorization: Bearer <token>". Jobs still queued
+    when the agent stops run once it is started again.
+
+    Jobs may not use -pre-phase-cmd, -post-phase-cmd, -cred-command,
+    -shard-command, -plugin or -control, and the files they name, ie with
+    -o, -j or file:<path>, must be relative paths inside the job's
+    directory; -source may also read /dev/zero or /dev/urandom. Jobs still
+    choose the endpoint they send requests to. They only get the PATH,
+    HOME, TMPDIR, TZ, LANG, LC_ALL and SSL_CERT_* variables of the agent's
+    environment, so their keys are passed with -a and -s.
+`)
+	}
+	if err := aflag.Parse(args); err != nil {
+		return exitConfigError
+	}
+	if err := applyEnv(aflag, "HSBENCH_AGENT_"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	if a.token == "" {
+		fmt.Fprintf(os.Stderr, "Missing -token argument or HSBENCH_AGENT_TOKEN\n")
+		return exitConfigError
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		logFatal("Could not find the hsbench executable: ", err)
+	}
+	a.exe = exe
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		logFatal("Could not create -dir: ", err)
+	}
+	a.load()
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		logFatalf("Could not listen on -listen address %s: %v", listen, err)
+	}
+	go a.runJobs()
+	logInfof("Agent listening on %s, keeping jobs in %s", listener.Addr(), a.dir)
+	if err := http.Serve(listener, a.handler()); err != nil {
+		logFatal("Agent stopped: ", err)
+	}
+	return exitOK
+}
+
+// jobDir -- the directory of a job
+func (a *agent) jobDir(id int) string {
+	return filepath.Join(a.dir, strconv.Itoa(id))
+}
+
+// load -- pick up the jobs of an earlier agent, queueing again the ones it
+// did not start
+func (a *agent) load() {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		logFatal("Could not read -dir: ", err)
+	}
+	for _, e := range entries {
+		id, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(a.jobDir(id), "job.json"))
+		if err != nil {
+			continue
+		}
+		var job AgentJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			logWarnf("Skipping job %d: %v", id, err)
+			continue
+		}
+		if job.State == jobRunning {
+			job.State = jobInterrupted
+			a.save(&job)
+		}
+		if job.State == jobQueued {
+			a.queue = append(a.queue, id)
+		}
+		a.jobs[id] = &job
+		a.nextID = max(a.nextID, id+1)
+	}
+	sort.Ints(a.queue)
+	if len(a.jobs) > 0 {
+		logInfof("Loaded %d jobs, %d queued", len(a.jobs), len(a.queue))
+	}
+}
+
+// save -- write job.json of a job, called with mu held
+func (a *agent) save(job *AgentJob) {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(a.jobDir(job.ID), "job.json"), append(data, '\n'))
+	}
+	if err != nil {
+		logErrorf("Could not save job %d: %v", job.ID, err)
+	}
+}
+
+// submit -- queue a job
+func (a *agent) submit(args []string) (*AgentJob, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	job := &AgentJob{ID: a.nextID, Args: args, State: jobQueued, Queued: time.Now()}
+	if err := os.MkdirAll(a.jobDir(job.ID), 0755); err != nil {
+		return nil, err
+	}
+	a.nextID++
+	a.jobs[job.ID] = job
+	a.queue = append(a.queue, job.ID)
+	a.save(job)
+	logInfof("Queued job %d: %q", job.ID, args)
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// cancel -- drop a queued job or stop the running one
+func (a *agent) cancel(id int) (*AgentJob, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	job := a.jobs[id]
+	switch {
+	case job == nil:
+		return nil, nil
+	case job.State == jobQueued:
+		for i, q := range a.queue {
+			if q == id {
+				a.queue = append(a.queue[:i], a.queue[i+1:]...)
+				break
+			}
+		}
+		job.State = jobCancelled
+		a.save(job)
+	case job.State == jobRunning:
+		// runJobs records the state once the process is gone
+		job.State = jobCancelled
+		if a.running != nil {
+			a.running.Process.Kill()
+		}
+	default:
+		return job, fmt.Errorf("job %d already %s", id, job.State)
+	}
+	logInfof("Cancelled job %d", id)
+	return job, nil
+}
+
+// runJobs -- run the queued jobs one after the other
+func (a *agent) runJobs() {
+	for {
+		a.mu.Lock()
+		if len(a.queue) == 0 {
+			a.mu.Unlock()
+			<-a.wake
+			continue
+		}
+		job := a.jobs[a.queue[0]]
+		a.queue = a.queue[1:]
+		dir := a.jobDir(job.ID)
+		out, err := os.Create(filepath.Join(dir, "output.log"))
+		if err != nil {
+			logErrorf("Could not start job %d: %v", job.ID, err)
+			job.State = jobFailed
+			a.save(job)
+			a.mu.Unlock()
+			continue
+		}
+		args := append(append([]string{}, job.Args...), "-summary", "summary.json")
+		cmd := exec.Command(a.exe, args...)
+		cmd.Dir = dir
+		cmd.Env = jobEnv()
+		cmd.Stdout = out
+		cmd.Stderr = out
+		started := time.Now()
+		job.State = jobRunning
+		job.Started = &started
+		err = cmd.Start()
+		if err == nil {
+			a.running = cmd
+		}
+		a.save(job)
+		a.mu.Unlock()
+
+		logInfof("Running job %d", job.ID)
+		if err == nil {
+			err = cmd.Wait()
+		}
+		out.Close()
+
+		a.mu.Lock()
+		code := exitFatal
+		if err == nil {
+			code = exitOK
+		} else if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() >= 0 {
+			code = exit.ExitCode()
+		}
+		finished := time.Now()
+		job.Finished = &finished
+		if job.State != jobCancelled {
+			job.ExitCode = &code
+			job.State = jobPassed
+			if code != exitOK {
+				job.State = jobFailed
+			}
+		}
+		a.running = nil
+		a.save(job)
+		a.mu.Unlock()
+		logInfof("Job %d %s", job.ID, job.State)
+	}
+}
+
+// handler -- the job API, every request authenticated by the -token
+func (a *agent) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Args []string }
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "expected {\"Args\": [...]}: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Args) == 0 {
+			req.Args = []string{"run"}
+		}
+		// Only benchmarks, not other commands such as a second agent
+		_, bench := benchmarkCommands[req.Args[0]]
+		if !bench && !strings.HasPrefix(req.Args[0], "-") {
+			http.Error(w, "expected run, prefill, clean or hsbench options in Args", http.StatusBadRequest)
+			return
+		}
+		if err := checkJobArgs(req.Args); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job, err := a.submit(req.Args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		a.writeJob(w, http.StatusCreated, job)
+	})
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		jobs := make([]AgentJob, 0, len(a.jobs))
+		for _, job := range a.jobs {
+			jobs = append(jobs, *job)
+		}
+		a.mu.Unlock()
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs)
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if job := a.job(w, r); job != nil {
+			a.writeJob(w, http.StatusOK, job)
+		}
+	})
+	mux.HandleFunc("GET /jobs/{id}/log", func(w http.ResponseWriter, r *http.Request) {
+		if job := a.job(w, r); job != nil {
+			http.ServeFile(w, r, filepath.Join(a.jobDir(job.ID), "output.log"))
+		}
+	})
+	mux.HandleFunc("GET /jobs/{id}/summary", func(w http.ResponseWriter, r *http.Request) {
+		if job := a.job(w, r); job != nil {
+			http.ServeFile(w, r, filepath.Join(a.jobDir(job.ID), "summary.json"))
+		}
+	})
+	mux.HandleFunc("GET /jobs/{id}/files/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if job := a.job(w, r); job != nil {
+			// name is a single path element, so this stays in the job's directory
+			http.ServeFile(w, r, filepath.Join(a.jobDir(job.ID), filepath.Base(r.PathValue("name"))))
+		}
+	})
+	mux.HandleFunc("POST /jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.PathValue("id"))
+		job, err := a.cancel(id)
+		switch {
+		case job == nil:
+			http.NotFound(w, r)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			a.writeJob(w, http.StatusOK, job)
+		}
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "Bearer " + a.token
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// job -- the job named in the request path, answering 404 when there is none
+func (a *agent) job(w http.ResponseWriter, r *http.Request) *AgentJob {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	a.mu.Lock()
+	job := a.jobs[id]
+	a.mu.Unlock()
+	if err != nil || job == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	return job
+}
+
+func (a *agent) writeJob(w http.ResponseWriter, status int, job *AgentJob) {
+	a.mu.Lock()
+	data, _ := json.Marshal(job)
+	a.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestCheckJobArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		ok   bool
+	}{
+		{"plain run", []string{"-u", "http://s3:7480", "-m", "cxipgdx", "-o", "out.csv"}, true},
+		{"pre-phase command", []string{"-pre-phase-cmd", "sync"}, false},
+		{"post-phase command inline", []string{"--post-phase-cmd=sync"}, false},
+		{"credential command", []string{"-cred-command", "vault read"}, false},
+		{"shard command", []string{"-shard-command", "ls"}, false},
+		{"plugin", []string{"-plugin", "op.so"}, false},
+		{"control", []string{"-control", "0.0.0.0:7480"}, false},
+		{"control on loopback", []string{"-control=127.0.0.1:7480"}, false},
+		{"control after a positional argument", []string{"run", "-m", "p", "-control", ":7480"}, false},
+		{"absolute output", []string{"-o", "/etc/passwd"}, false},
+		{"output leaving the directory", []string{"-j=../x.json"}, false},
+		{"key file", []string{"-a", "file:/root/.aws/key"}, false},
+		{"local key file", []string{"-a", "file:key"}, true},
+		{"literal key", []string{"-a", "AKIA"}, true},
+		{"unix dialer", []string{"-dialer", "unix:/run/s3.sock"}, false},
+		{"tcp dialer", []string{"-dialer", "tcp:10.0.0.1"}, true},
+		{"source device", []string{"-source", "/dev/urandom"}, true},
+		{"source file", []string{"-source", "/etc/shadow"}, false},
+		{"sink url", []string{"-sink", "influx:http://db:8086/write"}, true},
+		{"sink file", []string{"-sink", "file:/tmp/x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkJobArgs(tt.args)
+			if tt.ok && err != nil {
+				t.Errorf("checkJobArgs(%q) = %v, want it allowed", tt.args, err)
+			} else if !tt.ok && err == nil {
+				t.Errorf("checkJobArgs(%q) allowed it, want an error", tt.args)
+			}
+		})
+	}
+}
+
+func TestJobEnv(t *testing.T) {
+	t.Setenv("HSBENCH_A", "secret")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("TZ", "UTC")
+	env := jobEnv()
+	found := false
+	for _, v := range env {
+		switch v {
+		case "HSBENCH_A=secret", "AWS_SECRET_ACCESS_KEY=secret":
+			t.Errorf("job environment has %s", v)
+		case "TZ=UTC":
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("job environment %q lacks TZ", env)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// statField -- accessors for one numeric column of OutputStats, and whether
+// it is a throughput or latency the run-to-run spread is reported for
+type statField struct {
+	get    func(o *OutputStats) float64
+	set    func(o *OutputStats, v float64)
+	spread bool
+}
+
+var statFields = []statField{
+	{func(o *OutputStats) float64 { return o.Seconds }, func(o *OutputStats, v float64) { o.Seconds = v }, false},
+	{func(o *OutputStats) float64 { return float64(o.Ops) }, func(o *OutputStats, v float64) { o.Ops = int(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return o.Mbps }, func(o *OutputStats, v float64) { o.Mbps = v }, true},
+	{func(o *OutputStats) float64 { return o.Iops }, func(o *OutputStats, v float64) { o.Iops = v }, true},
+	{func(o *OutputStats) float64 { return o.MinLat }, func(o *OutputStats, v float64) { o.MinLat = v }, true},
+	{func(o *OutputStats) float64 { return o.AvgLat }, func(o *OutputStats, v float64) { o.AvgLat = v }, true},
+	{func(o *OutputStats) float64 { return o.Lat99 }, func(o *OutputStats, v float64) { o.Lat99 = v }, true},
+	{func(o *OutputStats) float64 { return o.Lat95 }, func(o *OutputStats, v float64) { o.Lat95 = v }, true},
+	{func(o *OutputStats) float64 { return o.Lat90 }, func(o *OutputStats, v float64) { o.Lat90 = v }, true},
+	{func(o *OutputStats) float64 { return o.Lat75 }, func(o *OutputStats, v float64) { o.Lat75 = v }, true},
+	{func(o *OutputStats) float64 { return o.Lat50 }, func(o *OutputStats, v float64) { o.Lat50 = v }, true},
+	{func(o *OutputStats) float64 { return o.MaxLat }, func(o *OutputStats, v float64) { o.MaxLat = v }, true},
+	{func(o *OutputStats) float64 { return float64(o.Slowdowns) }, func(o *OutputStats, v float64) { o.Slowdowns = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return float64(o.NotModified) }, func(o *OutputStats, v float64) { o.NotModified = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return float64(o.ScannedBytes) }, func(o *OutputStats, v float64) { o.ScannedBytes = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return o.Throttled }, func(o *OutputStats, v float64) { o.Throttled = v }, false},
+	{func(o *OutputStats) float64 { return o.TargetRate }, func(o *OutputStats, v float64) { o.TargetRate = v }, false},
+	{func(o *OutputStats) float64 { return float64(o.CacheHits) }, func(o *OutputStats, v float64) { o.CacheHits = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return float64(o.CacheMisses) }, func(o *OutputStats, v float64) { o.CacheMisses = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return o.Dirps }, func(o *OutputStats, v float64) { o.Dirps = v }, true},
+	{func(o *OutputStats) float64 { return o.Keyps }, func(o *OutputStats, v float64) { o.Keyps = v }, true},
+	{func(o *OutputStats) float64 { return float64(o.Conflicts) }, func(o *OutputStats, v float64) { o.Conflicts = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return float64(o.QuotaRejects) }, func(o *OutputStats, v float64) { o.QuotaRejects = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return float64(o.Corrupt) }, func(o *OutputStats, v float64) { o.Corrupt = int64(math.Round(v)) }, false},
+	{func(o *OutputStats) float64 { return o.Idle }, func(o *OutputStats, v float64) { o.Idle = v }, false},
+	{func(o *OutputStats) float64 { return o.MaxGap }, func(o *OutputStats, v float64) { o.MaxGap = v }, false},
+}
+
+// totalsByMode -- group the TOTAL rows by mode, keeping the order modes first ran in
+func totalsByMode(oStats []OutputStats) ([]string, map[string][]OutputStats) {
+	order := make([]string, 0)
+	totals := make(map[string][]OutputStats)
+	for _, o := range oStats {
+		if o.IntervalName != "TOTAL" {
+			continue
+		}
+		if _, ok := totals[o.Mode]; !ok {
+			order = append(order, o.Mode)
+		}
+		totals[o.Mode] = append(totals[o.Mode], o)
+	}
+	return order, totals
+}
+
+// aggregateRow -- build a row applying fn to every column of the given totals,
+// or only to the throughput and latency columns with spreadOnly
+func aggregateRow(mode string, name string, totals []OutputStats, spreadOnly bool, fn func([]float64) float64) OutputStats {
+	row := OutputStats{Loop: -1, IntervalName: name, Thread: -1, Mode: mode, RunID: run_id, Tags: run_tags}
+	// The row covers the loops from the start of the first to the end of the last
+	for _, o := range totals {
+		if row.Start.IsZero() || o.Start.Before(row.Start) {
+			row.Start = o.Start
+		}
+		if o.End.After(row.End) {
+			row.End = o.End
+		}
+	}
+	values := make([]float64, len(totals))
+	for _, f := range statFields {
+		if spreadOnly && !f.spread {
+			continue
+		}
+		for i := range totals {
+			values[i] = f.get(&totals[i])
+		}
+		f.set(&row, fn(values))
+	}
+	return row
+}
+
+func mean(v []float64) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
+
+// stddev -- sample standard deviation
+func stddev(v []float64) float64 {
+	if len(v) < 2 {
+		return 0
+	}
+	m := mean(v)
+	sum := 0.0
+	for _, x := range v {
+		sum += (x - m) * (x - m)
+	}
+	return math.Sqrt(sum / float64(len(v)-1))
+}
+
+func minimum(v []float64) float64 {
+	m := math.Inf(1)
+	for _, x := range v {
+		m = math.Min(m, x)
+	}
+	return m
+}
+
+func maximum(v []float64) float64 {
+	m := math.Inf(-1)
+	for _, x := range v {
+		m = math.Max(m, x)
+	}
+	return m
+}
+
+// tCritical95 -- two sided 95% Student's t critical values by degrees of freedom
+var tCritical95 = []float64{
+	0, 12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262,
+	2.228, 2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093,
+	2.086, 2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045,
+	2.042,
+}
+
+// ci95 -- half width of the 95% confidence interval of the mean
+func ci95(v []float64) float64 {
+	n := len(v)
+	if n < 2 {
+		return 0
+	}
+	t := 1.960
+	if n-1 < len(tCritical95) {
+		t = tCritical95[n-1]
+	}
+	return t * stddev(v) / math.Sqrt(float64(n))
+}
+
+// cv -- coefficient of variation, the standard deviation relative to the mean
+func cv(v []float64) float64 {
+	m := mean(v)
+	if m == 0 {
+		return 0
+	}
+	return stddev(v) / m
+}
+
+// aggregateLoops -- summarize the TOTAL rows of every mode that ran more than
+// once with MEAN, STDDEV, MIN and MAX rows, plus the coefficient of variation
+// (CV) and the bounds of the 95% confidence interval of the mean (CI95LOW and
+// CI95HIGH) of the throughput and latencies, their other columns left at 0.
+// Aggregate rows use loop -1.
+func aggregateLoops(oStats []OutputStats) []OutputStats {
+	aggs := make([]OutputStats, 0)
+	order, totals := totalsByMode(oStats)
+	for _, mode := range order {
+		t := totals[mode]
+		if len(t) < 2 {
+			continue
+		}
+		aggs = append(aggs,
+			aggregateRow(mode, "MEAN", t, false, mean),
+			aggregateRow(mode, "STDDEV", t, false, stddev),
+			aggregateRow(mode, "MIN", t, false, minimum),
+			aggregateRow(mode, "MAX", t, false, maximum),
+			aggregateRow(mode, "CV", t, true, cv),
+			aggregateRow(mode, "CI95LOW", t, true, func(v []float64) float64 { return mean(v) - ci95(v) }),
+			aggregateRow(mode, "CI95HIGH", t, true, func(v []float64) float64 { return mean(v) + ci95(v) }))
+		logVariance(mode, t)
+	}
+	for i := range aggs {
+		aggs[i].log()
+	}
+	return aggs
+}
+
+// logVariance -- log a readable run-to-run variance summary for one mode
+func logVariance(mode string, totals []OutputStats) {
+	summarize := func(get func(o *OutputStats) float64) string {
+		v := make([]float64, len(totals))
+		for i := range totals {
+			v[i] = get(&totals[i])
+		}
+		return fmt.Sprintf("%.2f ± %.2f (CV %.1f%%)", mean(v), ci95(v), cv(v)*100)
+	}
+	logInfof("Mode: %s, Runs: %d, 95%% CI: [ MB/s: %s, IO/s: %s, 50%%(ms): %s, 99%%(ms): %s ]",
+		mode,
+		len(totals),
+		summarize(func(o *OutputStats) float64 { return o.Mbps }),
+		summarize(func(o *OutputStats) float64 { return o.Iops }),
+		summarize(func(o *OutputStats) float64 { return o.Lat50 }),
+		summarize(func(o *OutputStats) float64 { return o.Lat99 }))
+}
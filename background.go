@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The -bg-* options: a background workload run alongside the -bg-tests
+// tests without being counted in them
+var bg_mode, bg_tests, bg_rate_arg, bg_size_arg string
+var bg_threads int
+var bg_rate, bg_size int64
+
+// bg_data -- the body of every background PUT
+var bg_data []byte
+
+// bgPrefix -- the key prefix of the objects the background PUTs write, so
+// they never overwrite the objects the tests use
+const bgPrefix = "hsbench-background/"
+
+// background -- a running background workload
+type background struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	mu       sync.Mutex
+	nextNano int64
+
+	// Totals, and the totals at the last interval row
+	offered, done, errors int64
+	lastOffered, lastDone int64
+	startNano, lastNano   int64
+}
+
+// startBackground -- start the -bg-threads background threads for the test
+// r, or return nil when r is not one of -bg-tests
+func startBackground(loop int, r rune) *background {
+	if bg_mode == "" || !strings.ContainsRune(bg_tests, r) {
+		return nil
+	}
+	if bg_mode == "g" && object_count <= 0 {
+		logWarnf("Loop %d has no objects for the background GETs to read", loop)
+		return nil
+	}
+	if bg_mode == "p" && bg_data == nil {
+		bg_data = make([]byte, bg_size)
+		if !zero_object_data {
+			data_rand.read(bg_data)
+		}
+	}
+	name := map[string]string{"p": "PUT", "g": "GET"}[bg_mode]
+	logInfof("Loop %d starting %d background %s threads at %s", loop, bg_threads, name, bgRateString())
+	now := time.Now().UnixNano()
+	b := &background{stop: make(chan struct{}), startNano: now, lastNano: now}
+	for n := 0; n < bg_threads; n++ {
+		b.wg.Add(1)
+		go b.run(n)
+	}
+	b.wg.Add(1)
+	go b.logIntervals(loop)
+	return b
+}
+
+// bgRateString -- the -bg-rate as given to it, or full speed
+func bgRateString() string {
+	if bg_rate == 0 {
+		return "full speed"
+	}
+	return bytefmt.ByteSize(uint64(bg_rate)) + "/s"
+}
+
+// pace -- wait until the -bg-rate allows size more bytes, or the background
+// is stopped. Returns false once stopped.
+func (b *background) pace(size int64) bool {
+	if bg_rate > 0 {
+		b.mu.Lock()
+		now := time.Now().UnixNano()
+		if b.nextNano < now {
+			b.nextNano = now
+		}
+		at := b.nextNano
+		b.nextNano += size * int64(time.Second) / bg_rate
+		b.mu.Unlock()
+		select {
+		case <-b.stop:
+			return false
+		case <-time.After(time.Duration(at - now)):
+		}
+	}
+	select {
+	case <-b.stop:
+		return false
+	default:
+		return true
+	}
+}
+
+// run -- one background thread, putting its own objects or getting the
+// objects of the tests until stopped
+func (b *background) run(thread_num int) {
+	defer b.wg.Done()
+	svc := newS3Client()
+	for n := int64(0); ; n++ {
+		size := bg_size
+		var bucket *string
+		var key string
+		if bg_mode == "g" {
+			bucket, key, size = objectName(0, (n*int64(bg_threads)+int64(thread_num))%object_count, nil)
+		} else {
+			bucket = &buckets[n%bucket_count]
+			key = fmt.Sprintf("%s%04d/%012d", bgPrefix, thread_num, n)
+		}
+		if !b.pace(size) {
+			return
+		}
+		atomic.AddInt64(&b.offered, size)
+		var err error
+		if bg_mode == "g" {
+			var out *s3.GetObjectOutput
+			if out, err = svc.GetObject(&s3.GetObjectInput{Bucket: bucket, Key: &key}); err == nil {
+				size, err = drainBody(out.Body)
+			}
+		} else {
+			req, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+				Bucket: bucket,
+				Key:    aws.String(key),
+				Body:   bytes.NewReader(bg_data),
+			})
+			// Disable payload checksum calculation (very expensive)
+			req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+			err = req.Send()
+		}
+		if err != nil {
+			if atomic.AddInt64(&b.errors, 1) == 1 {
+				logWarn("background err", "mode", "BACKGROUND", "thread", thread_num, "error", err)
+			}
+			continue
+		}
+		atomic.AddInt64(&b.done, size)
+	}
+}
+
+// logIntervals -- log the background load every -ri seconds
+func (b *background) logIntervals(loop int) {
+	defer b.wg.Done()
+	ticker := time.NewTicker(time.Duration(interval * float64(time.Second)))
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.logLoad(loop, fmt.Sprint(i))
+		}
+	}
+}
+
+// logLoad -- log the load offered and done since the last row
+func (b *background) logLoad(loop int, name string) {
+	now := time.Now().UnixNano()
+	offered, done := atomic.LoadInt64(&b.offered), atomic.LoadInt64(&b.done)
+	from := b.lastNano
+	if name == "TOTAL" {
+		from, b.lastOffered, b.lastDone = b.startNano, 0, 0
+	}
+	seconds := float64(now-from) / 1e9
+	logInfof("Loop: %d, Int: %s, Dur(s): %.1f, Mode: BACKGROUND, Offered MB/s: %.2f, Done MB/s: %.2f, Errors: %d",
+		loop, name, seconds,
+		float64(offered-b.lastOffered)/seconds/bytefmt.MEGABYTE,
+		float64(done-b.lastDone)/seconds/bytefmt.MEGABYTE,
+		atomic.LoadInt64(&b.errors))
+	b.lastOffered, b.lastDone, b.lastNano = offered, done, now
+}
+
+// finish -- stop the background threads once the test is over and log the
+// load they put on the server during it
+func (b *background) finish(loop int) {
+	if b == nil {
+		return
+	}
+	close(b.stop)
+	b.wg.Wait()
+	b.logLoad(loop, "TOTAL")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var backoff_base float64
+var backoff_max float64
+
+// isThrottle -- whether err is the backend asking us to slow down
+func isThrottle(err error) bool {
+	if rerr, ok := err.(awserr.RequestFailure); ok {
+		switch rerr.StatusCode() {
+		case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+			return true
+		}
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequests":
+			return true
+		}
+	}
+	return false
+}
+
+// throttleRetryer -- the SDK retryer, except throttles are handed back to the
+// benchmark thread so that its backoff, not the SDK's, is measured
+type throttleRetryer struct {
+	client.DefaultRetryer
+}
+
+func (r throttleRetryer) ShouldRetry(req *request.Request) bool {
+	if backoff_base > 0 && isThrottle(req.Error) {
+		return false
+	}
+	return r.DefaultRetryer.ShouldRetry(req)
+}
+
+// backoffDelay -- full jitter exponential backoff for the given attempt
+func backoffDelay(attempt int) time.Duration {
+	ceiling := backoff_base * float64(int64(1)<<uint(min(attempt, 30)))
+	if ceiling > backoff_max {
+		ceiling = backoff_max
+	}
+	return time.Duration(backoff_rand.float64() * ceiling * float64(time.Second))
+}
+
+// throttle -- if err is a throttle, sleep off an exponentially growing delay
+// and record it as throttled time. Rejections by a declared quota are also
+// expected. Returns false for other errors, which count towards the thread's
+// error limit.
+func (stats *Stats) throttle(thread_num int, err error) bool {
+	if stats.rejectQuota(thread_num, err) {
+		return true
+	}
+	if backoff_base <= 0 || !isThrottle(err) {
+		return false
+	}
+	ts := &stats.threadStats[thread_num]
+	delay := backoffDelay(ts.backoffs)
+	ts.backoffs++
+	logDebug("throttled", stats.attrs(thread_num, "backoff", delay, "error", err)...)
+	time.Sleep(delay)
+	stats.current(thread_num).throttleNano += int64(delay)
+	ts.mu.Unlock()
+	return true
+}
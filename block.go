@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var block_size_arg string
+var block_size int64
+var block_objects int64
+
+// runBlockRead -- read random block aligned ranges from a few large objects,
+// like a virtual disk or database file kept in S3
+func runBlockRead(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		opnum := atomic.AddInt64(&op_counter, 1)
+		if duration_secs <= -1 && opnum >= object_count {
+			atomic.AddInt64(&op_counter, -1)
+			break
+		}
+
+		bucket, key, size := objectName(thread_num, int64(rand.intn(int(block_objects))), rand)
+		blocks := size / block_size
+		if blocks < 1 {
+			stats.abort(thread_num, fmt.Sprintf("object %s is smaller than one %d byte block", key, block_size))
+			break
+		}
+		offset := int64(rand.intn(int(blocks))) * block_size
+		rng := fmt.Sprintf("bytes=%d-%d", offset, offset+block_size-1)
+		r := &s3.GetObjectInput{
+			Bucket: bucket,
+			Key:    &key,
+			Range:  &rng,
+		}
+
+		start := time.Now().UnixNano()
+		req, resp := svc.GetObjectRequest(r)
+		err := req.Send()
+		if err == nil {
+			drainBody(resp.Body)
+		}
+		end := time.Now().UnixNano()
+
+		if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, "block read", err) {
+				break
+			}
+		} else {
+			stats.addBucketOp(thread_num, *bucket, block_size, end-start)
+		}
+		think(start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var bucket_template, bucket_file string
+
+// bucket_names holds the buckets read from -bucket-file
+var bucket_names []string
+
+var bucketNumber = regexp.MustCompile(`\{n(?::(\d+))?\}`)
+
+// bucketName -- name bucket i from the -bucket-template, where {prefix} is
+// the bucket prefix, {n} the bucket number and {n:W} the number zero padded
+// to W digits. Without a template buckets are the prefix and 12 digits.
+func bucketName(i int64) string {
+	if bucket_template == "" {
+		return fmt.Sprintf("%s%012d", bucket_prefix, i)
+	}
+	name := strings.ReplaceAll(bucket_template, "{prefix}", bucket_prefix)
+	return bucketNumber.ReplaceAllStringFunc(name, func(m string) string {
+		width := bucketNumber.FindStringSubmatch(m)[1]
+		if width == "" {
+			return strconv.FormatInt(i, 10)
+		}
+		w, _ := strconv.Atoi(width)
+		return fmt.Sprintf("%0*d", w, i)
+	})
+}
+
+// readBucketFile -- read one bucket name per line, skipping blank lines and
+// # comments
+func readBucketFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no buckets in file")
+	}
+	return names, nil
+}
+
+// setupBuckets -- fill in the bucket names used by the tests
+func setupBuckets() {
+	if bucket_names != nil {
+		buckets = bucket_names
+		return
+	}
+	for i := int64(0); i < bucket_count; i++ {
+		buckets = append(buckets, bucketName(i))
+	}
+}
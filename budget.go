@@ -0,0 +1,42 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+var max_bytes_arg string
+var max_bytes int64
+
+// Bytes of -max-bytes the running test has taken, and whether it ran out
+var phase_bytes int64
+var phase_bytes_out int32
+
+// resetByteBudget -- every PUT and GET test gets the whole -max-bytes
+func resetByteBudget() {
+	atomic.StoreInt64(&phase_bytes, 0)
+	atomic.StoreInt32(&phase_bytes_out, 0)
+}
+
+// takeBytes -- take size bytes of the -max-bytes budget for a request about
+// to be made. Returns false when they would take the test over the budget,
+// which ends it for the thread.
+func takeBytes(stats *Stats, size int64) bool {
+	if max_bytes <= 0 {
+		return true
+	}
+	if atomic.AddInt64(&phase_bytes, size) <= max_bytes {
+		return true
+	}
+	atomic.AddInt64(&phase_bytes, -size)
+	if atomic.CompareAndSwapInt32(&phase_bytes_out, 0, 1) {
+		logInfof("Loop %d %s test reached -max-bytes %s", stats.loop, stats.mode, max_bytes_arg)
+	}
+	return false
+}
+
+// returnBytes -- give back the bytes taken for a request that failed
+func returnBytes(size int64) {
+	if max_bytes > 0 {
+		atomic.AddInt64(&phase_bytes, -size)
+	}
+}
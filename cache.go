@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// cache_headers are checked for HIT or MISS on GET responses, on top of the
+// well known cache status headers below
+var cache_headers stringListFlag
+
+var defaultCacheHeaders = []string{"X-Cache", "X-Cache-Status", "CF-Cache-Status", "X-Proxy-Cache"}
+
+// cacheStatus -- whether a response came from a cache, if it says so. A
+// positive Age means the response was cached somewhere along the way.
+func cacheStatus(h http.Header) (hit bool, known bool) {
+	for _, name := range append(cache_headers, defaultCacheHeaders...) {
+		v := strings.ToUpper(h.Get(name))
+		switch {
+		case v == "":
+			continue
+		case strings.Contains(v, "HIT"):
+			return true, true
+		case strings.Contains(v, "MISS"), strings.Contains(v, "EXPIRED"), strings.Contains(v, "BYPASS"):
+			return false, true
+		}
+	}
+	if age, err := strconv.Atoi(h.Get("Age")); err == nil {
+		return age > 0, true
+	}
+	return false, false
+}
+
+// addCacheStatus -- count a GET response as a cache hit or miss when its
+// headers tell
+func (stats *Stats) addCacheStatus(thread_num int, req *request.Request) {
+	if req.HTTPResponse == nil {
+		return
+	}
+	hit, known := cacheStatus(req.HTTPResponse.Header)
+	if !known {
+		return
+	}
+	is := stats.current(thread_num)
+	if hit {
+		is.cacheHits++
+	} else {
+		is.cacheMisses++
+	}
+	stats.threadStats[thread_num].mu.Unlock()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// newChecksumHash -- return the hash for an S3 checksum algorithm, or nil if unknown
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case s3.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE()
+	case s3.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case s3.ChecksumAlgorithmSha1:
+		return sha1.New()
+	case s3.ChecksumAlgorithmSha256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// setContentMD5 -- hash the body of a PUT request for its Content-MD5 header.
+// The hash is worked out for every request, like a client checking its writes
+// end to end would, so its cost shows in the PUT latency.
+func setContentMD5(r *s3.PutObjectInput) {
+	if !content_md5 {
+		return
+	}
+	h := md5.New()
+	io.Copy(h, r.Body)
+	r.Body.Seek(0, io.SeekStart)
+	r.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(h.Sum(nil)))
+}
+
+const trailerPayload = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+// setChecksum -- send the -checksum of a PUT body as a trailer after it,
+// the body going in unsigned aws-chunked -chunk-size chunks as the newer
+// SDKs send it. The v1 SDK has no trailer support, so like -upload-mode
+// aws-chunked the content length step is replaced and the body swapped
+// for an encoding one before each attempt. The checksum is worked out as
+// the body is sent, so its cost shows in the PUT latency.
+func setChecksum(req *request.Request) {
+	if checksum_algorithm == "" {
+		return
+	}
+	trailer := "x-amz-checksum-" + strings.ToLower(checksum_algorithm)
+	req.Handlers.Sign.Swap(corehandlers.BuildContentLengthHandler.Name, request.NamedHandler{
+		Name: "hsbench.TrailerContentLength",
+		Fn: func(r *request.Request) {
+			size, err := aws.SeekerLen(r.Body)
+			if err != nil {
+				r.Error = err
+				return
+			}
+			length := trailerChunkedLength(size, trailer)
+			h := r.HTTPRequest.Header
+			h.Set("X-Amz-Content-Sha256", trailerPayload)
+			h.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(size, 10))
+			h.Set("X-Amz-Sdk-Checksum-Algorithm", checksum_algorithm)
+			h.Set("X-Amz-Trailer", trailer)
+			h.Set("Content-Encoding", "aws-chunked")
+			h.Set("Content-Length", strconv.FormatInt(length, 10))
+			r.HTTPRequest.ContentLength = length
+		},
+	})
+	req.Handlers.Send.PushFront(func(r *request.Request) {
+		// The transport resends through GetBody when a pooled connection
+		// turns out closed
+		r.HTTPRequest.GetBody = func() (io.ReadCloser, error) {
+			r.Body.Seek(0, io.SeekStart)
+			return io.NopCloser(&trailerEncoder{
+				body:    r.Body,
+				hash:    newChecksumHash(checksum_algorithm),
+				trailer: trailer,
+				chunk:   make([]byte, chunk_size),
+			}), nil
+		}
+		r.HTTPRequest.Body, _ = r.HTTPRequest.GetBody()
+	})
+}
+
+// trailerChunkedLength -- the encoded length of a body of size bytes with
+// the trailer holding its checksum
+func trailerChunkedLength(size int64, trailer string) int64 {
+	chunkLen := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))) + 2 + n + 2
+	}
+	length := (size / chunk_size) * chunkLen(chunk_size)
+	if rest := size % chunk_size; rest > 0 {
+		length += chunkLen(rest)
+	}
+	sum := base64.StdEncoding.EncodedLen(newChecksumHash(checksum_algorithm).Size())
+	return length + int64(len("0\r\n")+len(trailer)+1+sum+len("\r\n\r\n"))
+}
+
+// trailerEncoder -- the unsigned aws-chunked encoding of a body, hashing it
+// on the way and ending with the checksum trailer
+type trailerEncoder struct {
+	body    io.Reader
+	hash    hash.Hash
+	trailer string
+	chunk   []byte
+	out     []byte
+	done    bool
+}
+
+// next -- encode the next chunk, the final chunk and trailer once the body
+// is read
+func (e *trailerEncoder) next() error {
+	n, err := io.ReadFull(e.body, e.chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if n == 0 {
+		sum := base64.StdEncoding.EncodeToString(e.hash.Sum(nil))
+		e.out = append(e.out[:0], fmt.Sprintf("0\r\n%s:%s\r\n\r\n", e.trailer, sum)...)
+		e.done = true
+		return nil
+	}
+	data := e.chunk[:n]
+	e.hash.Write(data)
+	e.out = append(e.out[:0], fmt.Sprintf("%x\r\n", n)...)
+	e.out = append(e.out, data...)
+	e.out = append(e.out, "\r\n"...)
+	return nil
+}
+
+func (e *trailerEncoder) Read(p []byte) (int, error) {
+	if len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+func runGetAttributes(thread_num int, fendtime time.Time, rand *ThreadSafeUUID, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	attributes := aws.StringSlice([]string{
+		s3.ObjectAttributesEtag,
+		s3.ObjectAttributesChecksum,
+		s3.ObjectAttributesObjectSize,
+		s3.ObjectAttributesStorageClass,
+	})
+	next := func() (int64, bool) {
+		return nextCounted(loop_objects && duration_secs > -1, false)
+	}
+	runRequests(thread_num, stats, "get attributes", next, func(objnum int64) (string, func() (int64, error)) {
+		bucket, key, _ := objectName(thread_num, objnum, rand)
+		r := &s3.GetObjectAttributesInput{
+			Bucket:           bucket,
+			Key:              &key,
+			ObjectAttributes: attributes,
+		}
+		return *bucket, func() (int64, error) {
+			_, err := svc.GetObjectAttributes(r)
+			return 0, err
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
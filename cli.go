@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// subcommands run instead of a benchmark when named as the first argument
+var subcommands = map[string]func(args []string) int{
+	"compare": runCompare,
+	"report":  runReport,
+	"history": runHistory,
+	"agent":   runAgent,
+	"kube":    runKube,
+}
+
+var subcommand func(args []string) int
+
+// benchmarkCommands -- subcommands running a benchmark with the usual
+// options, and the modes they run. A plain "hsbench [OPTIONS]" is the same
+// as run.
+var benchmarkCommands = map[string]string{
+	"run":     "",
+	"prefill": "ip",
+	"clean":   "cx",
+}
+
+var benchmark_command string
+
+const commandsUsage = `
+COMMANDS:
+  run      run the -m tests, the default when no command is given
+  prefill  create the buckets and put -n objects in them, like -m ip
+  clean    delete the objects and the buckets, like -m cx
+  compare  compare runs recorded with -history-db test by test
+  report   write -report and -md files from -j JSON results
+  history  list runs recorded with -history-db
+  agent    run as a daemon taking benchmark jobs over HTTP
+  kube     run workers as a Kubernetes Job
+
+  "hsbench <command> -h" shows the options of a command.
+`
+
+// pickSubcommand -- whether the first argument names a subcommand, which
+// then parses its own flags
+func pickSubcommand() bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	subcommand = subcommands[os.Args[1]]
+	return subcommand != nil
+}
+
+// benchmarkArgs -- the benchmark options, after the command if one is given
+func benchmarkArgs() []string {
+	if len(os.Args) > 1 {
+		if _, ok := benchmarkCommands[os.Args[1]]; ok {
+			benchmark_command = os.Args[1]
+			return os.Args[2:]
+		}
+	}
+	return os.Args[1:]
+}
+
+// setupCommand -- run the modes of prefill or clean, which choose them
+// instead of -m
+func setupCommand(fs *flag.FlagSet) {
+	preset := benchmarkCommands[benchmark_command]
+	if preset == "" {
+		return
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "m" {
+			configFatalf("-m can not be used with %s, which runs %q", benchmark_command, preset)
+		}
+	})
+	fs.Set("m", preset)
+}
+
+// runCompare -- "hsbench compare 3 7", the history of the given runs side
+// by side
+func runCompare(args []string) int {
+	var path, mode string
+	cflag := flag.NewFlagSet("compare", flag.ContinueOnError)
+	cflag.StringVar(&path, "db", "hsbench.db", "History database written by -history-db")
+	cflag.StringVar(&mode, "mode", "", "Only tests of this mode, ie PUT")
+	cflag.Usage = func() {
+		fmt.Fprintf(cflag.Output(), "\nUSAGE: %s compare [OPTIONS] <run> <run>...\n\n", os.Args[0])
+		fmt.Fprintf(cflag.Output(), "OPTIONS:\n")
+		cflag.PrintDefaults()
+	}
+	if err := cflag.Parse(args); err != nil {
+		return exitConfigError
+	}
+	if err := applyEnv(cflag, "HSBENCH_COMPARE_"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	if cflag.NArg() < 2 {
+		cflag.Usage()
+		return exitConfigError
+	}
+	return runHistory([]string{"-db", path, "-mode", mode, "-compare", strings.Join(cflag.Args(), ",")})
+}
+
+// runReport -- write the HTML report and Markdown table of earlier runs from
+// their -j JSON results
+func runReport(args []string) int {
+	rflag := flag.NewFlagSet("report", flag.ContinueOnError)
+	rflag.StringVar(&report_output, "report", "", "Write a self-contained HTML report to this file")
+	rflag.StringVar(&markdown_output, "md", "", "Write a Markdown table of the test totals to this file")
+	rflag.Usage = func() {
+		fmt.Fprintf(rflag.Output(), "\nUSAGE: %s report [OPTIONS] <results.json>...\n\n", os.Args[0])
+		fmt.Fprintf(rflag.Output(), "OPTIONS:\n")
+		rflag.PrintDefaults()
+	}
+	if err := rflag.Parse(args); err != nil {
+		return exitConfigError
+	}
+	if err := applyEnv(rflag, "HSBENCH_REPORT_"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	if rflag.NArg() == 0 || (report_output == "" && markdown_output == "") {
+		rflag.Usage()
+		return exitConfigError
+	}
+	oStats := make([]OutputStats, 0)
+	for _, path := range rflag.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not read results: %v\n", err)
+			return exitConfigError
+		}
+		var rows []OutputStats
+		if err := json.Unmarshal(data, &rows); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not parse results %s: %v\n", path, err)
+			return exitConfigError
+		}
+		oStats = append(oStats, rows...)
+	}
+	writeReport(oStats)
+	writeMarkdown(oStats)
+	return exitOK
+}
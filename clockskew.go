@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+var clock_skew string
+var max_clock_skew float64
+
+// clock_offset_nano is added to the local clock when signing in "correct" mode
+var clock_offset_nano int64
+
+// skewedNow -- the local time adjusted by the measured clock skew
+func skewedNow() time.Time {
+	return time.Now().Add(time.Duration(atomic.LoadInt64(&clock_offset_nano)))
+}
+
+// signWithSkew -- S3 signing handler that signs with the corrected clock
+var signWithSkew = request.NamedHandler{
+	Name: v4.SignRequestHandler.Name,
+	Fn: func(r *request.Request) {
+		v4.SignSDKRequestWithCurrentTime(r, skewedNow, func(s *v4.Signer) {
+			s.DisableURIPathEscaping = true
+		})
+	},
+}
+
+// handleSkewedRequest -- explain RequestTimeTooSkewed failures, and in
+// "correct" mode adopt the server clock and retry the request.
+func handleSkewedRequest(r *request.Request) {
+	aerr, ok := r.Error.(awserr.Error)
+	if !ok || aerr.Code() != "RequestTimeTooSkewed" || r.HTTPResponse == nil {
+		return
+	}
+	date, err := http.ParseTime(r.HTTPResponse.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	skew := date.Sub(time.Now())
+	if clock_skew == "correct" {
+		atomic.StoreInt64(&clock_offset_nano, int64(skew))
+		r.Retryable = aws.Bool(true)
+		logWarnf("Request rejected for clock skew, adjusting signing time by %s", skew)
+	} else {
+		logWarnf("Request rejected for clock skew, server clock is %s ahead of this client", skew)
+	}
+}
+
+// checkClockSkew -- compare the local clock with the server before any tests
+// run, warning about or correcting a large difference
+func checkClockSkew() {
+	skew, err := measureClockSkew(newS3Client(), &buckets[0])
+	if skew == 0 && err != nil {
+		logWarnf("Unable to measure clock skew: %v", err)
+		return
+	}
+	logInfof("Clock skew: server is %.3fs ahead of this client", skew.Seconds())
+	if math.Abs(skew.Seconds()) < max_clock_skew {
+		return
+	}
+	if clock_skew == "correct" {
+		atomic.StoreInt64(&clock_offset_nano, int64(skew))
+		logWarnf("Clock skew of %s exceeds %gs, signing requests with the server clock", skew, max_clock_skew)
+	} else {
+		logWarnf("Clock skew of %s exceeds %gs, requests may fail with signature errors (see -clock-skew)", skew, max_clock_skew)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var contention_keys int
+var contention_conditional bool
+
+// isConflict -- whether err is a normal outcome of racing other writers on a
+// key: it was deleted (404), or a conflicting or conditional write lost (409,
+// 412)
+func isConflict(err error) bool {
+	if rerr, ok := err.(awserr.RequestFailure); ok {
+		switch rerr.StatusCode() {
+		case http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed:
+			return true
+		}
+	}
+	return false
+}
+
+// runContention -- PUT, GET and DELETE the same few keys from every thread
+func runContention(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	buf := integrityBuffer()
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		opnum := atomic.AddInt64(&op_counter, 1)
+		if object_count > -1 && opnum >= object_count {
+			atomic.AddInt64(&op_counter, -1)
+			break
+		}
+		key := fmt.Sprintf("%scontended%06d", object_prefix, rand.intn(contention_keys))
+		bucket := &buckets[0]
+
+		var req *request.Request
+		var get *s3.GetObjectOutput
+		switch rand.intn(3) {
+		case 0:
+			req, _ = svc.PutObjectRequest(&s3.PutObjectInput{
+				Bucket: bucket,
+				Key:    &key,
+				Body:   objectBody(buf, key),
+			})
+			// Disable payload checksum calculation (very expensive)
+			req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+			if contention_conditional {
+				// Only create the key, losing writers get a 412
+				req.HTTPRequest.Header.Set("If-None-Match", "*")
+			}
+		case 1:
+			req, get = svc.GetObjectRequest(&s3.GetObjectInput{Bucket: bucket, Key: &key})
+		case 2:
+			req, _ = svc.DeleteObjectRequest(&s3.DeleteObjectInput{Bucket: bucket, Key: &key})
+		}
+
+		start := time.Now().UnixNano()
+		err := req.Send()
+		if err == nil && get != nil {
+			drainBody(get.Body)
+		}
+		end := time.Now().UnixNano()
+
+		if isConflict(err) {
+			stats.addConflict(thread_num, end-start)
+		} else if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, "contention", err) {
+				break
+			}
+		} else {
+			stats.addOp(thread_num, 0, end-start)
+		}
+		think(start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
+
+// addConflict -- count an operation that lost a race on its key
+func (stats *Stats) addConflict(thread_num int, latNano int64) {
+	stats.current(thread_num).conflicts++
+	stats.threadStats[thread_num].mu.Unlock()
+	stats.addOp(thread_num, 0, latNano)
+}
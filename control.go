@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var control_addr string
+
+// Operator adjustments made through the control endpoint
+var control_paused int32
+var control_finish int32
+var control_rate uint64 // float64 bits of the ops/s override, 0 for none
+
+// phaseOver -- whether the running test should stop, because its duration is
+// up or an early finish was asked for
+func phaseOver() bool {
+	if atomic.LoadInt32(&control_finish) != 0 {
+		return true
+	}
+	return duration_secs > -1 && time.Now().After(endtime)
+}
+
+// controlRate -- the target rate set through the control endpoint, or 0
+func controlRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&control_rate))
+}
+
+// waitResume -- hold the calling thread while the load is paused
+func waitResume() {
+	for atomic.LoadInt32(&control_paused) != 0 && !phaseOver() {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// resetControl -- a finish asked for applies to the running test only
+func resetControl() {
+	atomic.StoreInt32(&control_finish, 0)
+}
+
+// ControlStatus -- the reply to every control request
+type ControlStatus struct {
+	Paused         bool
+	Finishing      bool
+	TargetRate     float64
+	RunningThreads int64
+}
+
+func controlStatus() ControlStatus {
+	return ControlStatus{
+		atomic.LoadInt32(&control_paused) != 0,
+		atomic.LoadInt32(&control_finish) != 0,
+		controlRate(),
+		atomic.LoadInt64(&running_threads)}
+}
+
+// startControl -- serve the control endpoint:
+//
+//	GET  /status          current state
+//	POST /pause           stop offering load until resumed
+//	POST /resume          resume the load
+//	POST /rate?ops=<n>    run open loop at n ops/s, 0 to go back to -profile or closed loop
+//	POST /finish          end the running test now, as if its duration was up
+func startControl() {
+	listener, err := net.Listen("tcp", control_addr)
+	if err != nil {
+		logFatalf("Could not listen on -control address %s: %v", control_addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeControlStatus(w)
+	})
+	mux.HandleFunc("/pause", controlAction(func(r *http.Request) error {
+		atomic.StoreInt32(&control_paused, 1)
+		logInfof("Control: load paused")
+		return nil
+	}))
+	mux.HandleFunc("/resume", controlAction(func(r *http.Request) error {
+		atomic.StoreInt32(&control_paused, 0)
+		logInfof("Control: load resumed")
+		return nil
+	}))
+	mux.HandleFunc("/rate", controlAction(func(r *http.Request) error {
+		ops, err := strconv.ParseFloat(r.URL.Query().Get("ops"), 64)
+		if err != nil || ops < 0 || math.IsInf(ops, 0) {
+			return fmt.Errorf("expected ops=<ops/s>")
+		}
+		atomic.StoreUint64(&control_rate, math.Float64bits(ops))
+		logInfof("Control: target rate set to %.0f ops/s", ops)
+		return nil
+	}))
+	mux.HandleFunc("/finish", controlAction(func(r *http.Request) error {
+		atomic.StoreInt32(&control_finish, 1)
+		logInfof("Control: finishing the running test")
+		return nil
+	}))
+	logInfof("Control endpoint listening on %s", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logErrorf("Control endpoint stopped: %v", err)
+		}
+	}()
+}
+
+// controlAction -- wrap a state change, allowing POST only
+func controlAction(action func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := action(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeControlStatus(w)
+	}
+}
+
+func writeControlStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(controlStatus())
+}
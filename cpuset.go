@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var cpu_set_args stringListFlag
+var cpu_sets []cpuSet
+
+// cpuSet -- the CPUs one group of worker threads runs on
+type cpuSet []int
+
+// parseCPUSet -- parse a CPU list such as 0-3,8,10-11
+func parseCPUSet(arg string) (cpuSet, error) {
+	var set cpuSet
+	for _, part := range strings.Split(arg, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("%q is not a CPU list like 0-3,8", arg)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("%q is not a CPU list like 0-3,8", arg)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			set = append(set, cpu)
+		}
+	}
+	return set, nil
+}
+
+// parseCPUSets -- parse the -cpu-set arguments, checking the process may
+// run on every CPU named
+func parseCPUSets(args []string) ([]cpuSet, error) {
+	var sets []cpuSet
+	for _, arg := range args {
+		set, err := parseCPUSet(arg)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCPUSet(set); err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// worker -- start test thread n, pinned to the CPUs of its -cpu-set group.
+// Thread n belongs to group n modulo the number of groups.
+func worker(n int, run func()) {
+	go func() {
+		if len(cpu_sets) > 0 {
+			if err := pinThread(cpu_sets[n%len(cpu_sets)]); err != nil {
+				logWarnf("Thread %d could not be pinned to its CPUs: %v", n, err)
+			}
+		}
+		run()
+	}()
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// cpuMask -- a kernel cpu_set_t for up to 1024 CPUs
+type cpuMask [1024 / 64]uint64
+
+func (m *cpuMask) set(cpu int) {
+	m[cpu/64] |= 1 << (uint(cpu) % 64)
+}
+
+func (m *cpuMask) isSet(cpu int) bool {
+	return m[cpu/64]&(1<<(uint(cpu)%64)) != 0
+}
+
+func schedAffinity(trap uintptr, m *cpuMask) error {
+	_, _, errno := syscall.RawSyscall(trap, 0, unsafe.Sizeof(*m), uintptr(unsafe.Pointer(m)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// checkCPUSet -- make sure the process is allowed to run on set
+func checkCPUSet(set cpuSet) error {
+	var allowed cpuMask
+	if err := schedAffinity(syscall.SYS_SCHED_GETAFFINITY, &allowed); err != nil {
+		return err
+	}
+	for _, cpu := range set {
+		if cpu >= len(allowed)*64 || !allowed.isSet(cpu) {
+			return fmt.Errorf("CPU %d is not available to the process", cpu)
+		}
+	}
+	return nil
+}
+
+// pinThread -- lock the calling goroutine to its OS thread and bind the
+// thread to set. The thread exits with the goroutine, so the binding does
+// not leak to other goroutines.
+func pinThread(set cpuSet) error {
+	runtime.LockOSThread()
+	var m cpuMask
+	for _, cpu := range set {
+		m.set(cpu)
+	}
+	return schedAffinity(syscall.SYS_SCHED_SETAFFINITY, &m)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errNoPinning = errors.New("CPU pinning is only supported on Linux")
+
+func checkCPUSet(set cpuSet) error {
+	return errNoPinning
+}
+
+func pinThread(set cpuSet) error {
+	return errNoPinning
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+var cred_command, cred_file string
+var cred_refresh time.Duration
+
+// credentialOutput -- what -cred-command prints or -cred-file holds, the
+// JSON of an AWS CLI credential_process, so existing helpers for Vault or
+// IRSA can be reused
+type credentialOutput struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      *time.Time
+}
+
+// refreshingProvider -- credentials reread every -cred-refresh, or sooner
+// when they say they expire sooner
+type refreshingProvider struct {
+	credentials.Expiry
+}
+
+func (p *refreshingProvider) Retrieve() (credentials.Value, error) {
+	var data []byte
+	var err error
+	if cred_command != "" {
+		cmd := exec.Command("sh", "-c", cred_command)
+		cmd.Stderr = os.Stderr
+		data, err = cmd.Output()
+	} else {
+		data, err = os.ReadFile(cred_file)
+	}
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("reading credentials: %v", err)
+	}
+	var out credentialOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return credentials.Value{}, fmt.Errorf("parsing credentials: %v", err)
+	}
+	if out.AccessKeyId == "" || out.SecretAccessKey == "" {
+		return credentials.Value{}, fmt.Errorf("credentials without an AccessKeyId and SecretAccessKey")
+	}
+	expires := time.Now().Add(cred_refresh)
+	if out.Expiration != nil && out.Expiration.Before(expires) {
+		expires = *out.Expiration
+	}
+	// Refresh a little early so requests are not signed with keys about to expire
+	p.SetExpiration(expires, min(time.Minute, time.Until(expires)/10))
+	logInfof("Loaded credentials for %s, next refresh by %s", out.AccessKeyId, expires.Format(time.RFC3339))
+	return credentials.Value{
+		AccessKeyID:     out.AccessKeyId,
+		SecretAccessKey: out.SecretAccessKey,
+		SessionToken:    out.SessionToken,
+		ProviderName:    "hsbench",
+	}, nil
+}
+
+// makeCredentials -- the -a and -s keys, or credentials refreshed from
+// -cred-command or -cred-file for runs outliving short-lived credentials
+func makeCredentials() *credentials.Credentials {
+	if cred_command == "" && cred_file == "" {
+		return credentials.NewStaticCredentials(access_key, secret_key, "")
+	}
+	creds := credentials.NewCredentials(&refreshingProvider{})
+	if _, err := creds.Get(); err != nil {
+		logFatalf("Could not load credentials: %v", err)
+	}
+	return creds
+}
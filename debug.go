@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var debug_sample float64
+
+// formatHeaders -- render headers on one line with credentials redacted
+func formatHeaders(h http.Header) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := strings.Join(h[k], ",")
+		if k == "Authorization" || k == "X-Amz-Security-Token" {
+			v = "<redacted>"
+		}
+		parts = append(parts, k+": "+v)
+	}
+	return "{" + strings.Join(parts, "; ") + "}"
+}
+
+// logSampledRequest -- log the details of a random sample of completed requests
+func logSampledRequest(r *request.Request) {
+	if sample_rand.float64() >= debug_sample {
+		return
+	}
+	status := 0
+	respHeaders := "{}"
+	if r.HTTPResponse != nil {
+		status = r.HTTPResponse.StatusCode
+		respHeaders = formatHeaders(r.HTTPResponse.Header)
+	}
+	logInfof("Sampled %s %s %s status=%d duration=%s retries=%d err=%v request_headers=%s response_headers=%s",
+		r.Operation.Name,
+		r.HTTPRequest.Method,
+		r.HTTPRequest.URL.String(),
+		status,
+		time.Since(r.AttemptTime),
+		r.RetryCount,
+		r.Error,
+		formatHeaders(r.HTTPRequest.Header),
+		respHeaders)
+}
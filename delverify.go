@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var delete_verify string
+var delete_verify_poll, delete_verify_timeout time.Duration
+
+// Deleted objects still seen at the first check, and once -delete-verify-timeout passed
+var delete_late, delete_lingering int64
+
+type pendingDelete struct {
+	bucket string
+	key    string
+	end    int64
+}
+
+// resetDeleteVerify -- start counting afresh for a new DEL test
+func resetDeleteVerify() {
+	atomic.StoreInt64(&delete_late, 0)
+	atomic.StoreInt64(&delete_lingering, 0)
+}
+
+// deleteGone -- whether a deleted object no longer shows, to a HEAD or in a
+// listing starting at its key
+func deleteGone(svc *s3.S3, p *pendingDelete) (bool, error) {
+	if delete_verify == "list" {
+		out, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
+			Bucket:  &p.bucket,
+			Prefix:  &p.key,
+			MaxKeys: aws.Int64(1),
+		})
+		if err != nil {
+			return false, err
+		}
+		return len(out.Contents) == 0 || aws.StringValue(out.Contents[0].Key) != p.key, nil
+	}
+	_, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: &p.bucket, Key: &p.key})
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusNotFound {
+		return true, nil
+	}
+	return false, err
+}
+
+// verifyDeletes -- check the objects a thread deleted until each is gone.
+// The time from the delete to the check that found it gone goes to gone.
+func verifyDeletes(thread_num int, svc *s3.S3, pending []pendingDelete, gone *Stats) {
+	deadline := time.Now().Add(delete_verify_timeout)
+	first := true
+	for len(pending) > 0 {
+		remaining := pending[:0]
+		for _, p := range pending {
+			ok, err := deleteGone(svc, &p)
+			now := time.Now().UnixNano()
+			if err != nil {
+				gone.addSlowDown(thread_num)
+				gone.logError(thread_num, "delete verify", err)
+				remaining = append(remaining, p)
+			} else if ok {
+				gone.addOp(thread_num, 0, now-p.end)
+			} else {
+				remaining = append(remaining, p)
+			}
+		}
+		if first {
+			atomic.AddInt64(&delete_late, int64(len(remaining)))
+			first = false
+		}
+		pending = remaining
+		if len(pending) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(delete_verify_poll)
+	}
+	if len(pending) > 0 {
+		atomic.AddInt64(&delete_lingering, int64(len(pending)))
+		logWarnf("Thread %d still sees %d deleted objects, ie %s/%s", thread_num, len(pending), pending[0].bucket, pending[0].key)
+	}
+	gone.finish(thread_num)
+}
+
+// logDeleteVerify -- report how many deletes were not visible at once
+func logDeleteVerify() {
+	logInfof("Delete verify: %d deleted objects still visible at the first check, %d after %s",
+		atomic.LoadInt64(&delete_late), atomic.LoadInt64(&delete_lingering), delete_verify_timeout)
+}
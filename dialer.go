@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+var dialer_arg string
+var ip_version string
+
+// dialFunc -- how the transport opens its connections
+type dialFunc = func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialers -- the dialers -dialer can name, each made from the argument after
+// the first colon, ie the socket path of "unix:/run/s3.sock"
+var dialers = map[string]func(arg string) (dialFunc, error){
+	"tcp":        tcpDialer,
+	"unix":       unixDialer,
+	"connect-to": connectToDialer,
+}
+
+// registerDialer -- make a dialer available to -dialer as name, from an init
+// function of its own file in this package
+func registerDialer(name string, newDial func(arg string) (dialFunc, error)) {
+	if _, ok := dialers[name]; ok {
+		logFatalf("Dialer %s registered twice", name)
+	}
+	dialers[name] = newDial
+}
+
+// netDialer -- the timeouts of the default transport
+func netDialer() *net.Dialer {
+	return &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+}
+
+// tcpDialer -- "tcp" or "tcp:<source address>", connect to the endpoint of
+// the URL, optionally from one of several local addresses
+func tcpDialer(arg string) (dialFunc, error) {
+	d := netDialer()
+	if arg != "" {
+		ip := net.ParseIP(arg)
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not an IP address", arg)
+		}
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return d.DialContext, nil
+}
+
+// unixDialer -- "unix:<path>", connect to a Unix socket, ie of a local
+// gateway, whatever host the URL names. The URL still sets the Host header
+// and, for https, the name the certificate is checked against.
+func unixDialer(arg string) (dialFunc, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("needs a socket path, ie unix:/run/s3.sock")
+	}
+	d := netDialer()
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", arg)
+	}, nil
+}
+
+// connectToDialer -- "connect-to:<host:port>", connect to another address
+// than the URL's, ie a sidecar in front of the endpoint
+func connectToDialer(arg string) (dialFunc, error) {
+	if _, _, err := net.SplitHostPort(arg); err != nil {
+		return nil, fmt.Errorf("needs a host:port, ie connect-to:127.0.0.1:8080")
+	}
+	d := netDialer()
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, network, arg)
+	}, nil
+}
+
+// makeDialer -- the dial function -dialer selects for the -ip-version, nil
+// to leave the transport's own
+func makeDialer() dialFunc {
+	if dialer_arg == "" && ip_version == "dual" {
+		return nil
+	}
+	name, arg := "tcp", ""
+	if dialer_arg != "" {
+		name, arg, _ = strings.Cut(dialer_arg, ":")
+	}
+	newDial, ok := dialers[name]
+	if !ok {
+		names := make([]string, 0, len(dialers))
+		for n := range dialers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		configFatalf("Invalid -dialer argument %q, must be one of %s", dialer_arg, strings.Join(names, ", "))
+	}
+	dial, err := newDial(arg)
+	if err != nil {
+		configFatalf("Invalid -dialer argument %q: %v", dialer_arg, err)
+	}
+	if ip_version == "dual" {
+		return dial
+	}
+	if name == "unix" {
+		configFatalf("-ip-version %s does not apply to a Unix socket -dialer", ip_version)
+	}
+	return ipVersionDialer(dial, "tcp"+ip_version)
+}
+
+// ipVersionDialer -- dial TCP over one address family only, so the names
+// only resolve to addresses of it and a dual stack endpoint can be
+// benchmarked one family at a time
+func ipVersionDialer(dial dialFunc, family string) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network == "tcp" {
+			network = family
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+var read_buffer_arg string
+var read_buffer int64
+
+// readBuffers -- the buffers GET bodies are drained with, shared by all
+// threads so a download does not allocate one of its own
+var readBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, read_buffer)
+		return &b
+	},
+}
+
+// drainBody -- read a response body to its end and close it. The body is
+// read straight into a pooled -read-buffer rather than through io.Copy,
+// which would pick the small buffer of ioutil.Discard.
+func drainBody(body io.ReadCloser) (int64, error) {
+	defer body.Close()
+	bp := readBuffers.Get().(*[]byte)
+	defer readBuffers.Put(bp)
+	buf := *bp
+	var total int64
+	for {
+		n, err := body.Read(buf)
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
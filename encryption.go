@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var sse_algorithm, sse_kms_key string
+var sse_bucket_key bool
+
+// encryptionConfiguration -- the default encryption applied by the 'e' mode
+func encryptionConfiguration() *s3.ServerSideEncryptionConfiguration {
+	def := &s3.ServerSideEncryptionByDefault{SSEAlgorithm: aws.String(sse_algorithm)}
+	if sse_kms_key != "" {
+		def.KMSMasterKeyID = aws.String(sse_kms_key)
+	}
+	rule := &s3.ServerSideEncryptionRule{ApplyServerSideEncryptionByDefault: def}
+	if sse_bucket_key {
+		rule.BucketKeyEnabled = aws.Bool(true)
+	}
+	return &s3.ServerSideEncryptionConfiguration{Rules: []*s3.ServerSideEncryptionRule{rule}}
+}
+
+// runBucketsEncryption -- set the default encryption of every bucket once,
+// so the PUT tests that follow write encrypted objects
+func runBucketsEncryption(thread_num int, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	conf := encryptionConfiguration()
+
+	for {
+		bucket_num := atomic.AddInt64(&op_counter, 1)
+		if bucket_num >= bucket_count {
+			atomic.AddInt64(&op_counter, -1)
+			break
+		}
+		start := time.Now().UnixNano()
+		_, err := svc.PutBucketEncryption(&s3.PutBucketEncryptionInput{
+			Bucket:                            aws.String(buckets[bucket_num]),
+			ServerSideEncryptionConfiguration: conf,
+		})
+		end := time.Now().UnixNano()
+
+		if err != nil {
+			logFatalf("FATAL: Unable to set the default encryption of bucket %s: %v", buckets[bucket_num], err)
+		}
+		stats.addOp(thread_num, 0, end-start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envName -- the environment variable of a flag, ie HSBENCH_LOG_LEVEL for
+// -log-level with the HSBENCH_ prefix
+func envName(prefix string, flagName string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv -- set the flags not given on the command line from their
+// environment variables. Repeatable flags take one value per line.
+func applyEnv(fs *flag.FlagSet, prefix string) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(prefix, f.Name))
+		if !ok || given[f.Name] || err != nil {
+			return
+		}
+		values := []string{value}
+		switch f.Value.(type) {
+		case *stringListFlag, *headerFlags:
+			values = strings.Split(strings.TrimRight(value, "\n"), "\n")
+		}
+		for _, v := range values {
+			if serr := fs.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", value, envName(prefix, f.Name), serr)
+				return
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var existing_objects bool
+
+// discoverObjects -- list the buckets to index the objects already in them,
+// so object tests can run against data hsbench did not write
+func discoverObjects() {
+	svc := newS3Client()
+	object_index = make([]manifestEntry, 0)
+	for _, bucket := range buckets {
+		err := svc.ListObjectsV2Pages(
+			&s3.ListObjectsV2Input{
+				Bucket:  &bucket,
+				Prefix:  &object_prefix,
+				MaxKeys: &max_keys,
+			},
+			func(p *s3.ListObjectsV2Output, last bool) bool {
+				for _, o := range p.Contents {
+					e := manifestEntry{bucket: bucket, key: *o.Key}
+					if o.Size != nil {
+						e.size = *o.Size
+					}
+					if o.ETag != nil {
+						e.etag = *o.ETag
+					}
+					object_index = append(object_index, e)
+				}
+				return true
+			})
+		if err != nil {
+			logFatalf("Unable to list existing objects in bucket %s: %v", bucket, err)
+		}
+	}
+	if len(object_index) == 0 {
+		logFatal("No existing objects found to run against")
+	}
+	logInfof("Found %d existing objects", len(object_index))
+	if object_count < 0 {
+		object_count = int64(len(object_index))
+	}
+}
+
+// indexKeys -- the indexed keys in a bucket, in listing order
+func indexKeys(bucket string) []string {
+	keys := make([]string, 0)
+	for _, e := range object_index {
+		if e.bucket == bucket {
+			keys = append(keys, e.key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var expire_age time.Duration
+
+type expirePage struct {
+	bucket_num int64
+	token      *string
+}
+
+type expiredObject struct {
+	bucket *string
+	key    *string
+	size   int64
+}
+
+// expireQueue -- the listing pages still to fetch and the expired objects
+// found in them, shared by the expire threads
+type expireQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pages   []expirePage
+	objects []expiredObject
+	// threads currently listing a page, which may find more
+	busy int
+	// set when the cleanup was cut short
+	stopped bool
+}
+
+func newExpireQueue() *expireQueue {
+	q := &expireQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	for b := int64(0); b < bucket_count; b++ {
+		q.pages = append(q.pages, expirePage{b, nil})
+	}
+	return q
+}
+
+// pop -- take an expired object to delete or, when there is none, a page to
+// list. Objects come first so the backlog of deletes stays short. Returns
+// false once the cleanup is complete.
+func (q *expireQueue) pop() (*expiredObject, *expirePage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.objects) == 0 && len(q.pages) == 0 && q.busy > 0 && !q.stopped {
+		q.cond.Wait()
+	}
+	if q.stopped {
+		return nil, nil, false
+	}
+	if n := len(q.objects); n > 0 {
+		o := q.objects[n-1]
+		q.objects = q.objects[:n-1]
+		return &o, nil, true
+	}
+	if n := len(q.pages); n > 0 {
+		p := q.pages[n-1]
+		q.pages = q.pages[:n-1]
+		q.busy++
+		return nil, &p, true
+	}
+	return nil, nil, false
+}
+
+// done -- finish listing a page, queueing the expired objects it held and
+// the page after it
+func (q *expireQueue) done(next *expirePage, found []expiredObject) {
+	q.mu.Lock()
+	if !q.stopped {
+		q.objects = append(q.objects, found...)
+		if next != nil {
+			q.pages = append(q.pages, *next)
+		}
+	}
+	q.busy--
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// stop -- give up on the rest of the cleanup
+func (q *expireQueue) stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.pages = nil
+	q.objects = nil
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// runExpire -- list the buckets and delete the objects last modified more
+// than -expire-age before the test started, like a TTL cleanup job. Listing
+// pages go to lists, the deletes to deletes.
+func runExpire(thread_num int, queue *expireQueue, cutoff time.Time, lists *Stats, deletes *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	for {
+		if phaseOver() {
+			queue.stop()
+			break
+		}
+		obj, page, ok := queue.pop()
+		if !ok {
+			break
+		}
+
+		if page != nil {
+			lists.arrive(thread_num)
+			start := time.Now().UnixNano()
+			out, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
+				Bucket:            &buckets[page.bucket_num],
+				Prefix:            &object_prefix,
+				MaxKeys:           &max_keys,
+				ContinuationToken: page.token,
+			})
+			end := time.Now().UnixNano()
+			if err != nil {
+				queue.done(nil, nil)
+				lists.abort(thread_num, fmt.Sprintf("unable to list %s: %v", buckets[page.bucket_num], err))
+				queue.stop()
+				break
+			}
+			found := make([]expiredObject, 0)
+			for _, o := range out.Contents {
+				if o.LastModified != nil && o.LastModified.Before(cutoff) {
+					found = append(found, expiredObject{&buckets[page.bucket_num], o.Key, *o.Size})
+				}
+			}
+			var next *expirePage
+			if out.IsTruncated != nil && *out.IsTruncated {
+				next = &expirePage{page.bucket_num, out.NextContinuationToken}
+			}
+			lists.addWalked(thread_num, 0, int64(len(out.Contents)))
+			lists.addOp(thread_num, 0, end-start)
+			queue.done(next, found)
+			think(start)
+			continue
+		}
+
+		deletes.arrive(thread_num)
+		start := time.Now().UnixNano()
+		_, err := svc.DeleteObject(&s3.DeleteObjectInput{Bucket: obj.bucket, Key: obj.key})
+		end := time.Now().UnixNano()
+		if err != nil {
+			if deletes.requestFailed(thread_num, &errcnt, "expire delete", err) {
+				queue.stop()
+				break
+			}
+		} else {
+			deletes.addBucketOp(thread_num, *obj.bucket, obj.size, end-start)
+		}
+		think(start)
+	}
+	lists.finish(thread_num)
+	deletes.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var failover_after int
+var failover_recheck time.Duration
+
+// endpoint -- one of the comma separated -u endpoints and its health
+type endpoint struct {
+	url          *url.URL
+	mu           sync.Mutex
+	failures     int
+	firstFailure time.Time
+	down         bool
+	downSince    time.Time
+	// when the last trial request was let through to the endpoint while down
+	trialStart time.Time
+}
+
+var endpoints []*endpoint
+var endpointNext uint64
+
+// FailoverEvent -- an endpoint being marked unhealthy, and what it did to
+// the throughput of the test running at the time
+type FailoverEvent struct {
+	Endpoint    string
+	At          time.Time
+	Failures    int
+	DetectionMs float64
+	// Drop of IO/s below the mean of the intervals before the failover,
+	// and how long until an interval was back within 10% of that mean
+	DipPct     float64
+	DipSeconds float64
+	Recovered  bool
+}
+
+var failoverMu sync.Mutex
+var failoverEvents []FailoverEvent
+
+// parseEndpoints -- split -u into its endpoints, the first one is used for
+// everything that is not routed per request
+func parseEndpoints(arg string) ([]*endpoint, error) {
+	eps := make([]*endpoint, 0)
+	for _, s := range strings.Split(arg, ",") {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("%q is not a URL with a scheme prefix, ie http://", s)
+		}
+		eps = append(eps, &endpoint{url: u})
+	}
+	return eps, nil
+}
+
+// usable -- whether requests may go to the endpoint. Unhealthy endpoints get
+// a single trial request every -failover-recheck, the others keep away from
+// them until it succeeds.
+func (e *endpoint) usable() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.down {
+		return true
+	}
+	now := time.Now()
+	if now.Sub(e.downSince) < failover_recheck || now.Sub(e.trialStart) < failover_recheck {
+		return false
+	}
+	e.trialStart = now
+	return true
+}
+
+// routeRequest -- send the request to the next usable endpoint, round robin.
+// It runs before signing on every attempt, so retries can move elsewhere.
+func routeRequest(r *request.Request) {
+	next := atomic.AddUint64(&endpointNext, 1)
+	e := endpoints[next%uint64(len(endpoints))]
+	for k := uint64(0); k < uint64(len(endpoints)); k++ {
+		if c := endpoints[(next+k)%uint64(len(endpoints))]; c.usable() {
+			e = c
+			break
+		}
+	}
+	r.HTTPRequest.URL.Scheme = e.url.Scheme
+	r.HTTPRequest.URL.Host = e.url.Host
+}
+
+// recordAttempt -- track consecutive failed attempts per endpoint. Transport
+// errors and 5xx responses other than 503 SlowDown count as failures.
+func recordAttempt(r *request.Request) {
+	var e *endpoint
+	for _, c := range endpoints {
+		if c.url.Host == r.HTTPRequest.URL.Host {
+			e = c
+		}
+	}
+	if e == nil {
+		return
+	}
+	failed := r.Error != nil
+	if r.HTTPResponse != nil && r.HTTPResponse.StatusCode >= 500 && r.HTTPResponse.StatusCode != 503 {
+		failed = true
+	}
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !failed {
+		if e.down {
+			logInfof("Endpoint %s healthy again after %.1fs", e.url.Host, now.Sub(e.downSince).Seconds())
+		}
+		e.down = false
+		e.failures = 0
+		return
+	}
+	if e.failures == 0 {
+		e.firstFailure = now
+	}
+	e.failures++
+	if e.down {
+		// A failed trial request, wait another -failover-recheck
+		e.downSince = now
+		return
+	}
+	if e.failures >= failover_after {
+		e.down = true
+		e.downSince = now
+		ev := FailoverEvent{Endpoint: e.url.Host, At: now, Failures: e.failures, DetectionMs: float64(now.Sub(e.firstFailure)) / 1e6}
+		logWarnf("Endpoint %s marked unhealthy after %d consecutive failures, detected in %.0fms", ev.Endpoint, ev.Failures, ev.DetectionMs)
+		failoverMu.Lock()
+		failoverEvents = append(failoverEvents, ev)
+		failoverMu.Unlock()
+	}
+}
+
+// takeFailovers -- the failovers during a finished test, with the depth and
+// duration of the throughput dip each caused worked out from its intervals
+func takeFailovers(stats *Stats, os []OutputStats) []FailoverEvent {
+	failoverMu.Lock()
+	events := make([]FailoverEvent, 0)
+	for _, ev := range failoverEvents {
+		// Leave out failovers between tests, ie during the probe
+		if ev.At.UnixNano() >= stats.startNano {
+			events = append(events, ev)
+		}
+	}
+	failoverEvents = nil
+	failoverMu.Unlock()
+
+	type point struct {
+		startNano int64
+		iops      float64
+	}
+	points := make([]point, 0, len(os))
+	for _, o := range os {
+		i, err := strconv.ParseInt(o.IntervalName, 10, 64)
+		if err != nil || o.Thread != -1 || o.Bucket != "" {
+			continue
+		}
+		points = append(points, point{stats.alignNano + i*stats.intervalNano, o.Iops})
+	}
+
+	for n := range events {
+		ev := &events[n]
+		at := ev.At.UnixNano()
+		baseline, count := 0.0, 0
+		for _, p := range points {
+			if p.startNano+stats.intervalNano <= at {
+				baseline += p.iops
+				count++
+			}
+		}
+		if count == 0 {
+			logInfof("Loop %d %s failover of %s: detected in %.0fms, no intervals before it to measure the dip against",
+				stats.loop, stats.mode, ev.Endpoint, ev.DetectionMs)
+			continue
+		}
+		baseline /= float64(count)
+		low := baseline
+		for _, p := range points {
+			if p.startNano+stats.intervalNano <= at {
+				continue
+			}
+			if p.iops >= 0.9*baseline && p.startNano > at {
+				ev.Recovered = true
+				ev.DipSeconds = float64(p.startNano-at) / 1e9
+				break
+			}
+			low = min(low, p.iops)
+		}
+		if !ev.Recovered {
+			ev.DipSeconds = float64(stats.endNano-at) / 1e9
+		}
+		if baseline > 0 {
+			ev.DipPct = 100 * (1 - low/baseline)
+		}
+		recovery := ""
+		if !ev.Recovered {
+			recovery = " (not recovered)"
+		}
+		logInfof("Loop %d %s failover of %s: detected in %.0fms, IO/s dipped %.0f%% for %.1fs%s",
+			stats.loop, stats.mode, ev.Endpoint, ev.DetectionMs, ev.DipPct, ev.DipSeconds, recovery)
+	}
+	return events
+}
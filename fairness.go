@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// ThreadSpread -- how evenly the operations of a test were spread over its
+// threads. Threads stuck on a slow gateway or connection finish fewer.
+type ThreadSpread struct {
+	MinOps        int64
+	MedianOps     int64
+	MaxOps        int64
+	SlowestThread int
+	FastestThread int
+	// MaxOps over MinOps, 0 when a thread did nothing
+	Ratio float64
+}
+
+// threadSpread -- the spread of the operations over the threads of a test,
+// nil with a single thread or no operations
+func (stats *Stats) threadSpread() *ThreadSpread {
+	if stats.threads < 2 {
+		return nil
+	}
+	order := make([]int, stats.threads)
+	ops := make([]int64, stats.threads)
+	total := int64(0)
+	for t := range stats.threadStats {
+		ts := &stats.threadStats[t]
+		ts.mu.Lock()
+		ops[t] = ts.ops
+		ts.mu.Unlock()
+		order[t] = t
+		total += ops[t]
+	}
+	if total == 0 {
+		return nil
+	}
+	sort.SliceStable(order, func(i, j int) bool { return ops[order[i]] < ops[order[j]] })
+	slowest, fastest := order[0], order[len(order)-1]
+	s := &ThreadSpread{
+		MinOps:        ops[slowest],
+		MedianOps:     ops[order[len(order)/2]],
+		MaxOps:        ops[fastest],
+		SlowestThread: slowest,
+		FastestThread: fastest,
+	}
+	if s.MinOps > 0 {
+		s.Ratio = math.Round(100*float64(s.MaxOps)/float64(s.MinOps)) / 100
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var kill_interval time.Duration
+var kill_fraction float64
+var kill_idle_only bool
+
+// connTracker -- every open connection of the shared transport, so some can
+// be closed under the requests using them
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[*trackedConn]struct{}
+}
+
+var tracker = connTracker{conns: make(map[*trackedConn]struct{})}
+
+type trackedConn struct {
+	net.Conn
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		tracker.mu.Lock()
+		delete(tracker.conns, c)
+		tracker.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// trackingDialer -- wrap a dial function to record the connections it opens
+func trackingDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tc := &trackedConn{Conn: conn}
+		tracker.mu.Lock()
+		tracker.conns[tc] = struct{}{}
+		tracker.mu.Unlock()
+		return tc, nil
+	}
+}
+
+// killConns -- close a random -kill-fraction of the open connections, idle or
+// in the middle of a request. Interrupted requests fail with a connection
+// reset and are retried by the SDK on a new connection.
+func killConns() (int, int) {
+	tracker.mu.Lock()
+	open := make([]*trackedConn, 0, len(tracker.conns))
+	for c := range tracker.conns {
+		open = append(open, c)
+	}
+	tracker.mu.Unlock()
+	fault_rand.shuffle(len(open), func(i, j int) { open[i], open[j] = open[j], open[i] })
+	n := int(float64(len(open))*kill_fraction + 0.5)
+	for _, c := range open[:n] {
+		c.Close()
+	}
+	return n, len(open)
+}
+
+// startKiller -- inject connection failures every -kill-interval, with up to
+// 50% jitter either way so kills do not line up with report intervals
+func startKiller(transport *http.Transport) {
+	go func() {
+		for {
+			jitter := (fault_rand.float64() - 0.5) * float64(kill_interval)
+			time.Sleep(kill_interval + time.Duration(jitter))
+			if kill_idle_only {
+				transport.CloseIdleConnections()
+				logInfof("Fault injection: closed the idle connections")
+				continue
+			}
+			killed, open := killConns()
+			logInfof("Fault injection: closed %d of %d connections", killed, open)
+		}
+	}()
+}
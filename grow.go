@@ -0,0 +1,58 @@
+package main
+
+import (
+	"maps"
+	"strconv"
+)
+
+var grow bool
+
+// grow_step -- the -n every PUT test adds to the namespace with -grow
+var grow_step int64
+
+// grow_objects -- the objects the PUT tests have written so far with -grow
+var grow_objects int64
+
+// phase_tags -- the tags of the rows of the running test, the run's tags
+// and with -grow the objects the buckets held when it started
+var phase_tags map[string]string
+
+// startGrowth -- with -grow, have a PUT test write the next -n objects
+// after those the earlier ones wrote rather than the same ones again, so
+// each loop measures the buckets at a larger size
+func startGrowth(loop int, r rune) {
+	phase_tags = run_tags
+	if !grow {
+		return
+	}
+	if r == 'p' {
+		op_counter = grow_objects - 1
+		if grow_step > -1 {
+			object_count = grow_objects + grow_step
+		}
+		if grow_objects > 0 {
+			logInfof("Loop %d growing the namespace from %d objects", loop, grow_objects)
+		}
+	}
+	phase_tags = maps.Clone(run_tags)
+	if _, ok := run_tags["objects"]; !ok {
+		phase_tags["objects"] = strconv.FormatInt(grow_objects, 10)
+	}
+	if _, ok := run_tags["fill_level"]; !ok && staircase_level != "" {
+		phase_tags["fill_level"] = staircase_level
+	}
+}
+
+// endGrowth -- count the objects a PUT test wrote, or start over once a
+// test removed them
+func endGrowth(r rune) {
+	if !grow {
+		return
+	}
+	switch r {
+	case 'p':
+		grow_objects = op_counter + 1
+	case 'c', 'x', 'd':
+		grow_objects = 0
+	}
+}
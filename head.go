@@ -0,0 +1,25 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// runHead -- the 'h' mode, HEAD requests for the objects, to load the
+// metadata path without moving any data
+func runHead(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	next := func() (int64, bool) {
+		return nextObject(thread_num, loop_objects && duration_secs > -1, true)
+	}
+	runRequests(thread_num, stats, "head", next, func(objnum int64) (string, func() (int64, error)) {
+		bucket, key, _ := objectName(thread_num, objnum, rand)
+		return *bucket, func() (int64, error) {
+			_, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: bucket, Key: &key})
+			return 0, err
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
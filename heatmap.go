@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"strconv"
+)
+
+var heatmap_output string
+
+// heatmapCell -- the number of operations of a test in one interval whose
+// latency fell in one log scale bucket
+type heatmapCell struct {
+	loop     int
+	mode     string
+	interval int64
+	bucket   int
+	count    int64
+}
+
+var heatmapCells []heatmapCell
+
+// heatmapBuckets is the number of latency buckets per doubling
+const heatmapBuckets = 4
+
+// heatmapBucket -- the log scale bucket of a latency, bucket 0 starting at
+// one microsecond
+func heatmapBucket(latNano int64) int {
+	us := float64(latNano) / 1000
+	if us < 1 {
+		return 0
+	}
+	return int(math.Floor(math.Log2(us) * heatmapBuckets))
+}
+
+// heatmapBound -- the lower latency bound of a bucket in milliseconds
+func heatmapBound(bucket int) float64 {
+	return math.Pow(2, float64(bucket)/heatmapBuckets) / 1000
+}
+
+// recordHeatmap -- histogram every interval of a finished test
+func recordHeatmap(stats *Stats) {
+	if heatmap_output == "" {
+		return
+	}
+	for i := int64(0); i <= stats.lastInterval(); i++ {
+		counts := make(map[int]int64)
+		maxBucket := -1
+		for t := range stats.threadStats {
+			ts := &stats.threadStats[t]
+			ts.mu.Lock()
+			if is := ts.at(i); is != nil {
+				for _, lat := range is.latNano {
+					b := heatmapBucket(lat)
+					counts[b]++
+					maxBucket = max(maxBucket, b)
+				}
+			}
+			ts.mu.Unlock()
+		}
+		for b := 0; b <= maxBucket; b++ {
+			if counts[b] > 0 {
+				heatmapCells = append(heatmapCells, heatmapCell{stats.loop, stats.mode, i, b, counts[b]})
+			}
+		}
+	}
+}
+
+// writeHeatmap -- write the latency histograms as interval x latency bucket
+// x count rows, leaving out empty buckets
+func writeHeatmap() {
+	if heatmap_output == "" {
+		return
+	}
+	file, err := os.OpenFile(outputPath(heatmap_output), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		logFatal("Could not open heatmap file for writing: ", err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	w.Write([]string{"Loop", "Mode", "Interval", "Latency From(ms)", "Latency To(ms)", "Count"})
+	for _, c := range heatmapCells {
+		w.Write([]string{
+			strconv.Itoa(c.loop),
+			c.mode,
+			strconv.FormatInt(c.interval, 10),
+			strconv.FormatFloat(heatmapBound(c.bucket), 'g', 4, 64),
+			strconv.FormatFloat(heatmapBound(c.bucket+1), 'g', 4, 64),
+			strconv.FormatInt(c.count, 10)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logFatal("Error writing heatmap: ", err)
+	}
+}
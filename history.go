@@ -0,0 +1,312 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	// Pure Go, so builds with CGO_ENABLED=0 keep -history-db
+	_ "modernc.org/sqlite"
+)
+
+var history_db string
+
+// run_started is recorded as the start of the run in the history
+var run_started = time.Now()
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	started   TEXT NOT NULL,
+	run_id    TEXT NOT NULL,
+	endpoint  TEXT NOT NULL,
+	modes     TEXT NOT NULL,
+	seed      INTEGER NOT NULL,
+	passed    INTEGER NOT NULL,
+	exit_code INTEGER NOT NULL,
+	config    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS run_tags (
+	run   INTEGER NOT NULL REFERENCES runs(id),
+	key   TEXT NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS phases (
+	run     INTEGER NOT NULL REFERENCES runs(id),
+	loop    INTEGER NOT NULL,
+	mode    TEXT NOT NULL,
+	passed  INTEGER NOT NULL,
+	seconds REAL NOT NULL,
+	ops     INTEGER NOT NULL,
+	mbps    REAL NOT NULL,
+	iops    REAL NOT NULL,
+	avg_lat REAL NOT NULL,
+	lat99   REAL NOT NULL,
+	max_lat REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS run_tags_key ON run_tags(key, value);
+CREATE INDEX IF NOT EXISTS phases_run ON phases(run);
+`
+
+// openHistory -- open the history database, creating its tables if needed
+func openHistory(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// recordHistory -- add the configuration of the run and the result of every
+// test it ran to the -history-db
+func recordHistory() {
+	if history_db == "" {
+		return
+	}
+	db, err := openHistory(history_db)
+	if err != nil {
+		logFatal("Error opening -history-db: ", err)
+	}
+	defer db.Close()
+	config, err := json.Marshal(runConfig)
+	if err != nil {
+		logFatal("Error marshaling the run configuration: ", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logFatal("Error recording the run history: ", err)
+	}
+	res, err := tx.Exec(`INSERT INTO runs (started, run_id, endpoint, modes, seed, passed, exit_code, config)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		run_started.UTC().Format(time.RFC3339), run_id, url_host, modes, seed, exit_code == exitOK, exit_code, string(config))
+	var run int64
+	if err == nil {
+		run, err = res.LastInsertId()
+	}
+	for _, k := range tagKeys(run_tags) {
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO run_tags (run, key, value) VALUES (?, ?, ?)`, run, k, run_tags[k])
+		}
+	}
+	for _, p := range phases {
+		if err == nil {
+			t := p.Total
+			_, err = tx.Exec(`INSERT INTO phases (run, loop, mode, passed, seconds, ops, mbps, iops, avg_lat, lat99, max_lat)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				run, p.Loop, p.Mode, p.Passed, t.Seconds, t.Ops, t.Mbps, t.Iops, t.AvgLat, t.Lat99, t.MaxLat)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		logFatal("Error recording the run history: ", err)
+	}
+	logInfof("Recorded run %d in %s", run, history_db)
+}
+
+// historyPhase -- a test of a past run as read back from the history
+type historyPhase struct {
+	run                               int64
+	started, runID, endpoint, tags    string
+	loop                              int
+	mode                              string
+	passed                            bool
+	ops                               int64
+	mbps, iops, avgLat, lat99, maxLat float64
+}
+
+// historyTime -- a -since or -until argument, a date or an RFC 3339 time
+func historyTime(s string) (string, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format(time.RFC3339), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return "", fmt.Errorf("invalid time %q, must be 2006-01-02 or RFC 3339", s)
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+// runHistory -- list or compare the runs recorded in a -history-db
+func runHistory(args []string) int {
+	var path, endpoint, mode, since, until, compare string
+	var tags stringListFlag
+	var limit int
+	hflag := flag.NewFlagSet("history", flag.ContinueOnError)
+	hflag.StringVar(&path, "db", "hsbench.db", "History database written by -history-db")
+	hflag.Var(&tags, "tag", "Only runs with this key=value tag, can be repeated")
+	hflag.StringVar(&endpoint, "endpoint", "", "Only runs against an endpoint containing this text")
+	hflag.StringVar(&mode, "mode", "", "Only tests of this mode, ie PUT")
+	hflag.StringVar(&since, "since", "", "Only runs started at or after this date or RFC 3339 time")
+	hflag.StringVar(&until, "until", "", "Only runs started before this date or RFC 3339 time")
+	hflag.IntVar(&limit, "limit", 20, "Maximum number of runs listed, the latest ones")
+	hflag.StringVar(&compare, "compare", "", "Comma separated run numbers to compare test by test against the first")
+	hflag.Usage = func() {
+		fmt.Fprintf(hflag.Output(), "\nUSAGE: %s history [OPTIONS]\n\n", os.Args[0])
+		fmt.Fprintf(hflag.Output(), "OPTIONS:\n")
+		hflag.PrintDefaults()
+	}
+	if err := hflag.Parse(args); err != nil {
+		return exitConfigError
+	}
+	if err := applyEnv(hflag, "HSBENCH_HISTORY_"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+
+	// Filters on the runs, then on their tests
+	where := []string{"1=1"}
+	var params []interface{}
+	tagArgs, err := parseTags(tags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -tag argument: %v\n", err)
+		return exitConfigError
+	}
+	for _, k := range tagKeys(tagArgs) {
+		where = append(where, "r.id IN (SELECT run FROM run_tags WHERE key = ? AND value = ?)")
+		params = append(params, k, tagArgs[k])
+	}
+	if endpoint != "" {
+		where = append(where, "instr(r.endpoint, ?) > 0")
+		params = append(params, endpoint)
+	}
+	for _, bound := range []struct{ arg, op string }{{since, ">="}, {until, "<"}} {
+		if bound.arg == "" {
+			continue
+		}
+		t, err := historyTime(bound.arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitConfigError
+		}
+		where = append(where, "r.started "+bound.op+" ?")
+		params = append(params, t)
+	}
+	var runs []int64
+	if compare != "" {
+		for _, s := range strings.Split(compare, ",") {
+			run, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid -compare argument %q, must be run numbers\n", compare)
+				return exitConfigError
+			}
+			runs = append(runs, run)
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?,", len(runs)), ",")
+		where = append(where, "r.id IN ("+marks+")")
+		for _, run := range runs {
+			params = append(params, run)
+		}
+		limit = -1
+	}
+	params = append(params, limit)
+	phaseWhere := "1=1"
+	if mode != "" {
+		phaseWhere = "p.mode = ?"
+		params = append(params, mode)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not open history database: %v\n", err)
+		return exitConfigError
+	}
+	db, err := openHistory(path)
+	if err != nil {
+		logFatal("Error opening history database: ", err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT r.id, r.started, r.run_id, r.endpoint,
+		(SELECT group_concat(key || '=' || value, ';') FROM run_tags WHERE run = r.id),
+		p.loop, p.mode, p.passed, p.ops, p.mbps, p.iops, p.avg_lat, p.lat99, p.max_lat
+		FROM runs r JOIN phases p ON p.run = r.id
+		WHERE r.id IN (SELECT r.id FROM runs r WHERE `+strings.Join(where, " AND ")+` ORDER BY r.id DESC LIMIT ?)
+		AND `+phaseWhere+`
+		ORDER BY r.id, p.rowid`, params...)
+	if err != nil {
+		logFatal("Error querying history database: ", err)
+	}
+	defer rows.Close()
+	var found []historyPhase
+	for rows.Next() {
+		var p historyPhase
+		var tags sql.NullString
+		if err := rows.Scan(&p.run, &p.started, &p.runID, &p.endpoint, &tags, &p.loop, &p.mode, &p.passed,
+			&p.ops, &p.mbps, &p.iops, &p.avgLat, &p.lat99, &p.maxLat); err != nil {
+			logFatal("Error reading history database: ", err)
+		}
+		p.tags = tags.String
+		found = append(found, p)
+	}
+	if err := rows.Err(); err != nil {
+		logFatal("Error reading history database: ", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if compare != "" {
+		compareHistory(w, runs, found)
+	} else {
+		fmt.Fprintln(w, "RUN\tSTARTED\tRUN ID\tENDPOINT\tTAGS\tLOOP\tMODE\tOPS\tMB/s\tIO/s\tAVG(ms)\t99%(ms)\tMAX(ms)\tPASSED")
+		for _, p := range found {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%t\n",
+				p.run, p.started, p.runID, p.endpoint, p.tags, p.loop, p.mode, p.ops, p.mbps, p.iops, p.avgLat, p.lat99, p.maxLat, p.passed)
+		}
+	}
+	w.Flush()
+	return exitOK
+}
+
+// compareHistory -- print the tests of the given runs side by side, with the
+// change of every run from the first in percent
+func compareHistory(w *tabwriter.Writer, runs []int64, found []historyPhase) {
+	type testKey struct {
+		loop int
+		mode string
+	}
+	var order []testKey
+	byRun := make(map[int64]map[testKey]historyPhase)
+	for _, p := range found {
+		k := testKey{p.loop, p.mode}
+		if byRun[p.run] == nil {
+			byRun[p.run] = make(map[testKey]historyPhase)
+		}
+		if _, seen := byRun[runs[0]][k]; !seen && p.run == runs[0] {
+			order = append(order, k)
+		}
+		byRun[p.run][k] = p
+	}
+	var current int64
+	change := func(v, base float64) string {
+		if base == 0 || current == runs[0] {
+			return ""
+		}
+		return fmt.Sprintf(" (%+.1f%%)", (v-base)/base*100)
+	}
+	fmt.Fprintln(w, "LOOP\tMODE\tRUN\tMB/s\tIO/s\t99%(ms)")
+	for _, k := range order {
+		base := byRun[runs[0]][k]
+		for _, run := range runs {
+			current = run
+			p, ok := byRun[run][k]
+			if !ok {
+				fmt.Fprintf(w, "%d\t%s\t%d\t-\t-\t-\n", k.loop, k.mode, run)
+				continue
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%.2f%s\t%.2f%s\t%.2f%s\n", k.loop, k.mode, run,
+				p.mbps, change(p.mbps, base.mbps), p.iops, change(p.iops, base.iops), p.lat99, change(p.lat99, base.lat99))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+var pre_phase_cmd, post_phase_cmd string
+
+// runPhaseHook -- run -pre-phase-cmd or -post-phase-cmd around the test of
+// the modes in phase, ie "p", or "pg" for a PUT test with -overlap. The
+// command gets the hook, phase, loop and run ID in HSBENCH_HOOK,
+// HSBENCH_PHASE, HSBENCH_LOOP and HSBENCH_RUN_ID.
+func runPhaseHook(hook string, command string, loop int, phase string) error {
+	if command == "" {
+		return nil
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"HSBENCH_HOOK="+hook,
+		"HSBENCH_PHASE="+phase,
+		fmt.Sprintf("HSBENCH_LOOP=%d", loop),
+		"HSBENCH_RUN_ID="+run_id)
+	// Interleave the output with the log
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", command, err)
+	}
+	logDebugf("Loop %d %s-phase command for %s took %s", loop, hook, phase, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// beforePhase -- run -pre-phase-cmd, stopping the run when it fails since
+// the test would not run under the conditions it was meant to
+func beforePhase(loop int, phase string) {
+	if err := runPhaseHook("pre", pre_phase_cmd, loop, phase); err != nil {
+		logFatalf("Pre-phase command failed: %v", err)
+	}
+}
+
+// afterPhase -- run -post-phase-cmd, only warning when it fails so the
+// results of the test are still written
+func afterPhase(loop int, phase string) {
+	if err := runPhaseHook("post", post_phase_cmd, loop, phase); err != nil {
+		logWarnf("Post-phase command failed: %v", err)
+	}
+}
@@ -5,20 +5,15 @@
 package main
 
 import (
-	"bytes"
 	"crypto/hmac"
-	"crypto/md5"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"math"
-	"math/rand"
 	"net/http"
 	"os"
 	"sort"
@@ -26,21 +21,24 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"testing"
 	"time"
 
 	"code.cloudfoundry.org/bytefmt"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 // Global variables
 var access_key, secret_key, url_host, bucket_prefix, object_prefix, region, modes, output, json_output, sizeArg string
 var buckets []string
 var duration_secs, threads, loops int
-var object_data []byte
-var object_data_md5 string
 var max_keys, running_threads, bucket_count, object_count, object_size, op_counter int64
 var object_count_flag bool
 var endtime time.Time
@@ -49,6 +47,23 @@ var zero_object_data bool
 var force_http1, randomize_suffix bool
 var randomize_seed int64
 var loop_objects bool
+var max_conns, max_idle_conns int
+var disable_keepalive bool
+var request_headers headerFlags
+var content_types, cache_controls, content_dispositions stringListFlag
+var cond_header string
+var output_detail string
+var select_format, select_expr string
+var checksum_algorithm string
+var content_md5 bool
+var policy_file, bucket_policy, object_acl string
+var notify_topic string
+var restore_days int64
+var restore_tier string
+var restore_poll, restore_timeout float64
+var notify_events stringListFlag
+
+var list_partitions int64
 
 var listMu sync.Mutex
 var listContinuationToken []*string
@@ -104,8 +119,41 @@ type IntervalStats struct {
 	mode         string
 	bytes        int64
 	slowdowns    int64
+	notModified  int64
+	scannedBytes int64
+	throttleNano int64
+	cacheHits    int64
+	cacheMisses  int64
+	walkedDirs   int64
+	walkedKeys   int64
+	conflicts    int64
+	quotaRejects int64
+	corrupt      int64
 	intervalNano int64
 	latNano      []int64
+	busyNano     int64 // time operations were in flight, spread over threads
+	maxGapNano   int64 // longest stretch a thread had no operation in flight
+	threads      int
+	startNano    int64 // wall clock time the stats begin at, 0 if unknown
+}
+
+// merge -- add the counters and latencies of o to is
+func (is *IntervalStats) merge(o *IntervalStats) {
+	is.bytes += o.bytes
+	is.slowdowns += o.slowdowns
+	is.notModified += o.notModified
+	is.scannedBytes += o.scannedBytes
+	is.throttleNano += o.throttleNano
+	is.cacheHits += o.cacheHits
+	is.cacheMisses += o.cacheMisses
+	is.walkedDirs += o.walkedDirs
+	is.walkedKeys += o.walkedKeys
+	is.conflicts += o.conflicts
+	is.quotaRejects += o.quotaRejects
+	is.corrupt += o.corrupt
+	is.latNano = append(is.latNano, o.latNano...)
+	is.busyNano += o.busyNano
+	is.maxGapNano = max(is.maxGapNano, o.maxGapNano)
 }
 
 func (is *IntervalStats) makeOutputStats() OutputStats {
@@ -141,10 +189,20 @@ func (is *IntervalStats) makeOutputStats() OutputStats {
 	seconds := float64(is.intervalNano) / 1000000000
 	mbps := float64(is.bytes) / seconds / bytefmt.MEGABYTE
 	iops := float64(ops) / seconds
+	var start, end time.Time
+	if is.startNano > 0 {
+		start = time.Unix(0, is.startNano).UTC()
+		end = time.Unix(0, is.startNano+is.intervalNano).UTC()
+	}
+	idle := float64(0)
+	if is.threads > 0 && is.intervalNano > 0 {
+		idle = math.Max(0, 100*(1-float64(is.busyNano)/float64(int64(is.threads)*is.intervalNano)))
+	}
 
 	return OutputStats{
 		is.loop,
 		is.name,
+		-1,
 		seconds,
 		is.mode,
 		ops,
@@ -158,12 +216,32 @@ func (is *IntervalStats) makeOutputStats() OutputStats {
 		Lat75,
 		Lat50,
 		maxLat,
-		is.slowdowns}
+		is.slowdowns,
+		is.notModified,
+		is.scannedBytes,
+		float64(is.throttleNano) / 1000000000,
+		0,
+		is.cacheHits,
+		is.cacheMisses,
+		float64(is.walkedDirs) / seconds,
+		float64(is.walkedKeys) / seconds,
+		is.conflicts,
+		"",
+		is.quotaRejects,
+		run_id,
+		phase_tags,
+		is.corrupt,
+		idle,
+		float64(is.maxGapNano) / 1000000,
+		start,
+		end,
+		sloResults(is.mode, is.latNano, is.slowdowns)}
 }
 
 type OutputStats struct {
 	Loop         int
 	IntervalName string
+	Thread       int
 	Seconds      float64
 	Mode         string
 	Ops          int
@@ -178,11 +256,67 @@ type OutputStats struct {
 	Lat50        float64
 	MaxLat       float64
 	Slowdowns    int64
+	NotModified  int64
+	ScannedBytes int64
+	Throttled    float64
+	TargetRate   float64
+	CacheHits    int64
+	CacheMisses  int64
+	Dirps        float64
+	Keyps        float64
+	Conflicts    int64
+	Bucket       string
+	QuotaRejects int64
+	RunID        string
+	Tags         map[string]string
+	Corrupt      int64
+	Idle         float64
+	MaxGap       float64
+	Start        time.Time
+	End          time.Time
+	SLOs         []SLOResult `json:",omitempty"`
 }
 
 func (o *OutputStats) log() {
-	log.Printf(
-		"Loop: %d, Int: %s, Dur(s): %.1f, Mode: %s, Ops: %d, MB/s: %.2f, IO/s: %.0f, Lat(ms): [ min: %.1f, avg: %.1f, 99%%: %.1f, 95%%: %.1f, 90%%: %.1f, 75%%: %.1f, 50%%: %.1f, max: %.1f ], Slowdowns: %d",
+	if log_format == "json" {
+		slog.Info("stats", "stats", *o)
+		return
+	}
+	// Rates and counters only some tests or options produce
+	rates := ""
+	if load_profile != nil {
+		rates += fmt.Sprintf(", Target IO/s: %.0f", o.TargetRate)
+	}
+	if o.Mode == "WALK" {
+		rates += fmt.Sprintf(", Dirs/s: %.0f, Keys/s: %.0f", o.Dirps, o.Keyps)
+	}
+	extra := ""
+	if o.Bucket != "" {
+		extra += fmt.Sprintf(", Bucket: %s", o.Bucket)
+	}
+	if o.CacheHits+o.CacheMisses > 0 {
+		extra += fmt.Sprintf(", Cache hits: %.1f%%", 100*float64(o.CacheHits)/float64(o.CacheHits+o.CacheMisses))
+	}
+	if o.Mode == "CONTEND" {
+		extra += fmt.Sprintf(", Conflicts: %d", o.Conflicts)
+	}
+	if o.Mode == "VERIFY" || o.Corrupt > 0 {
+		extra += fmt.Sprintf(", Corrupt: %d", o.Corrupt)
+	}
+	if quotaDeclared() {
+		extra += fmt.Sprintf(", Quota rejects: %d", o.QuotaRejects)
+	}
+	if o.RunID != "" {
+		extra += fmt.Sprintf(", Run: %s", o.RunID)
+	}
+	if len(o.Tags) > 0 {
+		extra += fmt.Sprintf(", Tags: %s", formatTags(o.Tags))
+	}
+	if o.Bucket == "" {
+		extra += fmt.Sprintf(", Idle: %.1f%%, Max gap(ms): %.1f", o.Idle, o.MaxGap)
+	}
+	logInfof(
+		"Loop: %d, Int: %s, Dur(s): %.1f, Mode: %s, Ops: %d, MB/s: %.2f, IO/s: %.0f%s, Lat(ms): [ min: %.1f, avg: %.1f, 99%%: %.1f, 95%%: %.1f, 90%%: %.1f, 75%%: %.1f, 50%%: %.1f, max: %.1f ], Slowdowns: %d, NotModified: %d, Scanned: %s, Throttled(s): %.1f%s",
 		o.Loop,
 		o.IntervalName,
 		o.Seconds,
@@ -190,6 +324,7 @@ func (o *OutputStats) log() {
 		o.Ops,
 		o.Mbps,
 		o.Iops,
+		rates,
 		o.MinLat,
 		o.AvgLat,
 		o.Lat99,
@@ -198,12 +333,19 @@ func (o *OutputStats) log() {
 		o.Lat75,
 		o.Lat50,
 		o.MaxLat,
-		o.Slowdowns)
+		o.Slowdowns,
+		o.NotModified,
+		bytefmt.ByteSize(uint64(o.ScannedBytes)),
+		o.Throttled,
+		extra)
+	if o.Bucket == "" {
+		o.logSLOs()
+	}
 }
 
 func (o *OutputStats) csv_header(w *csv.Writer) {
 	if w == nil {
-		log.Fatal("OutputStats passed nil CSV writer")
+		logFatal("OutputStats passed nil CSV writer")
 	}
 
 	s := []string{
@@ -221,16 +363,35 @@ func (o *OutputStats) csv_header(w *csv.Writer) {
 		"75% Latency(ms)",
 		"50% Latency(ms)",
 		"Max Latency(ms)",
-		"Slowdowns"}
+		"Slowdowns",
+		"Not Modified",
+		"Scanned Bytes",
+		"Throttled(s)",
+		"Target IO/s",
+		"Cache Hits",
+		"Cache Misses",
+		"Dirs/s",
+		"Keys/s",
+		"Conflicts",
+		"Bucket",
+		"Quota Rejects",
+		"Run ID",
+		"Tags",
+		"Corrupt",
+		"Idle %",
+		"Max Gap(ms)",
+		"Start",
+		"End",
+		"Thread"}
 
 	if err := w.Write(s); err != nil {
-		log.Fatal("Error writing to CSV writer: ", err)
+		logFatal("Error writing to CSV writer: ", err)
 	}
 }
 
 func (o *OutputStats) csv(w *csv.Writer) {
 	if w == nil {
-		log.Fatal("OutputStats Passed nil csv writer")
+		logFatal("OutputStats Passed nil csv writer")
 	}
 
 	s := []string{
@@ -249,63 +410,91 @@ func (o *OutputStats) csv(w *csv.Writer) {
 		strconv.FormatFloat(o.Lat75, 'f', 2, 64),
 		strconv.FormatFloat(o.Lat50, 'f', 2, 64),
 		strconv.FormatFloat(o.MaxLat, 'f', 2, 64),
-		strconv.FormatInt(o.Slowdowns, 10)}
+		strconv.FormatInt(o.Slowdowns, 10),
+		strconv.FormatInt(o.NotModified, 10),
+		strconv.FormatInt(o.ScannedBytes, 10),
+		strconv.FormatFloat(o.Throttled, 'f', 2, 64),
+		strconv.FormatFloat(o.TargetRate, 'f', 2, 64),
+		strconv.FormatInt(o.CacheHits, 10),
+		strconv.FormatInt(o.CacheMisses, 10),
+		strconv.FormatFloat(o.Dirps, 'f', 2, 64),
+		strconv.FormatFloat(o.Keyps, 'f', 2, 64),
+		strconv.FormatInt(o.Conflicts, 10),
+		o.Bucket,
+		strconv.FormatInt(o.QuotaRejects, 10),
+		o.RunID,
+		formatTags(o.Tags),
+		strconv.FormatInt(o.Corrupt, 10),
+		strconv.FormatFloat(o.Idle, 'f', 2, 64),
+		strconv.FormatFloat(o.MaxGap, 'f', 2, 64),
+		formatWallClock(o.Start),
+		formatWallClock(o.End),
+		strconv.Itoa(o.Thread)}
 
 	if err := w.Write(s); err != nil {
-		log.Fatal("Error writing to CSV writer: ", err)
+		logFatal("Error writing to CSV writer: ", err)
 	}
 }
 
 func (o *OutputStats) json(jfile *os.File) {
 	if jfile == nil {
-		log.Fatal("OutputStats passed nil JSON file")
+		logFatal("OutputStats passed nil JSON file")
 	}
 	jdata, err := json.Marshal(o)
 	if err != nil {
-		log.Fatal("Error marshaling JSON: ", err)
+		logFatal("Error marshaling JSON: ", err)
 	}
-	log.Println(string(jdata))
+	logInfof("%s", jdata)
 	_, err = jfile.WriteString(string(jdata) + "\n")
 	if err != nil {
-		log.Fatal("Error writing to JSON file: ", err)
+		logFatal("Error writing to JSON file: ", err)
 	}
 }
 
 type ThreadStats struct {
-	start       int64
-	curInterval int64
-	intervals   []IntervalStats
+	// guards intervals, which the collector reads while the thread writes
+	mu        sync.Mutex
+	intervals []IntervalStats
+	// consecutive throttles, only touched by the owning thread
+	backoffs int
+	// whole test stats per bucket name, for -output-detail bucket
+	buckets map[string]*IntervalStats
+	// the interval intervals[0] holds, above 0 once a soak test dropped some
+	base int64
+	// when the thread's last operation ended, for the gaps between them
+	lastEndNano int64
+	// operations and bytes of the whole test, kept apart from the intervals
+	// a soak test drops
+	ops   int64
+	bytes int64
 }
 
-func makeThreadStats(s int64, loop int, mode string, intervalNano int64) ThreadStats {
-	ts := ThreadStats{s, 0, []IntervalStats{}}
-	ts.intervals = append(ts.intervals, IntervalStats{loop, "0", mode, 0, 0, intervalNano, []int64{}})
-	return ts
+// interval -- return the stats for interval i, growing the slice as needed.
+// The caller must hold ts.mu.
+func (ts *ThreadStats) interval(i int64) *IntervalStats {
+	i = max(i, ts.base)
+	for int64(len(ts.intervals))+ts.base <= i {
+		ts.intervals = append(ts.intervals, IntervalStats{})
+	}
+	return &ts.intervals[i-ts.base]
 }
 
-func (ts *ThreadStats) updateIntervals(loop int, mode string, intervalNano int64) int64 {
-	// Interval statistics disabled, so just return the current interval
-	if intervalNano < 0 {
-		return ts.curInterval
-	}
-	for ts.start+intervalNano*(ts.curInterval+1) < time.Now().UnixNano() {
-		ts.curInterval++
-		ts.intervals = append(
-			ts.intervals,
-			IntervalStats{
-				loop,
-				strconv.FormatInt(ts.curInterval, 10),
-				mode,
-				0,
-				0,
-				intervalNano,
-				[]int64{}})
-	}
-	return ts.curInterval
+// at -- return the stats for interval i, or nil if there are none.
+// The caller must hold ts.mu.
+func (ts *ThreadStats) at(i int64) *IntervalStats {
+	if i < ts.base || i >= ts.base+int64(len(ts.intervals)) {
+		return nil
+	}
+	return &ts.intervals[i-ts.base]
 }
 
-func (ts *ThreadStats) finish() {
-	ts.curInterval = -1
+// trim -- drop the stats of the intervals before i.
+// The caller must hold ts.mu.
+func (ts *ThreadStats) trim(i int64) {
+	n := min(max(i-ts.base, 0), int64(len(ts.intervals)))
+	clear(ts.intervals[:n])
+	ts.intervals = ts.intervals[n:]
+	ts.base += n
 }
 
 type Stats struct {
@@ -317,200 +506,361 @@ type Stats struct {
 	mode string
 	// start time in nanoseconds
 	startNano int64
-	// end time in nanoseconds
+	// wall clock boundary the intervals are aligned to, at or before startNano
+	alignNano int64
+	// end time in nanoseconds, set atomically by the last thread to finish
 	endNano int64
 	// Duration in nanoseconds for each interval
 	intervalNano int64
 	// Per-thread statistics
 	threadStats []ThreadStats
-	// a map of per-interval thread completion counters
-	intervalCompletions sync.Map
 	// a counter of how many threads have finished updating stats entirely
 	completions int32
+	// a counter of how many threads gave up before finishing their work
+	aborted int32
+	// the next interval the collector will log, set atomically before it
+	// reads an interval so threads stop adding busy time to it
+	flushed int64
+	// closed when all threads have finished
+	done chan struct{}
+	// closed once the collector has flushed the final interval
+	collected chan struct{}
+	// guards nextNano, the next open-loop arrival when running a -profile
+	arrivalMu sync.Mutex
+	nextNano  int64
+	// rollups of a -soak-rotate test, only touched by the collector
+	soakState *soakState
+	// what RGW counted in the buckets around the test, with -rgw-stats
+	server *ServerStats
 }
 
-func makeStats(loop int, mode string, threads int, intervalNano int64) Stats {
+func makeStats(loop int, mode string, threads int, intervalNano int64) *Stats {
 	start := time.Now().UnixNano()
-	s := Stats{threads, loop, mode, start, 0, intervalNano, []ThreadStats{}, sync.Map{}, 0}
-	for i := 0; i < threads; i++ {
-		s.threadStats = append(s.threadStats, makeThreadStats(start, s.loop, s.mode, s.intervalNano))
-		s.updateIntervals(i)
+	s := &Stats{
+		threads:      threads,
+		loop:         loop,
+		mode:         mode,
+		startNano:    start,
+		alignNano:    start,
+		intervalNano: intervalNano,
+		threadStats:  make([]ThreadStats, threads),
+		done:         make(chan struct{}),
+		collected:    make(chan struct{}),
 	}
+	if intervalNano > 0 {
+		// Align to wall clock boundaries so every thread, and every client
+		// started with the same interval, reports the same time slices.
+		s.alignNano = start - start%intervalNano
+	}
+	if soak_rotate > 0 {
+		s.soakState = &soakState{summary: s.newSoakRollup("SUMMARY"), summaryFrom: start, total: s.newSoakRollup("TOTAL")}
+	}
+	go s.collect()
 	return s
 }
 
-func (stats *Stats) makeOutputStats(i int64) (OutputStats, bool) {
-	// Check bounds first
-	if stats.intervalNano < 0 || i < 0 {
-		return OutputStats{}, false
+// newIntervalStats -- empty stats for this test, to merge thread stats into
+func (stats *Stats) newIntervalStats(name string, intervalNano int64) IntervalStats {
+	return IntervalStats{loop: stats.loop, name: name, mode: stats.mode, intervalNano: intervalNano, latNano: []int64{}, threads: stats.threads}
+}
+
+// intervalOf -- return the interval a timestamp falls in
+func (stats *Stats) intervalOf(nano int64) int64 {
+	if stats.intervalNano <= 0 {
+		return 0
 	}
-	// Not safe to log if not all writers have completed.
-	value, ok := stats.intervalCompletions.Load(i)
-	if !ok {
-		return OutputStats{}, false
+	return (nano - stats.alignNano) / stats.intervalNano
+}
+
+// lastInterval -- return the interval the test ended in
+func (stats *Stats) lastInterval() int64 {
+	// An end exactly on a boundary belongs to the interval before it
+	return stats.intervalOf(max(atomic.LoadInt64(&stats.endNano)-1, stats.startNano))
+}
+
+// intervalStart -- return the time the test entered interval i
+func (stats *Stats) intervalStart(i int64) int64 {
+	return max(stats.alignNano+i*stats.intervalNano, stats.startNano)
+}
+
+// intervalDuration -- return the part of interval i that the test ran for
+func (stats *Stats) intervalDuration(i int64) int64 {
+	begin := stats.intervalStart(i)
+	end := stats.alignNano + (i+1)*stats.intervalNano
+	// The collector's timer gets here while the last thread may be finishing
+	if endNano := atomic.LoadInt64(&stats.endNano); endNano > 0 {
+		end = min(end, endNano)
 	}
-	cp, ok := value.(*int32)
-	if !ok {
-		return OutputStats{}, false
+	return end - begin
+}
+
+// collect -- log each interval once its wall clock boundary has passed, and
+// flush whatever is left once all threads have finished.
+func (stats *Stats) collect() {
+	defer close(stats.collected)
+	if stats.intervalNano <= 0 {
+		<-stats.done
+		return
 	}
-	count := atomic.LoadInt32(cp)
-	if count < int32(stats.threads) {
-		return OutputStats{}, false
+	for {
+		boundary := stats.alignNano + (stats.flushed+1)*stats.intervalNano
+		timer := time.NewTimer(time.Duration(boundary - time.Now().UnixNano()))
+		select {
+		case <-timer.C:
+			stats.flush(stats.intervalOf(time.Now().UnixNano()))
+		case <-stats.done:
+			timer.Stop()
+			stats.flush(stats.lastInterval() + 1)
+			return
+		}
+	}
+}
+
+// flush -- log all intervals before last that have not been logged yet
+func (stats *Stats) flush(last int64) {
+	for stats.flushed < last {
+		i := stats.flushed
+		atomic.StoreInt64(&stats.flushed, i+1)
+		if o, ok := stats.makeOutputStats(i); ok {
+			o.log()
+			if stats.soakState != nil {
+				stats.soak(i, o)
+			}
+		}
 	}
+}
 
-	bytes := int64(0)
-	ops := int64(0)
-	slowdowns := int64(0)
+func (stats *Stats) makeOutputStats(i int64) (OutputStats, bool) {
+	// Check bounds first
+	if stats.intervalNano <= 0 || i < 0 {
+		return OutputStats{}, false
+	}
 
+	is := stats.newIntervalStats(strconv.FormatInt(i, 10), stats.intervalDuration(i))
+	is.startNano = stats.intervalStart(i)
 	for t := 0; t < stats.threads; t++ {
-		bytes += stats.threadStats[t].intervals[i].bytes
-		ops += int64(len(stats.threadStats[t].intervals[i].latNano))
-		slowdowns += stats.threadStats[t].intervals[i].slowdowns
+		ts := &stats.threadStats[t]
+		ts.mu.Lock()
+		if tis := ts.at(i); tis != nil {
+			is.merge(tis)
+		}
+		ts.mu.Unlock()
 	}
-	// Aggregate the per-thread Latency slice
-	tmpLat := make([]int64, ops)
-	var c int
-	for t := 0; t < stats.threads; t++ {
-		c += copy(tmpLat[c:], stats.threadStats[t].intervals[i].latNano)
+	sort.Slice(is.latNano, func(i, j int) bool { return is.latNano[i] < is.latNano[j] })
+	o := is.makeOutputStats()
+	o.TargetRate = stats.intervalTargetRate(i)
+	return o, true
+}
+
+// intervalTargetRate -- the mean offered rate over the part of interval i the test ran for
+func (stats *Stats) intervalTargetRate(i int64) float64 {
+	begin := stats.intervalStart(i)
+	return stats.targetRate(begin, begin+stats.intervalDuration(i))
+}
+
+// makeThreadOutputStats -- stats for a single thread in interval i
+func (stats *Stats) makeThreadOutputStats(i int64, t int) OutputStats {
+	is := stats.newIntervalStats(strconv.FormatInt(i, 10), stats.intervalDuration(i))
+	is.startNano = stats.intervalStart(i)
+	ts := &stats.threadStats[t]
+	ts.mu.Lock()
+	if tis := ts.at(i); tis != nil {
+		is.merge(tis)
 	}
-	sort.Slice(tmpLat, func(i, j int) bool { return tmpLat[i] < tmpLat[j] })
-	is := IntervalStats{stats.loop, strconv.FormatInt(i, 10), stats.mode, bytes, slowdowns, stats.intervalNano, tmpLat}
-	return is.makeOutputStats(), true
+	ts.mu.Unlock()
+	is.threads = 1
+	sort.Slice(is.latNano, func(i, j int) bool { return is.latNano[i] < is.latNano[j] })
+	o := is.makeOutputStats()
+	o.Thread = t
+	o.TargetRate = stats.intervalTargetRate(i) / float64(stats.threads)
+	return o
+}
+
+// collectOutputStats -- gather every interval followed by the total once the
+// collector has flushed the final interval
+func (stats *Stats) collectOutputStats() []OutputStats {
+	if stats.soakState != nil {
+		return stats.soakOutputStats()
+	}
+	<-stats.collected
+	os := make([]OutputStats, 0)
+	for i := int64(0); i <= stats.lastInterval(); i++ {
+		if o, ok := stats.makeOutputStats(i); ok {
+			os = append(os, o)
+		} else {
+			break
+		}
+		if output_detail == "thread" {
+			for t := 0; t < stats.threads; t++ {
+				os = append(os, stats.makeThreadOutputStats(i, t))
+			}
+		}
+	}
+	if output_detail == "bucket" {
+		for _, o := range stats.makeBucketOutputStats() {
+			o.log()
+			os = append(os, o)
+		}
+	}
+	if o, ok := stats.makeTotalStats(); ok {
+		o.log()
+		os = append(os, o)
+	}
+	return os
 }
 
 func (stats *Stats) makeTotalStats() (OutputStats, bool) {
 	// Not safe to log if not all writers have completed.
 	completions := atomic.LoadInt32(&stats.completions)
-	if completions < int32(threads) {
-		log.Printf("log, completions: %d", completions)
+	if completions < int32(stats.threads) {
+		logDebugf("log, completions: %d", completions)
 		return OutputStats{}, false
 	}
 
-	bytes := int64(0)
-	ops := int64(0)
-	slowdowns := int64(0)
-
+	is := stats.newIntervalStats("TOTAL", stats.endNano-stats.startNano)
+	is.startNano = stats.startNano
 	for t := 0; t < stats.threads; t++ {
 		for i := 0; i < len(stats.threadStats[t].intervals); i++ {
-			bytes += stats.threadStats[t].intervals[i].bytes
-			ops += int64(len(stats.threadStats[t].intervals[i].latNano))
-			slowdowns += stats.threadStats[t].intervals[i].slowdowns
+			is.merge(&stats.threadStats[t].intervals[i])
 		}
 	}
-	// Aggregate the per-thread Latency slice
-	tmpLat := make([]int64, ops)
-	var c int
-	for t := 0; t < stats.threads; t++ {
-		for i := 0; i < len(stats.threadStats[t].intervals); i++ {
-			c += copy(tmpLat[c:], stats.threadStats[t].intervals[i].latNano)
-		}
-	}
-	sort.Slice(tmpLat, func(i, j int) bool { return tmpLat[i] < tmpLat[j] })
-	is := IntervalStats{stats.loop, "TOTAL", stats.mode, bytes, slowdowns, stats.endNano - stats.startNano, tmpLat}
-	return is.makeOutputStats(), true
+	sort.Slice(is.latNano, func(i, j int) bool { return is.latNano[i] < is.latNano[j] })
+	o := is.makeOutputStats()
+	o.TargetRate = stats.targetRate(stats.startNano, stats.endNano)
+	return o, true
 }
 
-// Only safe to call from the calling thread
-func (stats *Stats) updateIntervals(thread_num int) int64 {
-	curInterval := stats.threadStats[thread_num].curInterval
-	newInterval := stats.threadStats[thread_num].updateIntervals(stats.loop, stats.mode, stats.intervalNano)
-
-	// Finish has already been called
-	if curInterval < 0 {
-		return -1
-	}
+// current -- lock the thread's stats and return the interval for right now.
+// The caller must unlock stats.threadStats[thread_num].mu.
+func (stats *Stats) current(thread_num int) *IntervalStats {
+	ts := &stats.threadStats[thread_num]
+	ts.mu.Lock()
+	return ts.interval(stats.intervalOf(time.Now().UnixNano()))
+}
 
-	for i := curInterval; i < newInterval; i++ {
-		// load or store the current value
-		value, _ := stats.intervalCompletions.LoadOrStore(i, new(int32))
-		cp, ok := value.(*int32)
-		if !ok {
-			log.Printf("updateIntervals: got data of type %T but wanted *int32", value)
-			continue
-		}
+func (stats *Stats) addOp(thread_num int, bytes int64, latNano int64) {
+	is := stats.current(thread_num)
+	is.bytes += bytes
+	is.latNano = append(is.latNano, latNano)
+	stats.threadStats[thread_num].ops++
+	stats.threadStats[thread_num].bytes += bytes
+	stats.threadStats[thread_num].backoffs = 0
+	stats.addBusy(thread_num, latNano)
+	stats.threadStats[thread_num].mu.Unlock()
+}
 
-		count := atomic.AddInt32(cp, 1)
-		if count == int32(stats.threads) {
-			if is, ok := stats.makeOutputStats(i); ok {
-				is.log()
-			}
+// addBusy -- count an operation that just ended as busy time in the
+// intervals it ran in, and the time since the thread's last one as a gap.
+// Intervals the collector already logged are left alone, the part of the
+// operation in them going uncounted, so the logged rows match the ones
+// written at the end. The caller must hold stats.threadStats[thread_num].mu.
+func (stats *Stats) addBusy(thread_num int, latNano int64) {
+	ts := &stats.threadStats[thread_num]
+	end := time.Now().UnixNano()
+	start := max(end-latNano, stats.startNano)
+	first := max(stats.intervalOf(start), atomic.LoadInt64(&stats.flushed))
+	if gap := start - max(ts.lastEndNano, stats.startNano); gap > 0 {
+		is := ts.interval(first)
+		is.maxGapNano = max(is.maxGapNano, gap)
+	}
+	ts.lastEndNano = max(ts.lastEndNano, end)
+	for i := first; i <= stats.intervalOf(end); i++ {
+		begin := stats.alignNano + i*stats.intervalNano
+		from, to := start, end
+		if stats.intervalNano > 0 {
+			from, to = max(start, begin), min(end, begin+stats.intervalNano)
 		}
+		ts.interval(i).busyNano += to - from
 	}
-	return newInterval
 }
 
-func (stats *Stats) addOp(thread_num int, bytes int64, latNano int64) {
+func (stats *Stats) addSlowDown(thread_num int) {
+	stats.current(thread_num).slowdowns++
+	stats.threadStats[thread_num].mu.Unlock()
+}
 
-	// Interval statistics
-	cur := stats.threadStats[thread_num].curInterval
-	if cur < 0 {
-		return
-	}
-	stats.threadStats[thread_num].intervals[cur].bytes += bytes
-	stats.threadStats[thread_num].intervals[cur].latNano =
-		append(stats.threadStats[thread_num].intervals[cur].latNano, latNano)
+func (stats *Stats) addScanned(thread_num int, bytes int64) {
+	stats.current(thread_num).scannedBytes += bytes
+	stats.threadStats[thread_num].mu.Unlock()
 }
 
-func (stats *Stats) addSlowDown(thread_num int) {
-	cur := stats.threadStats[thread_num].curInterval
-	stats.threadStats[thread_num].intervals[cur].slowdowns++
+func (stats *Stats) addNotModified(thread_num int, latNano int64) {
+	stats.current(thread_num).notModified++
+	stats.threadStats[thread_num].mu.Unlock()
+	stats.addOp(thread_num, 0, latNano)
 }
 
 func (stats *Stats) finish(thread_num int) {
-	stats.updateIntervals(thread_num)
-	stats.threadStats[thread_num].finish()
 	count := atomic.AddInt32(&stats.completions, 1)
 	if count == int32(stats.threads) {
-		stats.endNano = time.Now().UnixNano()
+		atomic.StoreInt64(&stats.endNano, time.Now().UnixNano())
+		close(stats.done)
 	}
 }
 
 func runUpload(thread_num int, fendtime time.Time, rand *ThreadSafeUUID, stats *Stats) {
 	errcnt := 0
-	svc := s3.New(session.New(), cfg)
+	svc := newWorkerClient(thread_num)
+	buf := integrityBuffer()
 	for {
-		if duration_secs > -1 && time.Now().After(endtime) {
+		stats.arrive(thread_num)
+		if phaseOver() {
 			break
 		}
-		objnum := atomic.AddInt64(&op_counter, 1)
-		bucket_num := objnum % int64(bucket_count)
-		if object_count > -1 && objnum >= object_count {
-			objnum = atomic.AddInt64(&op_counter, -1)
+		markWriting(thread_num, atomic.LoadInt64(&op_counter))
+		objnum, ok := nextObject(thread_num, false, false)
+		if !ok {
+			markWritten(thread_num)
 			break
 		}
-		fileobj := bytes.NewReader(object_data)
-
-		var key string
-		if randomize_suffix {
-			key = fmt.Sprintf("%s%s", object_prefix, rand.generateUUIDv4().String())
-		} else {
-			key = fmt.Sprintf("%s%012d", object_prefix, objnum)
+		markWriting(thread_num, objnum)
+		bucket_num := workerBucket(thread_num, objnum)
+		key := objectKey(objnum)
+		size := keySize(key)
+		if !takeBytes(stats, size) {
+			putBackObject(thread_num)
+			markWritten(thread_num)
+			break
 		}
+		fileobj := objectBody(buf, key)
 		r := &s3.PutObjectInput{
-			Bucket: &buckets[bucket_num],
-			Key:    &key,
-			Body:   fileobj,
+			Bucket:             &buckets[bucket_num],
+			Key:                &key,
+			Body:               fileobj,
+			ContentType:        content_types.pick(rand),
+			CacheControl:       cache_controls.pick(rand),
+			ContentDisposition: content_dispositions.pick(rand),
 		}
+		var etag *string
+		var err error
 		start := time.Now().UnixNano()
-		req, _ := svc.PutObjectRequest(r)
-		// Disable payload checksum calculation (very expensive)
-		req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
-		err := req.Send()
+		if useMultipart(size) {
+			etag, err = putMultipart(svc, r)
+		} else {
+			setContentMD5(r)
+			req, out := svc.PutObjectRequest(r)
+			// Disable payload checksum calculation (very expensive)
+			req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+			setUploadMode(req)
+			setChecksum(req)
+			err = req.Send()
+			etag = out.ETag
+		}
 		end := time.Now().UnixNano()
-		stats.updateIntervals(thread_num)
+		markWritten(thread_num)
 
 		if err != nil {
-			errcnt++
-			stats.addSlowDown(thread_num)
-			atomic.AddInt64(&op_counter, -1)
-			log.Printf("upload err", err)
+			putBackObject(thread_num)
+			returnBytes(size)
+			if stats.requestFailed(thread_num, &errcnt, "upload", err) {
+				break
+			}
 		} else {
 			// Update the stats
-			stats.addOp(thread_num, object_size, end-start)
-		}
-		if errcnt > 2 {
-			break
+			stats.addBucketOp(thread_num, buckets[bucket_num], size, end-start)
+			addManifest(buckets[bucket_num], key, etag)
 		}
+		think(start)
 	}
 	stats.finish(thread_num)
 	atomic.AddInt64(&running_threads, -1)
@@ -518,30 +868,24 @@ func runUpload(thread_num int, fendtime time.Time, rand *ThreadSafeUUID, stats *
 
 func runDownload(thread_num int, fendtime time.Time, rand *ThreadSafeUUID, stats *Stats) {
 	errcnt := 0
-	svc := s3.New(session.New(), cfg)
+	svc := newWorkerClient(thread_num)
 	for {
-		if duration_secs > -1 && time.Now().After(endtime) {
+		stats.arrive(thread_num)
+		if phaseOver() {
 			break
 		}
 
-		objnum := atomic.AddInt64(&op_counter, 1)
-		if loop_objects && duration_secs > -1 {
-			objnum = objnum % object_count
-		}
-		if object_count > -1 && objnum >= object_count {
-			atomic.AddInt64(&op_counter, -1)
+		objnum, ok := nextObject(thread_num, loop_objects && duration_secs > -1, true)
+		if !ok {
 			break
 		}
 
-		bucket_num := objnum % int64(bucket_count)
-		var key string
-		if randomize_suffix {
-			key = fmt.Sprintf("%s%s", object_prefix, rand.generateUUIDv4().String())
-		} else {
-			key = fmt.Sprintf("%s%012d", object_prefix, objnum)
+		bucket, key, size := objectName(thread_num, objnum, rand)
+		if !takeBytes(stats, size) {
+			break
 		}
 		r := &s3.GetObjectInput{
-			Bucket: &buckets[bucket_num],
+			Bucket: bucket,
 			Key:    &key,
 		}
 
@@ -549,51 +893,100 @@ func runDownload(thread_num int, fendtime time.Time, rand *ThreadSafeUUID, stats
 		req, resp := svc.GetObjectRequest(r)
 		err := req.Send()
 		end := time.Now().UnixNano()
-		stats.updateIntervals(thread_num)
 
 		if err != nil {
-			errcnt++
-			stats.addSlowDown(thread_num)
-			log.Printf("download err", err)
+			returnBytes(size)
+			if stats.requestFailed(thread_num, &errcnt, "download", err) {
+				break
+			}
 		} else {
-			io.Copy(ioutil.Discard, resp.Body)
-			resp.Body.Close()
+			drainBody(resp.Body)
 			// Update the stats
-			stats.addOp(thread_num, object_size, end-start)
+			stats.addCacheStatus(thread_num, req)
+			stats.addBucketOp(thread_num, *bucket, size, end-start)
 		}
-		if errcnt > 2 {
-			break
-		}
-
+		think(start)
 	}
 	stats.finish(thread_num)
 	atomic.AddInt64(&running_threads, -1)
 }
 
-func runDelete(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+// setCondition -- make the GET r of key conditional on -cond-header, the
+// ETag the object was written with or since, the start of the test
+func setCondition(r *s3.GetObjectInput, key string, since time.Time) {
+	switch cond_header {
+	case "etag":
+		r.IfNoneMatch = &payloadFor(key).etag
+	case "date":
+		r.IfModifiedSince = &since
+	}
+}
+
+func runConditionalDownload(thread_num int, fendtime time.Time, rand *ThreadSafeUUID, stats *Stats) {
 	errcnt := 0
-	svc := s3.New(session.New(), cfg)
+	svc := newWorkerClient(thread_num)
+	since := time.Now().UTC()
 	for {
-		if duration_secs > -1 && time.Now().After(endtime) {
+		stats.arrive(thread_num)
+		if phaseOver() {
 			break
 		}
 
-		objnum := atomic.AddInt64(&op_counter, 1)
-		if object_count > -1 && objnum >= object_count {
-			atomic.AddInt64(&op_counter, -1)
+		objnum, ok := nextObject(thread_num, loop_objects && duration_secs > -1, true)
+		if !ok {
 			break
 		}
 
-		bucket_num := objnum % int64(bucket_count)
+		bucket, key, size := objectName(thread_num, objnum, rand)
+		r := &s3.GetObjectInput{
+			Bucket: bucket,
+			Key:    &key,
+		}
+		setCondition(r, key, since)
+
+		start := time.Now().UnixNano()
+		req, resp := svc.GetObjectRequest(r)
+		err := req.Send()
+		end := time.Now().UnixNano()
 
-		var key string
-		if randomize_suffix {
-			key = fmt.Sprintf("%s%s", object_prefix, rand.generateUUIDv4().String())
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotModified {
+			// The cached copy is still valid, nothing was transferred
+			stats.addCacheStatus(thread_num, req)
+			stats.addNotModified(thread_num, end-start)
+		} else if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, "conditional download", err) {
+				break
+			}
 		} else {
-			key = fmt.Sprintf("%s%012d", object_prefix, objnum)
+			drainBody(resp.Body)
+			// Update the stats
+			stats.addCacheStatus(thread_num, req)
+			stats.addBucketOp(thread_num, *bucket, size, end-start)
 		}
+		think(start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
+
+func runDelete(thread_num int, rand *ThreadSafeUUID, stats *Stats, gone *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	pending := make([]pendingDelete, 0)
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		objnum, ok := nextObject(thread_num, false, true)
+		if !ok {
+			break
+		}
+
+		bucket, key, size := objectName(thread_num, objnum, rand)
 		r := &s3.DeleteObjectInput{
-			Bucket: &buckets[bucket_num],
+			Bucket: bucket,
 			Key:    &key,
 		}
 
@@ -601,26 +994,35 @@ func runDelete(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
 		req, out := svc.DeleteObjectRequest(r)
 		err := req.Send()
 		end := time.Now().UnixNano()
-		stats.updateIntervals(thread_num)
 
 		if err != nil {
-			errcnt++
-			stats.addSlowDown(thread_num)
-			log.Printf("delete err", err, "out", out.String())
+			if stats.requestFailed(thread_num, &errcnt, "delete", err, "output", out.String()) {
+				break
+			}
 		} else {
 			// Update the stats
-			stats.addOp(thread_num, object_size, end-start)
-		}
-		if errcnt > 2 {
-			break
+			stats.addBucketOp(thread_num, *bucket, size, end-start)
+			if gone != nil {
+				pending = append(pending, pendingDelete{*bucket, key, end})
+			}
 		}
+		think(start)
 	}
 	stats.finish(thread_num)
+	if gone != nil {
+		verifyDeletes(thread_num, svc, pending, gone)
+	}
 	atomic.AddInt64(&running_threads, -1)
 }
 
+// isNoSuchBucket -- check whether a request failed because the bucket is missing
+func isNoSuchBucket(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == s3.ErrCodeNoSuchBucket
+}
+
 func runBucketDelete(thread_num int, stats *Stats) {
-	svc := s3.New(session.New(), cfg)
+	svc := newWorkerClient(thread_num)
 
 	for {
 		bucket_num := atomic.AddInt64(&op_counter, 1)
@@ -635,9 +1037,12 @@ func runBucketDelete(thread_num int, stats *Stats) {
 		start := time.Now().UnixNano()
 		_, err := svc.DeleteBucket(r)
 		end := time.Now().UnixNano()
-		stats.updateIntervals(thread_num)
 
-		if err != nil {
+		if isNoSuchBucket(err) {
+			// Nothing to delete, which is expected on a fresh run
+			continue
+		} else if err != nil {
+			stats.abort(thread_num, fmt.Sprintf("unable to delete bucket %s: %v", buckets[bucket_num], err))
 			break
 		}
 		stats.addOp(thread_num, 0, end-start)
@@ -646,31 +1051,67 @@ func runBucketDelete(thread_num int, stats *Stats) {
 	atomic.AddInt64(&running_threads, -1)
 }
 
-func runBucketList(thread_num int, stats *Stats) {
-	svc := s3.New(session.New(), cfg)
+// listPartition -- the keys after which partition part of parts starts and
+// stops, following the object index or else the object naming of the PUT
+// test. Empty strings mean the start or end of the bucket.
+func listPartition(bucket_num int64, part int64, parts int64) (string, string) {
+	after, until := "", ""
+	if object_index != nil {
+		keys := indexKeys(buckets[bucket_num])
+		n := int64(len(keys))
+		if part > 0 && part*n/parts > 0 {
+			after = keys[part*n/parts-1]
+		}
+		if part < parts-1 && (part+1)*n/parts > 0 {
+			until = keys[(part+1)*n/parts-1]
+		}
+		return after, until
+	}
+	if part > 0 {
+		after = fmt.Sprintf("%s%012d", object_prefix, part*object_count/parts-1)
+	}
+	if part < parts-1 {
+		until = fmt.Sprintf("%s%012d", object_prefix, (part+1)*object_count/parts-1)
+	}
+	return after, until
+}
+
+func runBucketList(thread_num int, parts int64, stats *Stats) {
+	svc := newWorkerClient(thread_num)
 
 	for {
-		bucket_num := atomic.AddInt64(&op_counter, 1)
-		if bucket_num >= bucket_count {
+		unit := atomic.AddInt64(&op_counter, 1)
+		if unit >= bucket_count*parts {
 			atomic.AddInt64(&op_counter, -1)
 			break
 		}
+		bucket_num := unit / parts
+		after, until := listPartition(bucket_num, unit%parts, parts)
+		check := &listChecker{bucket: buckets[bucket_num], last: after, until: until}
 
 		start := time.Now().UnixNano()
 		err := svc.ListObjectsPages(
 			&s3.ListObjectsInput{
 				Bucket:  &buckets[bucket_num],
 				MaxKeys: &max_keys,
+				Marker:  &after,
 			},
 			func(p *s3.ListObjectsOutput, last bool) bool {
 				end := time.Now().UnixNano()
-				stats.updateIntervals(thread_num)
 				stats.addOp(thread_num, 0, end-start)
 				start = time.Now().UnixNano()
+				if list_check {
+					check.page(p)
+				}
+				// Stop once the page reaches the next partition
+				if until != "" && len(p.Contents) > 0 && *p.Contents[len(p.Contents)-1].Key >= until {
+					return false
+				}
 				return true
 			})
 
 		if err != nil {
+			stats.abort(thread_num, fmt.Sprintf("unable to list bucket %s: %v", buckets[bucket_num], err))
 			break
 		}
 	}
@@ -680,8 +1121,83 @@ func runBucketList(thread_num int, stats *Stats) {
 
 var cfg *aws.Config
 
+// headerFlags -- repeatable "Key: Value" flag holding extra request headers
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("header %q is not in \"Key: Value\" form", value)
+	}
+	*h = append(*h, strings.TrimSpace(key)+": "+strings.TrimSpace(val))
+	return nil
+}
+
+// stringListFlag -- repeatable flag collecting every value passed to it
+type stringListFlag []string
+
+func (l *stringListFlag) String() string {
+	return strings.Join(*l, " | ")
+}
+
+func (l *stringListFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// pick -- return one of the values at random, or nil if none were given
+func (l stringListFlag) pick(rand *ThreadSafeUUID) *string {
+	switch len(l) {
+	case 0:
+		return nil
+	case 1:
+		return &l[0]
+	}
+	return &l[rand.intn(len(l))]
+}
+
+// newS3Client -- create an S3 client with the user supplied headers attached
+func newS3Client() *s3.S3 {
+	return newS3ClientWith(cfg, len(endpoints) > 1)
+}
+
+// newS3ClientWith -- build an S3 client on config c, spreading requests over
+// the -u endpoints when failover is set
+func newS3ClientWith(c *aws.Config, failover bool) *s3.S3 {
+	sess := session.New()
+	if len(request_headers) > 0 {
+		// Add the headers while building so they are covered by the signature
+		sess.Handlers.Build.PushBack(func(r *request.Request) {
+			for _, h := range request_headers {
+				key, val, _ := strings.Cut(h, ": ")
+				r.HTTPRequest.Header.Set(key, val)
+			}
+		})
+	}
+	if debug_sample > 0 {
+		sess.Handlers.Complete.PushBack(logSampledRequest)
+	}
+	if top_slowest > 0 {
+		sess.Handlers.Complete.PushBack(recordSlowOp)
+	}
+	if failover {
+		sess.Handlers.Sign.PushFront(routeRequest)
+		sess.Handlers.Send.PushBack(recordAttempt)
+	}
+	sess.Handlers.Retry.PushFront(handleSkewedRequest)
+	svc := s3.New(sess, c)
+	if clock_skew == "correct" {
+		svc.Handlers.Sign.Swap(v4.SignRequestHandler.Name, signWithSkew)
+	}
+	return svc
+}
+
 func runBucketsInit(thread_num int, stats *Stats) {
-	svc := s3.New(session.New(), cfg)
+	svc := newWorkerClient(thread_num)
 
 	for {
 		bucket_num := atomic.AddInt64(&op_counter, 1)
@@ -690,53 +1206,63 @@ func runBucketsInit(thread_num int, stats *Stats) {
 			break
 		}
 		start := time.Now().UnixNano()
-		in := &s3.CreateBucketInput{Bucket: aws.String(buckets[bucket_num])}
+		in := &s3.CreateBucketInput{
+			Bucket:                    aws.String(buckets[bucket_num]),
+			CreateBucketConfiguration: bucketConfiguration(buckets[bucket_num]),
+		}
 		_, err := svc.CreateBucket(in)
 		end := time.Now().UnixNano()
-		stats.updateIntervals(thread_num)
 
 		if err != nil {
 			if !strings.Contains(err.Error(), s3.ErrCodeBucketAlreadyOwnedByYou) &&
 				!strings.Contains(err.Error(), "BucketAlreadyExists") {
-				log.Fatalf("FATAL: Unable to create bucket %s (is your access and secret correct?): %v", buckets[bucket_num], err)
+				logFatalf("FATAL: Unable to create bucket %s (is your access and secret correct?): %v", buckets[bucket_num], err)
 			}
 		}
 		stats.addOp(thread_num, 0, end-start)
+		if err := setBucketShards(buckets[bucket_num]); err != nil {
+			logFatalf("FATAL: Unable to set the index shards of bucket %s: %v", buckets[bucket_num], err)
+		}
 	}
 	stats.finish(thread_num)
 	atomic.AddInt64(&running_threads, -1)
 }
 
 func runBucketsClear(thread_num int, stats *Stats) {
-	svc := s3.New(session.New(), cfg)
+	svc := newWorkerClient(thread_num)
 
 	for current_bucket := range bucket_count {
 		bucket_num := (thread_num + int(current_bucket)) % int(bucket_count)
-		log.Printf("Clearing bucket %s num %d thread num %d", buckets[bucket_num], bucket_num, thread_num)
+		logDebugf("Clearing bucket %s num %d thread num %d", buckets[bucket_num], bucket_num, thread_num)
 		listMu.Lock()
 		if listBucketComplete[bucket_num] {
 			listMu.Unlock()
-			log.Printf("abort reading bucket %s in thread %d since bucket is read", buckets[bucket_num], thread_num)
-			break
+			logDebugf("skip reading bucket %s in thread %d since bucket is read", buckets[bucket_num], thread_num)
+			continue
 		}
 		out, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
 			Bucket:            &buckets[bucket_num],
 			ContinuationToken: listContinuationToken[bucket_num],
 			MaxKeys:           &max_keys,
 		})
-		if err != nil {
+		if isNoSuchBucket(err) {
+			listBucketComplete[bucket_num] = true
+			listMu.Unlock()
+			continue
+		} else if err != nil {
 			listMu.Unlock()
+			stats.abort(thread_num, fmt.Sprintf("unable to list bucket %s: %v", buckets[bucket_num], err))
 			break
 		}
 		if out.NextContinuationToken == nil {
 			listBucketComplete[bucket_num] = true
-			log.Printf("Reached end in bucket %s by thread %d", buckets[bucket_num], thread_num)
+			logDebugf("Reached end in bucket %s by thread %d", buckets[bucket_num], thread_num)
 		}
 		listContinuationToken[bucket_num] = out.NextContinuationToken
 		listMu.Unlock()
 		n := len(out.Contents)
 		for n > 0 {
-			log.Printf("Received %d objects from bucket %s in thread %d", n, buckets[bucket_num], thread_num)
+			logDebugf("Received %d objects from bucket %s in thread %d", n, buckets[bucket_num], thread_num)
 			for _, v := range out.Contents {
 				start := time.Now().UnixNano()
 				svc.DeleteObject(&s3.DeleteObjectInput{
@@ -744,7 +1270,6 @@ func runBucketsClear(thread_num int, stats *Stats) {
 					Key:    v.Key,
 				})
 				end := time.Now().UnixNano()
-				stats.updateIntervals(thread_num)
 				stats.addOp(thread_num, *v.Size, end-start)
 			}
 			listMu.Lock()
@@ -766,7 +1291,7 @@ func runBucketsClear(thread_num int, stats *Stats) {
 			}
 			if out.NextContinuationToken == nil {
 				listBucketComplete[bucket_num] = true
-				log.Printf("Reached end in bucket %s by thread %d", buckets[bucket_num], thread_num)
+				logDebugf("Reached end in bucket %s by thread %d", buckets[bucket_num], thread_num)
 			}
 			listContinuationToken[bucket_num] = out.NextContinuationToken
 			listMu.Unlock()
@@ -777,12 +1302,34 @@ func runBucketsClear(thread_num int, stats *Stats) {
 	atomic.AddInt64(&running_threads, -1)
 }
 
+// runPhase -- run the test of mode r and what goes around it: the wait for
+// the server to settle and the -pre-phase-cmd and -post-phase-cmd hooks
+func runPhase(loop int, r rune, phase string, first bool) []OutputStats {
+	settleBeforePhase(first, loop, phase)
+	beforePhase(loop, phase)
+	rows := runWrapper(loop, r)
+	afterPhase(loop, phase)
+	return rows
+}
+
 func runWrapper(loop int, r rune) []OutputStats {
 	op_counter = -1
+	resetShards()
+	resetByteBudget()
 	running_threads = int64(threads)
 	intervalNano := int64(interval * 1000000000)
 	endtime = time.Now().Add(time.Second * time.Duration(duration_secs))
-	var stats Stats
+	resetControl()
+	resetWorkers()
+	// Forget requests made between tests, ie by the probe
+	takeSlowest()
+	startRuntime()
+	var stats *Stats
+	// Second set of stats, time-to-restore for the restore test and the
+	// writes of the mixed test
+	var second *Stats
+	// GET test run alongside a PUT test with -overlap
+	var overlapped chan *Stats
 
 	// If we perviously set the object count after running a put
 	// test, set the object count back to -1 for the new put test.
@@ -790,51 +1337,238 @@ func runWrapper(loop int, r rune) []OutputStats {
 		object_count = -1
 		object_count_flag = false
 	}
+	startGrowth(loop, r)
 
 	rnd := NewThreadSafeUUID(randomize_seed)
+	server := serverStatsBefore()
+	bg := startBackground(loop, r)
 
 	switch r {
 	case 'c':
-		log.Printf("Running Loop %d BUCKET CLEAR TEST", loop)
+		logInfof("Running Loop %d BUCKET CLEAR TEST", loop)
 		stats = makeStats(loop, "BCLR", threads, intervalNano)
 		for n := 0; n < threads; n++ {
-			go runBucketsClear(n, &stats)
+			worker(n, func() { runBucketsClear(n, stats) })
 		}
 	case 'x':
-		log.Printf("Running Loop %d BUCKET DELETE TEST", loop)
+		logInfof("Running Loop %d BUCKET DELETE TEST", loop)
 		stats = makeStats(loop, "BDEL", threads, intervalNano)
 		for n := 0; n < threads; n++ {
-			go runBucketDelete(n, &stats)
+			worker(n, func() { runBucketDelete(n, stats) })
 		}
 	case 'i':
-		log.Printf("Running Loop %d BUCKET INIT TEST", loop)
+		logInfof("Running Loop %d BUCKET INIT TEST", loop)
 		stats = makeStats(loop, "BINIT", threads, intervalNano)
 		for n := 0; n < threads; n++ {
-			go runBucketsInit(n, &stats)
+			worker(n, func() { runBucketsInit(n, stats) })
+		}
+	case 'V':
+		logInfof("Running Loop %d OBJECT VERIFY TEST", loop)
+		stats = makeStats(loop, "VERIFY", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runVerify(n, rnd, stats) })
+		}
+	case 'e':
+		logInfof("Running Loop %d BUCKET ENCRYPTION TEST", loop)
+		stats = makeStats(loop, "BENC", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runBucketsEncryption(n, stats) })
 		}
 	case 'p':
-		log.Printf("Running Loop %d OBJECT PUT TEST", loop)
+		if notify_topic != "" {
+			configureNotifications()
+		}
+		logInfof("Running Loop %d OBJECT PUT TEST", loop)
 		stats = makeStats(loop, "PUT", threads, intervalNano)
+		if overlap_get {
+			overlapped = startOverlap(loop, rnd, stats, intervalNano)
+		}
 		for n := 0; n < threads; n++ {
-			go runUpload(n, endtime, rnd, &stats)
+			worker(n, func() { runUpload(n, endtime, rnd, stats) })
 		}
 	case 'l':
-		log.Printf("Running Loop %d BUCKET LIST TEST", loop)
+		logInfof("Running Loop %d BUCKET LIST TEST", loop)
 		stats = makeStats(loop, "LIST", threads, intervalNano)
+		parts := list_partitions
+		if parts > 1 && object_count <= 0 {
+			logWarnf("Listing buckets whole, -list-partitions needs -n or an earlier 'p' test to know the key range")
+			parts = 1
+		}
+		if parts > 1 && key_depth > 0 {
+			logWarnf("Listing buckets whole, -list-partitions can not split the key range of -key-depth directories")
+			parts = 1
+		}
+		resetListCheck()
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runBucketList(n, parts, stats) })
+		}
+	case 'w':
+		logInfof("Running Loop %d DIRECTORY WALK TEST", loop)
+		stats = makeStats(loop, "WALK", threads, intervalNano)
+		queue := newWalkQueue()
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runWalk(n, queue, stats) })
+		}
+	case 'E':
+		logInfof("Running Loop %d OBJECT EXPIRE TEST", loop)
+		stats = makeStats(loop, "EXPLIST", threads, intervalNano)
+		second = makeStats(loop, "EXPIRE", threads, intervalNano)
+		queue := newExpireQueue()
+		cutoff := time.Now().Add(-expire_age)
 		for n := 0; n < threads; n++ {
-			go runBucketList(n, &stats)
+			worker(n, func() { runExpire(n, queue, cutoff, stats, second) })
 		}
 	case 'g':
-		log.Printf("Running Loop %d OBJECT GET TEST", loop)
+		logInfof("Running Loop %d OBJECT GET TEST", loop)
 		stats = makeStats(loop, "GET", threads, intervalNano)
 		for n := 0; n < threads; n++ {
-			go runDownload(n, endtime, rnd, &stats)
+			worker(n, func() { runDownload(n, endtime, rnd, stats) })
+		}
+	case 'v':
+		logInfof("Running Loop %d OBJECT CONDITIONAL GET TEST", loop)
+		stats = makeStats(loop, "CGET", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runConditionalDownload(n, endtime, rnd, stats) })
+		}
+	case 's':
+		logInfof("Running Loop %d OBJECT SELECT TEST", loop)
+		stats = makeStats(loop, "SELECT", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runSelect(n, endtime, rnd, stats) })
+		}
+	case 'h':
+		logInfof("Running Loop %d OBJECT HEAD TEST", loop)
+		stats = makeStats(loop, "HEAD", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runHead(n, rnd, stats) })
+		}
+	case 'a':
+		logInfof("Running Loop %d OBJECT ATTRIBUTES TEST", loop)
+		stats = makeStats(loop, "ATTR", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runGetAttributes(n, endtime, rnd, stats) })
+		}
+	case 'P':
+		logInfof("Running Loop %d BUCKET POLICY PUT TEST", loop)
+		stats = makeStats(loop, "BPPUT", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runBucketPolicy(n, r, stats) })
+		}
+	case 'G':
+		logInfof("Running Loop %d BUCKET POLICY GET TEST", loop)
+		stats = makeStats(loop, "BPGET", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runBucketPolicy(n, r, stats) })
+		}
+	case 'D':
+		logInfof("Running Loop %d BUCKET POLICY DELETE TEST", loop)
+		stats = makeStats(loop, "BPDEL", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runBucketPolicy(n, r, stats) })
+		}
+	case 'A':
+		logInfof("Running Loop %d OBJECT ACL PUT TEST", loop)
+		stats = makeStats(loop, "ACLPUT", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runObjectAcl(n, r, rnd, stats) })
+		}
+	case 'R':
+		logInfof("Running Loop %d OBJECT ACL GET TEST", loop)
+		stats = makeStats(loop, "ACLGET", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runObjectAcl(n, r, rnd, stats) })
+		}
+	case 'r':
+		logInfof("Running Loop %d OBJECT RESTORE TEST", loop)
+		atomic.StoreInt64(&restore_given_up, 0)
+		stats = makeStats(loop, "RESTORE", threads, intervalNano)
+		second = makeStats(loop, "RESTORED", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runRestore(n, rnd, stats, second) })
+		}
+	case 'y':
+		logInfof("Running Loop %d REPLICATION LAG TEST", loop)
+		atomic.StoreInt64(&replica_missing, 0)
+		stats = makeStats(loop, "REPLPUT", threads, intervalNano)
+		second = makeStats(loop, "REPLLAG", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runReplication(n, stats, second) })
+		}
+	case 'k':
+		logInfof("Running Loop %d SAME KEY CONTENTION TEST", loop)
+		stats = makeStats(loop, "CONTEND", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runContention(n, rnd, stats) })
+		}
+	case 'u':
+		logInfof("Running Loop %d READ-MODIFY-WRITE TEST", loop)
+		stats = makeStats(loop, "RMW", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runReadModifyWrite(n, rnd, stats) })
+		}
+	case 'o':
+		logInfof("Running Loop %d OPERATION %s TEST", loop, operation_name)
+		stats = makeStats(loop, operationMode(), threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runOperation(n, rnd, stats) })
+		}
+	case 'q':
+		logInfof("Running Loop %d SCRIPT TEST", loop)
+		resetScript()
+		stats = makeStats(loop, "SCRIPT", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runScript(n, stats) })
+		}
+	case 'T':
+		logInfof("Running Loop %d SHARD READ TEST", loop)
+		if object_count <= 0 {
+			logFatal("The shard read test has no shards to read, set -n or run a 'p' test first")
+		}
+		stats = makeStats(loop, "SHARD", threads, intervalNano)
+		queue := newShardQueue(loop, rnd)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runShardRead(n, queue, stats) })
+		}
+	case 'B':
+		logInfof("Running Loop %d BLOCK READ TEST", loop)
+		if block_objects <= 0 {
+			block_objects = object_count
+		}
+		if block_objects <= 0 {
+			logFatal("The block read test has no objects to read, set -n or -block-objects")
+		}
+		stats = makeStats(loop, "BGET", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runBlockRead(n, rnd, stats) })
+		}
+	case 'S':
+		logInfof("Running Loop %d REQUEST SIGNING TEST", loop)
+		stats = makeStats(loop, "SIGN", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runSign(n, rnd, stats) })
+		}
+	case 'M':
+		logInfof("Running Loop %d MIXED TEST", loop)
+		if object_count <= 0 {
+			logFatal("The mixed test has no prefilled objects to read")
+		}
+		write_counter = 0
+		stats = makeStats(loop, "MGET", threads, intervalNano)
+		second = makeStats(loop, "MPUT", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runMixed(n, rnd, stats, second) })
 		}
 	case 'd':
-		log.Printf("Running Loop %d OBJECT DELETE TEST", loop)
+		logInfof("Running Loop %d OBJECT DELETE TEST", loop)
 		stats = makeStats(loop, "DEL", threads, intervalNano)
+		var gone *Stats
+		if delete_verify != "" {
+			resetDeleteVerify()
+			gone = makeStats(loop, "DELGONE", threads, intervalNano)
+			second = gone
+		}
 		for n := 0; n < threads; n++ {
-			go runDelete(n, rnd, &stats)
+			worker(n, func() { runDelete(n, rnd, stats, gone) })
 		}
 	}
 
@@ -842,55 +1576,233 @@ func runWrapper(loop int, r rune) []OutputStats {
 	for atomic.LoadInt64(&running_threads) > 0 {
 		time.Sleep(time.Millisecond)
 	}
+	bg.finish(loop)
+	if overlapped != nil {
+		second = <-overlapped
+		put_inflight = nil
+	}
+
+	if r == 'd' && delete_verify != "" {
+		logDeleteVerify()
+	}
+	if r == 'q' {
+		logScript(loop)
+	}
+	if r == 'l' && list_check && atomic.LoadInt32(&stats.aborted) == 0 {
+		checkListCount()
+	}
 
 	// If the user didn't set the object_count, we can set it here
 	// to limit subsequent get/del tests to valid objects only.
 	if r == 'p' && object_count < 0 {
 		object_count = op_counter + 1
+		if key_shard {
+			object_count = recordShards()
+		}
 		object_count_flag = true
 	}
+	endGrowth(r)
 
 	// Create the Output Stats
-	os := make([]OutputStats, 0)
-	for i := int64(0); i >= 0; i++ {
-		if o, ok := stats.makeOutputStats(i); ok {
-			os = append(os, o)
-		} else {
-			break
-		}
+	os := stats.collectOutputStats()
+	stats.server = server.after(stats)
+	recordPhase(stats, os)
+	recordHeatmap(stats)
+	if len(os) > 0 {
+		logQuota(os[len(os)-1])
 	}
-	if o, ok := stats.makeTotalStats(); ok {
-		o.log()
-		os = append(os, o)
+	if second != nil {
+		ros := second.collectOutputStats()
+		recordPhase(second, ros)
+		recordHeatmap(second)
+		os = append(os, ros...)
 	}
 	return os
 }
 
 func init() {
+	// Tests run with their own -test.* flags and set what they use themselves
+	if testing.Testing() || pickSubcommand() {
+		return
+	}
 	// Parse command line
 	myflag := flag.NewFlagSet("myflag", flag.ExitOnError)
-	myflag.StringVar(&access_key, "a", os.Getenv("AWS_ACCESS_KEY_ID"), "Access key")
-	myflag.StringVar(&secret_key, "s", os.Getenv("AWS_SECRET_ACCESS_KEY"), "Secret key")
-	myflag.StringVar(&url_host, "u", os.Getenv("AWS_HOST"), "URL for host with method prefix")
+	myflag.StringVar(&access_key, "a", os.Getenv("AWS_ACCESS_KEY_ID"), "Access key, or file:<path> or - to read it from a file or a line of stdin")
+	myflag.StringVar(&secret_key, "s", os.Getenv("AWS_SECRET_ACCESS_KEY"), "Secret key, or file:<path> or - to read it from a file or a line of stdin")
+	myflag.StringVar(&cred_command, "cred-command", "", "Command printing credential_process JSON credentials, run at startup and every -cred-refresh instead of using -a and -s")
+	myflag.StringVar(&cred_file, "cred-file", "", "File with credential_process JSON credentials, reread every -cred-refresh instead of using -a and -s")
+	myflag.DurationVar(&cred_refresh, "cred-refresh", 15*time.Minute, "How often -cred-command or -cred-file credentials are reloaded, sooner if their Expiration is earlier")
+	myflag.StringVar(&url_host, "u", os.Getenv("AWS_HOST"), "URL for host with method prefix, or a comma separated list of them to spread requests across, failing over between them")
+	myflag.IntVar(&failover_after, "failover-after", 3, "Number of consecutive failed requests after which one of several -u endpoints is marked unhealthy and skipped")
+	myflag.DurationVar(&failover_recheck, "failover-recheck", 10*time.Second, "How often an unhealthy endpoint is sent a trial request to see if it has recovered")
 	myflag.StringVar(&object_prefix, "op", "", "Prefix for objects")
+	myflag.IntVar(&key_depth, "key-depth", 0, "Number of directory levels object keys are spread over after -op, 100 directories at each")
+	myflag.StringVar(&preset, "preset", "", "Canned workload setting the flags not given, see NOTES <empty for none>")
 	myflag.BoolVar(&force_http1, "fh", false, "Force HTTP1")
-	myflag.BoolVar(&randomize_suffix, "rs", false, "Randomize object name suffix")
+	myflag.BoolVar(&randomize_suffix, "rs", false, "Randomize object name suffix, derived from -sd so later tests can read the objects back")
 	myflag.BoolVar(&loop_objects, "lo", false, "Loop objects on get operation")
-	myflag.Int64Var(&randomize_seed, "sd", 0, "Randomize object name suffix")
+	myflag.Int64Var(&randomize_seed, "sd", 0, "Randomize object name suffix (derived from -seed when not set)")
+	myflag.Int64Var(&seed, "seed", 0, "Seed every random choice of the run, ie object data, names, arrivals and jitter, so it can be replayed <picked from the clock when not set>")
 	myflag.StringVar(&bucket_prefix, "bp", "hotsauce-bench", "Prefix for buckets")
 	myflag.StringVar(&region, "r", "us-east-1", "Region for testing")
 	myflag.StringVar(&modes, "m", "cxiplgdcx", "Run modes in order.  See NOTES for more info")
+	myflag.StringVar(&run_id, "run-id", "", "Identifier of this run, added to every result row and used to name result files with -output-mode new")
+	myflag.Var(&tag_args, "tag", "Add a key=value tag to every result row and metric, ie -tag cluster=east (may be repeated)")
+	myflag.StringVar(&control_addr, "control", "", "Serve an HTTP control endpoint on this address, ie 127.0.0.1:7480, to pause, resume, change the rate of or finish the running test")
 	myflag.StringVar(&output, "o", "", "Write CSV output to this file")
 	myflag.StringVar(&json_output, "j", "", "Write JSON output to this file")
+	myflag.StringVar(&report_output, "report", "", "Write a self-contained HTML report to this file")
+	myflag.StringVar(&markdown_output, "md", "", "Write a Markdown table of the test totals to this file")
+	myflag.IntVar(&top_slowest, "top-slowest", 0, "Number of slowest requests of each test to log and keep in the -summary, with their keys and request IDs")
+	myflag.Var(&sink_args, "sink", "Also send the results to a <kind>:<target> sink, ie csv:results.csv, json:results.json, timeseries:intervals.csv (the interval rows with their wall clock start and end), log:, prometheus:<file or pushgateway URL> or influx:<file or write URL> (may be repeated)")
+	myflag.StringVar(&output_mode, "output-mode", "append", "How existing result files are handled: append to add this run's rows, new to name the files after the run's start time, or overwrite")
+	myflag.DurationVar(&soak_rotate, "soak-rotate", 0, "Soak test: stream each interval to the result files and start new files this often, ie 1h, keeping memory flat for multi-day runs <0s to keep every interval in memory until the end>")
+	myflag.DurationVar(&soak_summary, "soak-summary", 24*time.Hour, "Soak test: how often a SUMMARY row covering the time since the last one is logged and written")
+	myflag.StringVar(&heatmap_output, "heatmap", "", "Write a CSV of operation counts per interval and log scale latency bucket to this file")
+	myflag.StringVar(&output_detail, "output-detail", "interval", "Rows written to CSV and JSON output: interval, thread to add a row per thread for each interval, or bucket to add a BUCKET row per bucket for each test")
 	myflag.Int64Var(&max_keys, "mk", 1000, "Maximum number of keys to retreive at once for bucket listings")
+	myflag.DurationVar(&expire_age, "expire-age", 24*time.Hour, "Age by last modified time beyond which the 'E' mode deletes objects")
+	myflag.StringVar(&walk_delimiter, "delimiter", "/", "Directory delimiter used by the 'w' mode")
+	myflag.Int64Var(&list_partitions, "list-partitions", 1, "Number of key ranges each bucket is split into for the 'l' mode, so several threads can list one bucket")
+	myflag.BoolVar(&list_check, "list-check", false, "Check that the 'l' mode lists keys in order without duplicates and finds every object written, failing the test otherwise")
 	myflag.Int64Var(&object_count, "n", -1, "Maximum number of objects <-1 for unlimited>")
+	myflag.StringVar(&max_bytes_arg, "max-bytes", "", "Maximum bytes a PUT or GET test moves, with postfix K, M, G and T <empty for unlimited>")
 	myflag.Int64Var(&bucket_count, "b", 1, "Number of buckets to distribute IOs across")
+	myflag.StringVar(&bucket_template, "bucket-template", "", "Bucket name template, {prefix} is replaced by -bp, {n} by the bucket number and {n:W} by the number padded to W digits <empty for the prefix and 12 digits>")
+	myflag.StringVar(&bg_mode, "bg-mode", "", "Background workload run alongside the -bg-tests tests without being counted in them: p to put objects of its own, g to get the test objects <empty for none>")
+	myflag.StringVar(&bg_tests, "bg-tests", "g", "Tests the -bg-mode workload runs alongside, ie \"gv\"")
+	myflag.IntVar(&bg_threads, "bg-threads", 1, "Number of threads of the -bg-mode workload")
+	myflag.StringVar(&per_conn_bw_arg, "per-conn-bw", "0", "Bandwidth each connection reads and writes at, ie 10MB/s, with postfix K, M, and G <0 for unlimited>")
+	myflag.StringVar(&bg_rate_arg, "bg-rate", "0", "Bandwidth the -bg-mode workload offers per second with postfix K, M, and G <0 for full speed>")
+	myflag.StringVar(&bg_size_arg, "bg-z", "", "Size of the objects the -bg-mode workload puts with postfix K, M, and G <empty for -z>")
+	myflag.StringVar(&workers_file, "workers", "", "File of \"<group> threads=<n> [endpoint=<url>] [access-key=<key> secret-key=<key>] [buckets=<n,...>] [rate=<ops/s>] [read-pct=<pct>]\" lines running groups of threads with their own settings in place of -t")
+	myflag.BoolVar(&minio_info, "minio-info", false, "Ask the MinIO admin API for the server info and healing status before and after the run, logging them and adding them to the -summary file (needs admin:ServerInfo and admin:Heal)")
+	myflag.StringVar(&minio_admin_endpoint, "minio-admin-u", "", "Endpoint of the MinIO admin API for -minio-info <empty for the first -u>")
+	myflag.StringVar(&minio_admin_key, "minio-admin-a", "", "Access key of a MinIO admin user for -minio-info, or file:<path> or - <empty for -a>")
+	myflag.StringVar(&minio_admin_secret, "minio-admin-s", "", "Secret key of a MinIO admin user for -minio-info, or file:<path> or - <empty for -s>")
+	myflag.BoolVar(&rgw_stats, "rgw-stats", false, "Ask the RGW admin API for the objects and bytes in the buckets before and after every test, to check against the client's counts (needs buckets=read admin caps)")
+	myflag.StringVar(&rgw_admin_endpoint, "rgw-admin-u", "", "Endpoint of the RGW admin API for -rgw-stats <empty for the first -u>")
+	myflag.StringVar(&rgw_admin_key, "rgw-admin-a", "", "Access key of an RGW admin user for -rgw-stats, or file:<path> or - <empty for -a>")
+	myflag.StringVar(&rgw_admin_secret, "rgw-admin-s", "", "Secret key of an RGW admin user for -rgw-stats, or file:<path> or - <empty for -s>")
+	myflag.IntVar(&bucket_shards, "bucket-shards", 0, "Number of index shards the 'i' mode gives the buckets it creates, recorded as the bucket_shards tag <0 for the server default>")
+	myflag.DurationVar(&phase_gap, "phase-gap", 0, "Quiet time between tests, ie 30s, for the server to settle after the last one")
+	myflag.BoolVar(&settle, "settle", false, "Between tests, wait until a trickle of HEAD requests is back to the latency measured before the first test")
+	myflag.Float64Var(&settle_rate, "settle-rate", 10, "HEAD requests per second sent while waiting with -settle")
+	myflag.DurationVar(&settle_window, "settle-window", 5*time.Second, "Window of HEAD requests whose median latency -settle compares with the baseline")
+	myflag.Float64Var(&settle_margin, "settle-margin", 20, "Percentage above the baseline latency that -settle still takes as settled")
+	myflag.DurationVar(&settle_timeout, "settle-timeout", 5*time.Minute, "Longest -settle waits before starting the next test anyway")
+	myflag.StringVar(&pre_phase_cmd, "pre-phase-cmd", "", "Shell command run before every test, ie to drop caches or snapshot server metrics, with HSBENCH_PHASE, HSBENCH_LOOP and HSBENCH_RUN_ID set")
+	myflag.StringVar(&post_phase_cmd, "post-phase-cmd", "", "Shell command run after every test, ie to collect server metrics or rotate logs, with HSBENCH_PHASE, HSBENCH_LOOP and HSBENCH_RUN_ID set")
+	myflag.StringVar(&shard_command, "shard-command", "radosgw-admin bucket reshard --bucket={bucket} --num-shards={shards}", "Command run for every bucket to set its -bucket-shards, with {bucket} and {shards} filled in")
+	myflag.StringVar(&placement_file, "placement", "", "File of \"<bucket or glob> <LocationConstraint>\" lines placing the buckets created by the 'i' mode, ie in RGW placement targets")
+	myflag.StringVar(&bucket_file, "bucket-file", "", "File listing the buckets to use, one per line, instead of -bp, -b and -bucket-template")
 	myflag.IntVar(&duration_secs, "d", 60, "Maximum test duration in seconds <-1 for unlimited>")
 	myflag.IntVar(&threads, "t", 1, "Number of threads to run")
 	myflag.IntVar(&loops, "l", 1, "Number of times to repeat test")
 	myflag.StringVar(&sizeArg, "z", "1M", "Size of objects in bytes with postfix K, M, and G")
+	myflag.StringVar(&object_size_max_arg, "z-max", "", "Largest object size with postfix K, M, and G, spreading the sizes of the PUT, GET and 'M' mode objects from -z up to it <empty for all -z>")
 	myflag.Float64Var(&interval, "ri", 1.0, "Number of seconds between report intervals")
+	myflag.BoolVar(&integrity, "integrity", false, "Write objects of 512 byte blocks holding the key, the block offset and a CRC, for the 'V' mode to verify")
+	myflag.Int64Var(&verify_range, "verify-range", 0, "Number of bytes read at a random offset by each 'V' mode GET <0 for whole objects>")
+	myflag.StringVar(&overlap_arg, "overlap", "", "Start a 'g' test that directly follows a 'p' test once the PUT has written this percentage of the -n objects, running both at once")
+	myflag.StringVar(&subset_arg, "subset", "100%", "Percentage of the written objects GET and DELETE tests work on, a random sample fixed by -seed")
+	myflag.BoolVar(&grow, "grow", false, "Have every PUT test write the next -n objects after those of the earlier ones, so repeated loops measure ever larger buckets, tagging rows with the objects at the start of the test")
+	myflag.BoolVar(&key_shard, "key-shard", false, "Give every thread its own shard of the object numbers in PUT, GET and DELETE tests instead of a shared counter")
+	myflag.IntVar(&data_pool, "data-pool", 1, "Number of distinct random buffers PUT objects are spread over, so repeated data does not feed server-side dedupe or caching")
+	myflag.StringVar(&source_arg, "source", "", "Read PUT bodies from file:/path or a device such as /dev/urandom instead of an in-memory buffer")
 	myflag.BoolVar(&zero_object_data, "zd", false, "Write zero values for objects data in PUT operations instead of random data")
+	myflag.Var(&cpu_set_args, "cpu-set", "Pin test threads to a CPU list such as 0-7,16-23 on Linux, threads being dealt to the sets in turn (may be repeated)")
+	myflag.IntVar(&gomaxprocs, "gomaxprocs", 0, "Set GOMAXPROCS for the benchmark process <0 for the Go default>")
+	myflag.StringVar(&gogc, "gogc", "", "Set GOGC for the benchmark process, a percentage or off")
+	myflag.IntVar(&max_conns, "max-conns", 0, "Maximum number of connections per host <0 for unlimited>")
+	myflag.IntVar(&max_idle_conns, "max-idle-conns", 0, "Maximum number of idle connections kept per host <0 for the Go default>")
+	myflag.Float64Var(&conn_lifetime, "conn-lifetime", 0, "Number of seconds after which connections are closed and reopened before their next request, HTTP/2 ones only once idle, 0 to keep them open")
+	myflag.DurationVar(&kill_interval, "kill-interval", 0, "Fault injection: close connections about this often, ie 30s, to see how quickly throughput recovers from network blips <0s for never>")
+	myflag.Float64Var(&kill_fraction, "kill-fraction", 1, "Fault injection: fraction of the open connections, idle or busy, closed every -kill-interval")
+	myflag.BoolVar(&kill_idle_only, "kill-idle-only", false, "Fault injection: only close idle connections every -kill-interval, leaving requests in flight alone")
+	myflag.BoolVar(&disable_keepalive, "disable-keepalive", false, "Open a new connection for every request")
+	myflag.Var(&request_headers, "header", "Add a \"Key: Value\" header to all requests (may be repeated)")
+	myflag.Var(&content_types, "content-type", "Content-Type set on PUT objects (may be repeated to pick one at random per object)")
+	myflag.Var(&cache_controls, "cache-control", "Cache-Control set on PUT objects (may be repeated to pick one at random per object)")
+	myflag.Var(&content_dispositions, "content-disposition", "Content-Disposition set on PUT objects (may be repeated to pick one at random per object)")
+	myflag.StringVar(&select_format, "select-format", "", "Write PUT objects as csv or json records and query them with this input format in 's' mode")
+	myflag.StringVar(&select_expr, "select-expr", "SELECT * FROM S3Object s", "SQL expression run by the 's' mode")
+	myflag.StringVar(&upload_mode, "upload-mode", "buffered", "How PUT bodies are sent: buffered with a Content-Length, chunked with Transfer-Encoding: chunked as for unknown lengths, or aws-chunked with signed -chunk-size chunks")
+	myflag.StringVar(&read_buffer_arg, "read-buffer", "32K", "Size of the pooled buffers GET bodies are drained with, with postfix K, M, and G")
+	myflag.StringVar(&dialer_arg, "dialer", "", "How to connect to the endpoint: tcp[:<source ip>], unix:<socket path> or connect-to:<host:port>, see NOTES <the transport's own>")
+	myflag.StringVar(&ip_version, "ip-version", "dual", "Address family to resolve and connect to the endpoint over: 4, 6 or dual")
+	myflag.StringVar(&operation_name, "operation", "", "Registered operation the 'o' mode sends, built in or from a -plugin, see NOTES")
+	myflag.Var(&plugin_args, "plugin", "Go plugin file registering operations for -operation (may be repeated)")
+	myflag.StringVar(&script_arg, "script", "", "Operations the 'q' mode runs in turn, ie \"put A; head A; get A range 0-4095; delete A\", or file:<path> to read them from a file, see NOTES")
+	myflag.IntVar(&epochs, "epochs", 1, "Passes the 'T' mode makes over the shards")
+	myflag.StringVar(&shard_range_arg, "shard-range", "8M", "Size of the range requests the 'T' mode reads shards in, with postfix K, M, and G")
+	myflag.IntVar(&shard_parallel, "shard-parallel", 4, "Range requests of a shard each 'T' mode thread has in flight")
+	myflag.StringVar(&mpu_threshold_arg, "mpu-threshold", "", "Object size from which PUT tests send multipart uploads, with postfix K, M, and G <empty for never>")
+	myflag.StringVar(&mpu_part_size_arg, "mpu-part-size", "16M", "Size of the parts of -mpu-threshold multipart uploads, at least 5M")
+	myflag.IntVar(&mpu_concurrency, "mpu-concurrency", 4, "Parts of each -mpu-threshold multipart upload sent at once")
+	myflag.StringVar(&chunk_size_arg, "chunk-size", "64K", "Size of the chunks of -upload-mode aws-chunked and of -checksum bodies with postfix K, M, and G")
+	myflag.BoolVar(&content_md5, "content-md5", false, "Hash every PUT object and send its Content-MD5, counting the hashing in the PUT latency")
+	myflag.StringVar(&checksum_algorithm, "checksum", "", "Send a CRC32, CRC32C, SHA1 or SHA256 checksum of PUT objects, worked out while sending them, as the trailer of an aws-chunked body")
+	myflag.StringVar(&sse_algorithm, "sse", s3.ServerSideEncryptionAes256, "Default encryption applied by the 'e' mode: AES256 for SSE-S3 or aws:kms for SSE-KMS")
+	myflag.StringVar(&sse_kms_key, "sse-kms-key", "", "KMS key ID used by the 'e' mode with -sse aws:kms <empty for the default key>")
+	myflag.BoolVar(&sse_bucket_key, "sse-bucket-key", false, "Enable S3 Bucket Keys in the 'e' mode to cut SSE-KMS requests")
+	myflag.StringVar(&policy_file, "policy", "", "File with the bucket policy used by the 'P' mode, ${bucket} is replaced by the bucket name <empty for a public read policy>")
+	myflag.StringVar(&object_acl, "acl", s3.ObjectCannedACLPrivate, "Canned ACL applied by the 'A' mode")
+	myflag.StringVar(&notify_topic, "notify-topic", "", "Topic ARN to send bucket notifications to during PUT tests <empty to leave notifications unconfigured>")
+	myflag.Var(&notify_events, "notify-event", "Event type sent to -notify-topic (may be repeated, default s3:ObjectCreated:*)")
+	myflag.Int64Var(&restore_days, "restore-days", 1, "Number of days restored objects stay available")
+	myflag.StringVar(&restore_tier, "restore-tier", s3.TierStandard, "Retrieval tier used by the 'r' mode: Standard, Bulk or Expedited")
+	myflag.Float64Var(&restore_poll, "restore-poll", 10, "Number of seconds between checks for restore completion")
+	myflag.Float64Var(&restore_timeout, "restore-timeout", 3600, "Maximum number of seconds to wait for restores to complete")
+	myflag.StringVar(&staircase_arg, "staircase", "", "Object counts the 'F' mode fills the buckets to in turn, with postfix K, M and G, ie 1M,10M,100M")
+	myflag.StringVar(&staircase_tests, "staircase-tests", "gl", "Tests the 'F' mode runs at every -staircase level")
+	myflag.StringVar(&replica_endpoint, "replica-u", "", "Endpoint the 'y' mode polls for the objects it puts to -u, ie the other site of a replicated bucket")
+	myflag.StringVar(&replica_check, "replica-check", "head", "Request the 'y' mode polls -replica-u with: head, or get to read the whole object")
+	myflag.DurationVar(&replica_poll, "replica-poll", 100*time.Millisecond, "Pause between checks of the objects not yet on -replica-u")
+	myflag.DurationVar(&replica_timeout, "replica-timeout", 5*time.Minute, "Time after which objects not on -replica-u fail the REPLLAG test")
+	myflag.StringVar(&delete_verify, "delete-verify", "", "Check that the objects of a 'd' test are gone once it finishes: head or list, reported as DELGONE with the time from each delete until it was seen gone <empty for no check>")
+	myflag.DurationVar(&delete_verify_poll, "delete-verify-poll", time.Second, "Pause between checks of deleted objects still visible")
+	myflag.DurationVar(&delete_verify_timeout, "delete-verify-timeout", time.Minute, "Time after which deleted objects still visible fail the DELGONE test")
+	myflag.StringVar(&block_size_arg, "block-size", "4K", "Size of the aligned ranges read by the 'B' mode with postfix K, M, and G")
+	myflag.Int64Var(&block_objects, "block-objects", 0, "Number of objects read by the 'B' mode <0 for every object>")
+	myflag.IntVar(&contention_keys, "contention-keys", 1, "Number of keys every thread works on in the 'k' mode")
+	myflag.BoolVar(&contention_conditional, "contention-conditional", false, "Make 'k' mode PUTs create-only with If-None-Match: *, so losing writers get a 412")
+	myflag.Int64Var(&rmw_region, "rmw-region", 4096, "Number of bytes changed in each object by the 'u' mode")
+	myflag.BoolVar(&sign_payload, "sign-payload", false, "Hash the object data when signing in the 'S' mode rather than signing UNSIGNED-PAYLOAD like the PUT test")
+	myflag.Float64Var(&read_pct, "read-pct", 50, "Percentage of operations in the 'M' mode that are reads")
+	myflag.Float64Var(&key_zipf, "zipf", 0, "Exponent above 1 of the Zipf distribution 'M' mode reads pick objects by, the lower numbered objects being the hot ones <0 for uniform picks>")
+	myflag.Float64Var(&cond_pct, "cond-pct", 0, "Percentage of 'M' mode reads made conditional with -cond-header, 304 replies counted as NotModified")
+	myflag.Float64Var(&write_overlap, "write-overlap", 1, "Fraction of 'M' mode writes that overwrite objects in the prefilled range read by the mode, the rest create new objects <0 to keep reads and writes apart>")
+	myflag.StringVar(&manifest_out, "manifest-out", "", "Write the bucket, key, size, ETag and checksum of every object written by PUT tests to this CSV file")
+	myflag.BoolVar(&existing_objects, "existing-objects", false, "List the buckets at startup and run the object tests against the objects found instead of following the PUT naming")
+	myflag.StringVar(&manifest_in, "manifest-in", "", "Read the objects used by GET, DELETE and other object tests from a -manifest-out file instead of following the PUT naming")
+	myflag.Int64Var(&quota_objects, "quota-objects", 0, "Object count quota the endpoint is expected to enforce, its 403 QuotaExceeded responses are counted as Quota rejects rather than errors <0 for none>")
+	myflag.StringVar(&quota_size_arg, "quota-size", "", "Storage quota the endpoint is expected to enforce with postfix K, M, and G, its 403 QuotaExceeded responses are counted as Quota rejects rather than errors <empty for none>")
+	myflag.StringVar(&quota_scope, "quota-scope", "user", "Scope of -quota-objects and -quota-size: user or bucket")
+	myflag.StringVar(&profile_file, "profile", "", "File of \"<seconds> <ops/s>\" lines giving the offered rate across all threads over each test, interpolated between lines <empty to run closed-loop>")
+	myflag.StringVar(&arrival, "arrival", "fixed", "Inter-arrival times of a -profile: fixed, exponential (Poisson arrivals) or pareto (heavy-tailed bursts)")
+	myflag.Float64Var(&pareto_shape, "pareto-shape", 1.5, "Shape of -arrival pareto, values closer to 1 are burstier <must be above 1>")
+	myflag.StringVar(&burst_arg, "burst", "", "Run in bursts of <on>:<period>, ie 5s:30s runs 5 seconds of load every 30 seconds <empty for steady load>")
+	myflag.DurationVar(&think_time, "think-time", 0, "Pause between operations on each thread, ie 10ms")
+	myflag.StringVar(&duty_cycle_arg, "duty-cycle", "100%", "Percentage of time each thread spends in requests, pausing in proportion to each request's latency")
+	myflag.StringVar(&clock_skew, "clock-skew", "warn", "Handling of client/server clock skew: warn, correct (sign with the server clock) or ignore")
+	myflag.Float64Var(&backoff_base, "backoff-base", 0.1, "Base number of seconds to back off after a 503 SlowDown, doubled per consecutive throttle <0 to count throttles as errors>")
+	myflag.Float64Var(&backoff_max, "backoff-max", 10, "Maximum number of seconds to back off after a 503 SlowDown")
+	myflag.Float64Var(&max_clock_skew, "max-clock-skew", 60, "Number of seconds of clock skew tolerated before warning or correcting")
+	myflag.BoolVar(&probe, "probe", false, "Probe the endpoint for supported features before running tests")
+	myflag.StringVar(&summary_output, "summary", "", "Write a JSON summary with pass/fail per test to this file")
+	myflag.StringVar(&history_db, "history-db", "", "Record the configuration and per-test results of the run in this SQLite database, see \"hsbench history\"")
+	myflag.Var(&slo_args, "slo", "Latency objective such as \"99% GET < 20ms\" or \"99.9% * < 1s\", reporting compliance and error budget left per interval and failing tests that miss it in total (repeatable)")
+	myflag.Float64Var(&sla_max_lat99, "sla-lat99", 0, "Fail tests whose total 99% latency in ms is above this <0 to disable>")
+	myflag.Float64Var(&sla_min_iops, "sla-iops", 0, "Fail tests whose total IO/s is below this <0 to disable>")
+	myflag.Float64Var(&sla_min_mbps, "sla-mbps", 0, "Fail tests whose total MB/s is below this <0 to disable>")
+	myflag.StringVar(&sla_modes_arg, "sla-modes", "", "Comma separated tests the -sla-* thresholds apply to, ie PUT,GET <empty for all but the bucket tests and the RESTORED, REPLLAG, DELGONE and EXPIRE waits>")
+	myflag.StringVar(&log_level, "log-level", "info", "Log level: debug, info, warn or error")
+	myflag.StringVar(&log_format, "log-format", "text", "Log format: text or json")
+	myflag.BoolVar(&quiet, "quiet", false, "Only log warnings and errors, raising a lower -log-level to warn")
+	myflag.Float64Var(&debug_sample, "debug-sample", 0, "Fraction of requests to log in full, ie 0.001 logs one request in a thousand")
+	myflag.Var(&cache_headers, "cache-header", "Response header saying HIT or MISS, checked on GETs along with X-Cache, X-Cache-Status, CF-Cache-Status, X-Proxy-Cache and Age (may be repeated)")
+	myflag.StringVar(&cond_header, "cond-header", "etag", "Conditional header used by the 'v' mode and -cond-pct: etag (If-None-Match) or date (If-Modified-Since)")
 	// define custom usage output with notes
 	notes :=
 		`
@@ -899,10 +1811,54 @@ NOTES:
     c: clear all existing objects from buckets (requires lookups)
     x: delete buckets
     i: initialize buckets 
+    e: set the default encryption of buckets (see -sse)
     p: put objects in buckets
     l: list objects in buckets
+    w: walk the buckets a directory at a time with delimited listings,
+       reporting directories and keys per second (see -delimiter)
+    E: list the buckets and delete the objects older than -expire-age, like
+       a TTL cleanup job, reporting the listing (EXPLIST) and the deletes
+       (EXPIRE) separately
     g: get objects from buckets
+    v: conditionally get objects from buckets (304 responses are counted
+       separately as NotModified, see -cond-header)
+    s: run S3 Select queries against objects (see -select-format and
+       -select-expr)
+    a: get object attributes, including checksums (see -checksum)
+    h: head objects
     d: delete objects from buckets 
+    r: restore objects from a cold storage class and wait for them to
+       become available, reported as RESTORE (request latency) and
+       RESTORED (time until the object was readable)
+    y: put objects to -u and poll -replica-u until it serves them, reported
+       as REPLPUT and REPLLAG (time from the end of each put until the
+       replica served the object)
+    F: fill the buckets to each -staircase level in turn with a PUT test
+       and run the -staircase-tests at every level
+    M: mixed reads and writes, reported as MGET and MPUT (see -read-pct
+       and -write-overlap)
+    k: put, get and delete the same -contention-keys keys in the first
+       bucket from every thread, 404, 409 and 412 responses are counted
+       as Conflicts rather than errors
+    u: get objects, change a -rmw-region of each and put them back,
+       reported as RMW with the latency of the whole cycle
+    V: get objects, or random -verify-range ranges of them, and check them
+       against the -integrity pattern, mismatches are counted as Corrupt
+    o: send the -operation for every object, reported as its name
+    q: run the -script sequence of operations over and over, reported as
+       SCRIPT with every request an operation
+    T: read the objects as training data shards in -epochs passes, each
+       shard in -shard-range requests taken in order, -shard-parallel at a
+       time, the shards shuffled again for every pass
+    B: read random -block-size ranges from the first -block-objects
+       objects, like a virtual disk backed by S3
+    S: build and sign PUT requests without sending them, measuring the
+       client CPU cost per request (see -sign-payload)
+    P: put bucket policies (see -policy)
+    G: get bucket policies
+    D: delete bucket policies
+    A: put object ACLs (see -acl)
+    R: get object ACLs
 
     These modes are processed in-order and can be repeated, ie "ippgd" will
     initialize the buckets, put the objects, reput the objects, get the
@@ -913,162 +1869,1025 @@ NOTES:
     maximum number of keys returned to 1000 even if MaxKeys is set higher.
     hsbench will attempt to set MaxKeys to whatever value is passed via the 
     "mk" flag, but it's likely that any values above 1000 will be ignored.
+
+  - When a mode runs more than once, either because of -l or because it
+    appears several times in -m, its TOTAL rows are summarized by MEAN,
+    STDDEV, MIN, MAX, CV (coefficient of variation) and CI95LOW/CI95HIGH
+    (95% confidence interval of the mean) rows reported with loop -1.
+
+  - A -profile file turns the object tests into open-loop tests that offer
+    a scripted rate across all threads, ie this ramps up to 1000 ops/s over
+    a minute, holds it, then spikes to 5000 ops/s for ten seconds:
+
+        0    100
+        60s  1000
+        5m   1000
+        5m1s 5000
+        5m10s 5000
+        5m11s 1000
+
+    Times are measured from the start of each test. Each interval reports
+    the Target IO/s alongside the IO/s that was actually achieved.
+
+  - With -control, a running benchmark can be adjusted over HTTP:
+
+        curl -X POST http://127.0.0.1:7480/pause
+        curl -X POST http://127.0.0.1:7480/resume
+        curl -X POST http://127.0.0.1:7480/rate?ops=500
+        curl -X POST http://127.0.0.1:7480/finish
+        curl http://127.0.0.1:7480/status
+
+    A rate overrides -profile until it is set back to 0, and finish ends
+    the running test as if its duration was up, the next test still runs.
+
+  - For multi-day soak tests, -soak-rotate 1h writes every interval to the
+    -o, -j and -sink files as it ends and starts new files each hour, named
+    after the hour, instead of keeping all intervals until the end. A
+    SUMMARY row is added every -soak-summary. SUMMARY and TOTAL latency
+    percentiles come from a histogram and are accurate to about 2%.
+
+  - With -key-shard, thread n of t threads works on objects n, n+t, n+2t
+    and so on, so threads share no counter. When a PUT test runs for a
+    duration the threads write different numbers of objects, which later
+    GET and DELETE tests follow; other tests may find gaps.
+
+  - With -overlap 50%, "pg" in -m starts the GET test once half of the -n
+    objects are written and runs it alongside the rest of the PUT test.
+    It reads each object once, as soon as it is written, and is reported
+    as OGET, so ingest and serving traffic show their effect on each other.
+
+  - A -workers file runs groups of threads with settings of their own in
+    one test, ie 10 heavy writers and 200 light readers in an 'M' test:
+
+        writers threads=10 read-pct=0 endpoint=http://rgw2:8080
+        readers threads=200 read-pct=100 rate=2000 buckets=0,1
+
+    The threads of a group use the group's buckets for the objects they
+    write and read, so groups reading each other's objects should list
+    the same buckets.
+
+  - On a multi-socket client, "-cpu-set 0-15 -cpu-set 16-31" deals the
+    test threads out to the two sets in turn, each thread keeping to the
+    CPUs of its set. The connection handling the Go HTTP client does in
+    goroutines of its own is not pinned.
+
+  - Every run logs the -seed it used. Passing it back replays the object
+    data, random names, arrival times and jitter of that run; the order
+    in which threads draw from them still follows the server's timing.
+
+  - With -history-db hsbench.db every run is added to a SQLite database.
+    "hsbench history -db hsbench.db" lists past runs by -tag, -endpoint
+    or date, and -compare 3,7 shows how run 7 differs from run 3 test by
+    test.
+
+  - Every option can also be set through an HSBENCH_ environment variable
+    named after it, ie HSBENCH_U for -u or HSBENCH_LOG_LEVEL for -log-level,
+    so long command lines and keys need not be passed as arguments.
+    Options given on the command line take precedence. Repeatable options
+    take one value per line, and commands take HSBENCH_<COMMAND>_ ones,
+    ie HSBENCH_AGENT_TOKEN.
+
+  - With -bucket-shards 64 the 'i' mode reshards every bucket it creates
+    to 64 index shards through -shard-command, radosgw-admin by default,
+    so it needs to run where radosgw-admin can reach the cluster or be
+    given a command that does, ie over ssh. The count is added to the
+    results and the -history-db as the bucket_shards tag.
+
+  - With -rgw-stats every test logs the objects and bytes RGW counts in
+    the buckets before and after it next to the requests and bytes the
+    client counted, and adds both to the -summary file. The admin API
+    needs a user with buckets=read caps, given with -rgw-admin-a and
+    -rgw-admin-s when the test user has none.
+
+  - To see how index performance changes with bucket size, -grow makes
+    every PUT test write the -n objects after those the earlier PUT tests
+    wrote: with "-m pg -l 5 -n 100000 -grow" loop 0 writes objects 0 to
+    99999, loop 1 objects 100000 to 199999 and so on, and each GET test
+    reads all of them. Rows get an objects tag with the objects the buckets
+    held when the test started. A 'c', 'x' or 'd' test starts the
+    namespace over.
+
+  - PUT tests send objects of -mpu-threshold bytes or more as multipart
+    uploads of -mpu-part-size parts, -mpu-concurrency of them at a time,
+    like the SDK's upload manager. An upload is one operation, so PUT
+    latencies are the end-to-end transfer time of each object while MB/s
+    remains the aggregate throughput.
+
+  - Web assets are mostly small and some are read far more than others.
+    -z-max spreads object sizes from -z up to it, log-uniformly so small
+    objects are common, each key keeping its size in later tests. -zipf
+    1.1 has 'M' mode reads favour the lower numbered objects, and
+    -cond-pct 30 makes 30% of them conditional like a cache revalidating
+    its copy.
+
+  - The 'q' mode runs a -script of operations in turn over and over, ie
+    "put A; head A; get A range 0-4095; delete A", the operations put,
+    get, head and delete each taking a key and get an optional range. A
+    plain name like A is a key of its own for every thread and iteration,
+    under script/ after -op; a name with {thread} and {iter} in it is a
+    template, ie "put shared/{thread}" has every thread rewrite one key.
+    Every request counts as a SCRIPT operation, and the requests, errors
+    and latency of each step are logged once the test is done. A failed
+    step skips the rest of the iteration. -n caps the iterations when -d
+    is -1. Longer scripts can be read with -script file:<path>, one
+    operation per line and # starting comments.
+
+  - -dialer picks how connections to the endpoint are opened. unix:<path>
+    connects every request to a Unix socket, ie of a local gateway or
+    sidecar, and connect-to:<host:port> to another address than the URL's;
+    the URL still gives the Host header and the name TLS certificates are
+    checked against. tcp:<ip> binds the connections to a local address.
+    More dialers are added by a file of this package calling
+    registerDialer from init. -ip-version 4 or 6 keeps the TCP dialers to
+    one address family, names resolving only to its addresses, so each
+    family of a dual stack cluster can be benchmarked on its own; dual
+    leaves the choice to the resolver with a fallback to the other family.
+
+  - -per-conn-bw shapes every connection to the endpoint with a token
+    bucket for each direction, ie "-per-conn-bw 10MB/s" to see how the
+    store serves clients behind WAN or last mile links. With the default
+    connection pool each thread keeps to a connection of its own, so -t
+    threads offer at most -t times the bandwidth. Latencies include the
+    time spent waiting for the bucket.
+
+  - The 'o' mode sends an operation of its own for every object, with the
+    pacing, retries and stats of the other tests. get-tagging and
+    put-tagging are built in; more are added by implementing Operation in
+    a file of this package that calls registerOperation from init, or
+    without rebuilding hsbench from a Go plugin loaded with -plugin. The
+    plugin is built with -buildmode=plugin against the same SDK version
+    and exports them as
+
+        var Operations = map[string]func(*s3.S3, string, string) (int64, error){
+            "batch-get": batchGet,
+        }
+
+    each taking the thread's client, the bucket and the key and returning
+    the bytes it moved. Rows are reported under the operation's name.
+
+  - -preset names a canned workload, so the same test can be run at
+    several sites by name. It sets the flags the command line and the
+    environment do not, so "-preset smallfile -t 16" runs it with 16
+    threads. The presets are:
+
+    largeobject: -m cipgdx -z 2G -mpu-threshold 64M -mpu-part-size 64M
+       -mpu-concurrency 8 -t 4 -d 300
+       multi-GB objects put as multipart uploads and streamed back
+    webasset: -m cipMcx -z 1K -z-max 256K -read-pct 95 -zipf 1.1 -cond-pct 30
+       -cond-header date -t 64 -d 60 -slo "99% MGET < 100ms"
+       -slo "95% MGET < 20ms"
+       a CDN origin: small objects, mostly reads of hot objects, a third
+       of them revalidations, judged against latency objectives
+    mltrain: -m cipTdx -z 256M -n 64 -d -1 -mpu-threshold 64M -epochs 3
+       -shard-range 8M -shard-parallel 4 -t 8
+       training data shards read like a PyTorch or TensorFlow S3 data
+       loader, in epochs of shuffled shards read in parallel ranges
+    smallfile: -m ciphldphldx -z 1K -key-depth 4 -t 64 -d 60
+       tiny objects four directories deep, HEADs and listings of them,
+       and a delete and rewrite cycle, all load on the metadata path
+
+  - The 'F' mode is the "does listing degrade as buckets grow" test:
+    "-m ciFc -staircase 1M,10M,100M -staircase-tests gl -d 60" puts
+    objects until the buckets hold 1M, runs a 60 second GET and LIST test,
+    puts the next 9M, tests again and so on. The fill PUTs ignore -d and
+    run until the level is reached. Rows carry the level in a fill_level
+    tag and the objects in an objects tag, to compare the levels by. It
+    expects empty buckets; the tests after it cover every object it put.
+
+  - When cluster capacity rather than time bounds a test, -max-bytes 5T
+    ends each PUT and GET test once it has moved that many bytes, alongside
+    the -n and -d limits. A failed request gives its bytes back.
+
+  - Back to back tests measure each other's aftermath: the compaction and
+    garbage collection a PUT or DELETE test leaves the server can slow the
+    test after it. -phase-gap 30s waits that long before every test but
+    the first, logs the wait, and records it as GapSeconds in the -summary
+    file.
+
+  - Rather than guess a -phase-gap, -settle learns how fast the server
+    answers a trickle of -settle-rate HEAD requests before the first test,
+    and between tests keeps sending them until the median of a
+    -settle-window is within -settle-margin percent of that again, for at
+    most -settle-timeout. The wait follows any -phase-gap and counts
+    toward GapSeconds.
+
+  - -pre-phase-cmd and -post-phase-cmd run a shell command before and after
+    every test, ie "sync; echo 3 > /proc/sys/vm/drop_caches" before each
+    GET test. The command finds the test's -m letters in HSBENCH_PHASE
+    ("pg" for a PUT test overlapped with -overlap), the loop in
+    HSBENCH_LOOP, -run-id in HSBENCH_RUN_ID and pre or post in
+    HSBENCH_HOOK. A failing pre-phase command stops the run, a failing
+    post-phase command is only logged. Neither counts toward -d.
+
+  - Against MinIO, -minio-info logs the mode, servers and drive states and
+    the drives being healed before and after the run, and keeps the server
+    info and healing status as MinIO returned them in the -summary file, so
+    a run can be matched to the state of the cluster it ran on.
+
+  - To see how well the server isolates tenants, -bg-mode runs a noisy
+    neighbour alongside the measured tests: "-m ipg -bg-mode p -bg-rate
+    100M -bg-tests g" keeps putting objects at 100 MB/s while the GET test
+    runs. Only the GET test is reported; BACKGROUND rows log the load the
+    neighbour offered and the load the server took. Its objects go under
+    hsbench-background/ in the buckets and are removed by a 'c' test.
+
+  - The 'y' mode measures replication between two sites of a bucket:
+    "-u http://site-a -replica-u http://site-b -m y" puts each object to
+    site-a and polls site-b every -replica-poll until it serves the whole
+    object. REPLLAG latencies are the replication lag, to within the poll
+    interval. Objects already on the replica count as replicated at the
+    first check, so run it against empty buckets or a fresh -bp.
+
+  - With -delete-verify head (or list) every thread of a 'd' test checks
+    the objects it deleted once it is done, until each is gone or
+    -delete-verify-timeout passes. DELGONE reports the time from the end
+    of each delete to the check that no longer saw the object, so its
+    latencies are the visibility lag rounded up to -delete-verify-poll.
+    Objects still seen at the timeout fail the test.
+
+  - An -slo such as "99% GET < 20ms" is checked every interval and for
+    the whole test. Failed requests count as slow. The error budget is
+    the share of requests allowed to be slow, 1% of them here, and the
+    budget left shows how much of it was not used; it goes negative once
+    the objective is missed. The totals go to the -summary file.
+
+  - hsbench exits with 0 when every test passed, 1 on an unexpected fatal
+    error, 2 on invalid options, 3 when a test failed because threads
+    aborted, and 4 when a test missed one of the -sla-* thresholds or an
+    -slo. The -sla-* thresholds judge the tests named in -sla-modes, by
+    default every test but the bucket ones, ie BINIT and BCLR, and the
+    waits reported after another test, RESTORED, REPLLAG, DELGONE and
+    EXPIRE.
 `
 	myflag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "\nUSAGE: %s [OPTIONS]\n\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "OPTIONS:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "\nUSAGE: %s [run|prefill|clean] [OPTIONS]\n       %s <command> [OPTIONS]\n", os.Args[0], os.Args[0])
+		fmt.Fprint(flag.CommandLine.Output(), commandsUsage)
+		fmt.Fprintf(flag.CommandLine.Output(), "\nOPTIONS:\n")
 		myflag.PrintDefaults()
-		fmt.Fprintf(flag.CommandLine.Output(), notes)
+		fmt.Fprint(flag.CommandLine.Output(), notes)
 	}
 
-	if err := myflag.Parse(os.Args[1:]); err != nil {
-		os.Exit(1)
+	if err := myflag.Parse(benchmarkArgs()); err != nil {
+		os.Exit(exitConfigError)
 	}
+	setupCommand(myflag)
+	if err := applyEnv(myflag, "HSBENCH_"); err != nil {
+		configFatal(err)
+	}
+	if err := applyPreset(myflag); err != nil {
+		configFatal(err)
+	}
+	setupLogging()
+	myflag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "a", "s", "rgw-admin-a", "rgw-admin-s", "minio-admin-a", "minio-admin-s":
+			value = "<redacted>"
+		}
+		runConfig = append(runConfig, configParam{f.Name, value})
+	})
+	seedSet, sdSet := false, false
+	myflag.Visit(func(f *flag.Flag) {
+		seedSet = seedSet || f.Name == "seed"
+		sdSet = sdSet || f.Name == "sd"
+	})
+	setupSeed(seedSet, sdSet)
 
 	// Check the arguments
-	if object_count < 0 && duration_secs < 0 {
-		log.Fatal("The number of objects and duration can not both be unlimited")
+	var err error
+	if object_count < 0 && duration_secs < 0 && !existing_objects && manifest_in == "" {
+		configFatal("The number of objects and duration can not both be unlimited")
+	}
+	if cred_command != "" && cred_file != "" {
+		configFatal("Only one of -cred-command and -cred-file can be used")
+	}
+	if cred_refresh <= 0 {
+		configFatal("The -cred-refresh argument must be above zero")
+	}
+	if access_key, err = readSecret(access_key); err != nil {
+		configFatalf("Invalid -a argument: %v", err)
+	}
+	if secret_key, err = readSecret(secret_key); err != nil {
+		configFatalf("Invalid -s argument: %v", err)
 	}
-	if access_key == "" {
-		log.Fatal("Missing argument -a for access key.")
+	if rgw_admin_key, err = readSecret(rgw_admin_key); err != nil {
+		configFatalf("Invalid -rgw-admin-a argument: %v", err)
 	}
-	if secret_key == "" {
-		log.Fatal("Missing argument -s for secret key.")
+	if rgw_admin_secret, err = readSecret(rgw_admin_secret); err != nil {
+		configFatalf("Invalid -rgw-admin-s argument: %v", err)
+	}
+	if (rgw_admin_key == "") != (rgw_admin_secret == "") {
+		configFatal("The -rgw-admin-a and -rgw-admin-s arguments must be given together")
+	}
+	if minio_admin_key, err = readSecret(minio_admin_key); err != nil {
+		configFatalf("Invalid -minio-admin-a argument: %v", err)
+	}
+	if minio_admin_secret, err = readSecret(minio_admin_secret); err != nil {
+		configFatalf("Invalid -minio-admin-s argument: %v", err)
+	}
+	if (minio_admin_key == "") != (minio_admin_secret == "") {
+		configFatal("The -minio-admin-a and -minio-admin-s arguments must be given together")
+	}
+	if access_key == "" && cred_command == "" && cred_file == "" {
+		configFatal("Missing argument -a for access key.")
+	}
+	if secret_key == "" && cred_command == "" && cred_file == "" {
+		configFatal("Missing argument -s for secret key.")
 	}
 	if url_host == "" {
-		log.Fatal("Missing argument -u for host endpoint.")
+		configFatal("Missing argument -u for host endpoint.")
+	}
+	if endpoints, err = parseEndpoints(url_host); err != nil {
+		configFatalf("Invalid -u argument: %v", err)
+	}
+	url_host = endpoints[0].url.String()
+	if grow && key_shard {
+		configFatal("The -grow and -key-shard arguments can not be used together")
+	}
+	grow_step = object_count
+	if key_depth < 0 {
+		configFatal("The -key-depth argument must not be negative")
+	}
+	if phase_gap < 0 {
+		configFatal("The -phase-gap argument must not be negative")
+	}
+	if settle_rate <= 0 || settle_window <= 0 || settle_margin < 0 || settle_timeout <= 0 {
+		configFatal("The -settle-rate, -settle-window and -settle-timeout arguments must be above zero and -settle-margin not negative")
+	}
+	if failover_after < 1 || failover_recheck <= 0 {
+		configFatal("The -failover-after argument must be at least 1 and -failover-recheck above zero")
+	}
+	if existing_objects && manifest_in != "" {
+		configFatal("Only one of -existing-objects and -manifest-in can be used")
+	}
+	if manifest_in != "" {
+		if object_index, err = readManifest(manifest_in); err != nil {
+			configFatalf("Invalid -manifest-in file: %v", err)
+		}
+		if object_count < 0 {
+			object_count = int64(len(object_index))
+		}
+	}
+	if quota_size_arg != "" {
+		if quota_size, err = bytefmt.ToBytes(quota_size_arg); err != nil {
+			configFatalf("Invalid -quota-size argument: %v", err)
+		}
+	}
+	if quota_scope != "user" && quota_scope != "bucket" {
+		configFatalf("Invalid -quota-scope argument %q, must be user or bucket", quota_scope)
+	}
+	if profile_file != "" {
+		if load_profile, err = readProfile(profile_file); err != nil {
+			configFatalf("Invalid -profile file: %v", err)
+		}
+	}
+	if arrival != "fixed" && arrival != "exponential" && arrival != "pareto" {
+		configFatalf("Invalid -arrival argument %q, must be fixed, exponential or pareto", arrival)
+	}
+	if pareto_shape <= 1 {
+		configFatal("The -pareto-shape argument must be above 1")
+	}
+	if burst_arg != "" {
+		if burst_on, burst_period, err = parseBurst(burst_arg); err != nil {
+			configFatalf("Invalid -burst argument %q: %v", burst_arg, err)
+		}
+	}
+	if think_time < 0 {
+		configFatal("The -think-time argument can not be negative")
+	}
+	if duty_cycle, err = parseFraction(duty_cycle_arg); err != nil || duty_cycle <= 0 || duty_cycle > 1 {
+		configFatalf("Invalid -duty-cycle argument %q, must be a percentage above 0%% and up to 100%%", duty_cycle_arg)
+	}
+	if delete_verify != "" && delete_verify != "head" && delete_verify != "list" {
+		configFatalf("Invalid -delete-verify argument %q, must be head or list", delete_verify)
+	}
+	if delete_verify_poll <= 0 {
+		configFatal("Invalid -delete-verify-poll argument, must be positive")
+	}
+	if clock_skew != "warn" && clock_skew != "correct" && clock_skew != "ignore" {
+		configFatalf("Invalid -clock-skew argument %q, must be warn, correct or ignore", clock_skew)
+	}
+	if run_tags, err = parseTags(tag_args); err != nil {
+		configFatalf("Invalid -tag argument: %v", err)
+	}
+	if bucket_shards < 0 {
+		configFatal("The -bucket-shards argument can not be negative")
+	}
+	tagBucketShards()
+	if output_mode != "append" && output_mode != "new" && output_mode != "overwrite" {
+		configFatalf("Invalid -output-mode argument %q, must be append, new or overwrite", output_mode)
+	}
+	if soak_rotate < 0 || soak_summary <= 0 {
+		configFatal("The -soak-rotate argument can not be negative and -soak-summary must be above zero")
+	}
+	if soak_rotate > 0 && (interval <= 0 || output_detail != "interval" || heatmap_output != "") {
+		configFatal("A -soak-rotate test needs report intervals (-ri), -output-detail interval and no -heatmap")
+	}
+	if output_detail != "interval" && output_detail != "thread" && output_detail != "bucket" {
+		configFatalf("Invalid -output-detail argument %q, must be interval, thread or bucket", output_detail)
+	}
+	if integrity && (checksum_algorithm != "" || select_format != "" || zero_object_data) {
+		configFatal("The -integrity data can not be combined with -checksum, -select-format or -zd")
+	}
+	if source_arg != "" && (integrity || checksum_algorithm != "" || select_format != "" || zero_object_data) {
+		configFatal("The -source data can not be combined with -integrity, -checksum, -select-format or -zd")
+	}
+	if data_pool < 1 {
+		configFatal("The -data-pool argument must be at least 1")
+	}
+	if data_pool > 1 && (source_arg != "" || integrity || select_format != "" || zero_object_data) {
+		configFatal("The -data-pool buffers can not be combined with -source, -integrity, -select-format or -zd")
+	}
+	if verify_range < 0 {
+		configFatal("The -verify-range argument can not be negative")
+	}
+	if sse_algorithm != s3.ServerSideEncryptionAes256 && sse_algorithm != s3.ServerSideEncryptionAwsKms {
+		configFatalf("Invalid -sse argument %q, must be AES256 or aws:kms", sse_algorithm)
+	}
+	if sse_kms_key != "" && sse_algorithm != s3.ServerSideEncryptionAwsKms {
+		configFatal("The -sse-kms-key argument needs -sse aws:kms")
+	}
+	if cond_header != "etag" && cond_header != "date" {
+		configFatalf("Invalid -cond-header argument %q, must be etag or date", cond_header)
+	}
+	if select_format != "" && select_format != "csv" && select_format != "json" {
+		configFatalf("Invalid -select-format argument %q, must be csv or json", select_format)
+	}
+	if strings.ContainsRune(modes, 's') && select_format == "" {
+		configFatal("The 's' mode requires -select-format")
+	}
+	checksum_algorithm = strings.ToUpper(checksum_algorithm)
+	if checksum_algorithm != "" && newChecksumHash(checksum_algorithm) == nil {
+		configFatalf("Invalid -checksum argument %q, must be CRC32, CRC32C, SHA1 or SHA256", checksum_algorithm)
+	}
+	if policy_file != "" {
+		data, err := os.ReadFile(policy_file)
+		if err != nil {
+			configFatalf("Unable to read -policy file: %v", err)
+		}
+		bucket_policy = string(data)
+	}
+	if ip_version != "4" && ip_version != "6" && ip_version != "dual" {
+		configFatalf("Invalid -ip-version argument %q, must be 4, 6 or dual", ip_version)
+	}
+	if kill_interval < 0 || kill_fraction < 0 || kill_fraction > 1 {
+		configFatal("The -kill-interval argument can not be negative and -kill-fraction must be between 0 and 1")
+	}
+	if max_conns < 0 || max_idle_conns < 0 || conn_lifetime < 0 {
+		configFatal("Connection limits and lifetime passed to -max-conns, -max-idle-conns and -conn-lifetime can not be negative")
 	}
 	invalid_mode := false
 	for _, r := range modes {
 		if r != 'i' &&
+			r != 'e' &&
+			r != 'V' &&
 			r != 'c' &&
 			r != 'p' &&
 			r != 'g' &&
+			r != 'v' &&
+			r != 's' &&
+			r != 'a' &&
+			r != 'h' &&
+			r != 'T' &&
+			r != 'q' &&
+			r != 'o' &&
+			r != 'r' &&
+			r != 'y' &&
+			r != 'F' &&
+			r != 'M' &&
+			r != 'S' &&
+			r != 'B' &&
+			r != 'u' &&
+			r != 'k' &&
+			r != 'P' &&
+			r != 'G' &&
+			r != 'D' &&
+			r != 'A' &&
+			r != 'R' &&
 			r != 'l' &&
+			r != 'w' &&
+			r != 'E' &&
 			r != 'd' &&
 			r != 'x' {
 			s := fmt.Sprintf("Invalid mode '%s' passed to -m", string(r))
-			log.Printf(s)
+			logErrorf("%s", s)
 			invalid_mode = true
 		}
 	}
 	if invalid_mode {
-		log.Fatal("Invalid modes passed to -m, see help for details.")
+		configFatal("Invalid modes passed to -m, see help for details.")
+	}
+	if strings.ContainsRune(modes, 'q') {
+		if script, err = parseScript(script_arg); err != nil {
+			configFatalf("Invalid -script argument: %v", err)
+		}
+	}
+	if strings.ContainsRune(modes, 'F') {
+		if staircase_levels, err = parseStaircase(staircase_arg); err != nil || staircase_arg == "" {
+			configFatalf("The 'F' mode needs rising -staircase levels, ie 1M,10M,100M: %v", err)
+		}
+		if staircase_tests == "" || strings.Trim(staircase_tests, "lwgvsahVBSuPGDARe") != "" {
+			configFatalf("Invalid -staircase-tests argument %q, must be tests that neither add nor remove objects", staircase_tests)
+		}
+		if key_shard {
+			configFatal("The 'F' mode can not be used with -key-shard")
+		}
+	}
+	if strings.ContainsRune(modes, 'y') && replica_endpoint == "" {
+		configFatal("The 'y' mode needs the -replica-u endpoint to poll")
+	}
+	if replica_check != "head" && replica_check != "get" {
+		configFatalf("Invalid -replica-check argument %q, must be head or get", replica_check)
+	}
+	if replica_poll <= 0 {
+		configFatal("Invalid -replica-poll argument, must be positive")
+	}
+	for _, arg := range slo_args {
+		s, err := parseSLO(arg)
+		if err != nil {
+			configFatalf("Invalid -slo argument: %v", err)
+		}
+		slos = append(slos, s)
+	}
+	if sla_modes_arg != "" {
+		sla_modes = make(map[string]bool)
+		for _, m := range strings.Split(sla_modes_arg, ",") {
+			sla_modes[strings.ToUpper(strings.TrimSpace(m))] = true
+		}
+	}
+	if contention_keys < 1 {
+		configFatal("The -contention-keys argument must be at least 1")
+	}
+	if rmw_region < 0 {
+		configFatal("The -rmw-region argument can not be negative")
+	}
+	if subset, err = parseFraction(subset_arg); err != nil || subset <= 0 || subset > 1 {
+		configFatalf("Invalid -subset argument %q, must be a percentage above 0%% and up to 100%%", subset_arg)
+	}
+	if overlap_arg != "" {
+		if overlap, err = parseFraction(overlap_arg); err != nil || overlap < 0 || overlap > 1 {
+			configFatalf("Invalid -overlap argument %q, must be a percentage from 0%% to 100%%", overlap_arg)
+		}
+		if object_count <= 0 || key_shard || !strings.Contains(modes, "pg") {
+			configFatal("An -overlap test needs -n, no -key-shard and a 'p' mode directly followed by 'g' in -m")
+		}
+	}
+	if expire_age < 0 {
+		configFatal("The -expire-age argument can not be negative")
+	}
+	if list_partitions < 1 {
+		configFatal("The -list-partitions argument must be at least 1")
+	}
+	if read_pct < 0 || read_pct > 100 {
+		configFatal("The -read-pct argument must be between 0 and 100")
+	}
+	if write_overlap < 0 || write_overlap > 1 {
+		configFatal("The -write-overlap argument must be between 0 and 1")
+	}
+	if m := strings.IndexRune(modes, 'M'); m >= 0 && object_count < 0 && !strings.ContainsRune(modes[:m], 'p') {
+		configFatal("The 'M' mode needs -n or an earlier 'p' test to know which objects to read")
 	}
-	var err error
 	var size uint64
 	if size, err = bytefmt.ToBytes(sizeArg); err != nil {
-		log.Fatalf("Invalid -z argument for object size: %v", err)
+		configFatalf("Invalid -z argument for object size: %v", err)
 	}
 	object_size = int64(size)
+	if object_size_max_arg != "" {
+		if size, err = bytefmt.ToBytes(object_size_max_arg); err != nil || int64(size) < object_size {
+			configFatalf("Invalid -z-max argument %q, must be at least -z", object_size_max_arg)
+		}
+		object_size_max = int64(size)
+		if integrity || checksum_algorithm != "" || select_format != "" || source_arg != "" {
+			configFatal("Objects of -z-max sizes can not be used with -integrity, -checksum, -select-format or -source")
+		}
+		if cond_header == "etag" && (strings.ContainsRune(modes, 'v') || cond_pct > 0) {
+			configFatal("Objects of -z-max sizes have no ETag known ahead, use -cond-header date")
+		}
+	}
+	if key_zipf != 0 && key_zipf <= 1 {
+		configFatal("The -zipf argument must be above 1, or 0 for uniform picks")
+	}
+	if cond_pct < 0 || cond_pct > 100 {
+		configFatal("The -cond-pct argument must be between 0 and 100")
+	}
+	if bg_mode != "" && bg_mode != "p" && bg_mode != "g" {
+		configFatalf("Invalid -bg-mode argument %q, must be p or g", bg_mode)
+	}
+	if bg_threads < 1 {
+		configFatal("The -bg-threads argument must be at least 1")
+	}
+	if bg_rate_arg != "0" {
+		if size, err = bytefmt.ToBytes(bg_rate_arg); err != nil {
+			configFatalf("Invalid -bg-rate argument %q", bg_rate_arg)
+		}
+		bg_rate = int64(size)
+	}
+	if per_conn_bw_arg != "0" {
+		if size, err = bytefmt.ToBytes(strings.TrimSuffix(per_conn_bw_arg, "/s")); err != nil || size == 0 {
+			configFatalf("Invalid -per-conn-bw argument %q", per_conn_bw_arg)
+		}
+		per_conn_bw = int64(size)
+	}
+	if size, err = bytefmt.ToBytes(shard_range_arg); err != nil || size == 0 {
+		configFatalf("Invalid -shard-range argument %q", shard_range_arg)
+	}
+	shard_range = int64(size)
+	if epochs < 1 || shard_parallel < 1 {
+		configFatal("The -epochs and -shard-parallel arguments must be at least 1")
+	}
+	if mpu_threshold_arg != "" {
+		if size, err = bytefmt.ToBytes(mpu_threshold_arg); err != nil || size == 0 {
+			configFatalf("Invalid -mpu-threshold argument %q", mpu_threshold_arg)
+		}
+		mpu_threshold = int64(size)
+	}
+	if size, err = bytefmt.ToBytes(mpu_part_size_arg); err != nil || int64(size) < s3manager.MinUploadPartSize {
+		configFatalf("Invalid -mpu-part-size argument %q, must be at least 5M", mpu_part_size_arg)
+	}
+	mpu_part_size = int64(size)
+	if mpu_concurrency < 1 {
+		configFatal("The -mpu-concurrency argument must be at least 1")
+	}
+	if useMultipart(payloadSize()) && (checksum_algorithm != "" || upload_mode != "buffered") {
+		configFatal("Multipart uploads with -mpu-threshold can not be used with -checksum or -upload-mode")
+	}
+	if max_bytes_arg != "" {
+		if size, err = bytefmt.ToBytes(max_bytes_arg); err != nil || size == 0 {
+			configFatalf("Invalid -max-bytes argument %q", max_bytes_arg)
+		}
+		max_bytes = int64(size)
+	}
+	bg_size = object_size
+	if bg_size_arg != "" {
+		if size, err = bytefmt.ToBytes(bg_size_arg); err != nil {
+			configFatalf("Invalid -bg-z argument %q", bg_size_arg)
+		}
+		bg_size = int64(size)
+	}
+	if size, err = bytefmt.ToBytes(block_size_arg); err != nil || size == 0 {
+		configFatalf("Invalid -block-size argument %q", block_size_arg)
+	}
+	block_size = int64(size)
+	if size, err = bytefmt.ToBytes(chunk_size_arg); err != nil || size == 0 {
+		configFatalf("Invalid -chunk-size argument %q", chunk_size_arg)
+	}
+	chunk_size = int64(size)
+	if cpu_sets, err = parseCPUSets(cpu_set_args); err != nil {
+		configFatalf("Invalid -cpu-set argument: %v", err)
+	}
+	if gomaxprocs < 0 {
+		configFatal("The -gomaxprocs argument can not be negative")
+	}
+	if err = checkGOGC(gogc); err != nil {
+		configFatalf("Invalid -gogc argument: %v", err)
+	}
+	if size, err = bytefmt.ToBytes(read_buffer_arg); err != nil || size == 0 {
+		configFatalf("Invalid -read-buffer argument %q", read_buffer_arg)
+	}
+	read_buffer = int64(size)
+	if source_arg != "" {
+		if err = openSource(); err != nil {
+			configFatalf("Invalid -source argument: %v", err)
+		}
+		if content_md5 && !sourceSeekable() {
+			configFatal("The -content-md5 hash needs a -source that can be read again, not a stream")
+		}
+	}
+	if upload_mode != "buffered" && upload_mode != "chunked" && upload_mode != "aws-chunked" {
+		configFatalf("Invalid -upload-mode argument %q, must be buffered, chunked or aws-chunked", upload_mode)
+	}
+	if checksum_algorithm != "" && (upload_mode != "buffered" || sign_payload) {
+		configFatal("The -checksum trailer can not be combined with -upload-mode chunked or aws-chunked or -sign-payload")
+	}
+	if placement_file != "" {
+		if placements, err = readPlacements(placement_file); err != nil {
+			configFatalf("Invalid -placement file: %v", err)
+		}
+	}
+	if bucket_file != "" {
+		if bucket_names, err = readBucketFile(bucket_file); err != nil {
+			configFatalf("Invalid -bucket-file: %v", err)
+		}
+		bucket_count = int64(len(bucket_names))
+	} else if bucket_template != "" && !strings.Contains(bucket_template, "{n") && bucket_count > 1 {
+		configFatal("The -bucket-template argument must contain {n} to name more than one bucket")
+	}
+	if workers_file != "" {
+		groups, err := readWorkers(workers_file)
+		if err != nil {
+			configFatalf("Invalid -workers file: %v", err)
+		}
+		setupWorkers(groups)
+	}
 	listContinuationToken = make([]*string, bucket_count)
 	listBucketComplete = make([]bool, bucket_count)
-	log.Printf("list %v", listContinuationToken)
+	logDebugf("list %v", listContinuationToken)
 }
 
 func initData() {
 	// Initialize data for the bucket
-	object_data = make([]byte, object_size)
-	if zero_object_data {
-		for i := range object_data {
-			object_data[i] = 0
+	if source_file != nil {
+		return
+	}
+	object_pool = make([]payloadData, data_pool)
+	for i := range object_pool {
+		object_pool[i] = makePayload()
+	}
+}
+
+// makeHTTPClient -- build the HTTP client shared by all S3 sessions
+func makeHTTPClient() *http.Client {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		ForceAttemptHTTP2:   force_http1,
+		MaxConnsPerHost:     max_conns,
+		MaxIdleConnsPerHost: max_idle_conns,
+		DisableKeepAlives:   disable_keepalive,
+	}
+	transport.DialContext = makeDialer()
+	if per_conn_bw > 0 {
+		if transport.DialContext == nil {
+			transport.DialContext = netDialer().DialContext
 		}
-	} else {
-		rand.Read(object_data)
+		transport.DialContext = shapingDialer(transport.DialContext)
 	}
-	hasher := md5.New()
-	hasher.Write(object_data)
-	object_data_md5 = base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+	if kill_interval > 0 {
+		if transport.DialContext == nil {
+			transport.DialContext = netDialer().DialContext
+		}
+		transport.DialContext = trackingDialer(transport.DialContext)
+		startKiller(transport)
+	}
+	if conn_lifetime > 0 {
+		lifetime := time.Duration(conn_lifetime * float64(time.Second))
+		transport.IdleConnTimeout = lifetime
+		if transport.DialContext == nil {
+			transport.DialContext = netDialer().DialContext
+		}
+		transport.DialContext = agingDialer(transport.DialContext)
+		return &http.Client{Transport: &lifetimeTransport{transport, lifetime}}
+	}
+	return &http.Client{Transport: transport}
 }
 
 func main() {
+	if subcommand != nil {
+		os.Exit(subcommand(os.Args[2:]))
+	}
 	// Hello
-	log.Printf("Hotsauce S3 Benchmark Version 0.1")
+	logInfof("Hotsauce S3 Benchmark Version 0.1")
+	setupOperations()
 
 	cfg = &aws.Config{
 		Endpoint:    aws.String(url_host),
-		Credentials: credentials.NewStaticCredentials(access_key, secret_key, ""),
+		Credentials: makeCredentials(),
 		Region:      aws.String(region),
 		// DisableParamValidation:  aws.Bool(true),
 		DisableComputeChecksums: aws.Bool(true),
 		S3ForcePathStyle:        aws.Bool(true),
-		HTTPClient: &http.Client{
-			Transport: &http.Transport{
-				ForceAttemptHTTP2: force_http1,
-			},
-		},
+		HTTPClient:              makeHTTPClient(),
+		Retryer:                 throttleRetryer{client.DefaultRetryer{NumMaxRetries: client.DefaultRetryerMaxNumRetries}},
 	}
 
 	// Echo the parameters
-	log.Printf("Parameters:")
-	log.Printf("url=%s", url_host)
-	log.Printf("object_prefix=%s", object_prefix)
-	log.Printf("bucket_prefix=%s", bucket_prefix)
-	log.Printf("region=%s", region)
-	log.Printf("modes=%s", modes)
-	log.Printf("output=%s", output)
-	log.Printf("json_output=%s", json_output)
-	log.Printf("max_keys=%d", max_keys)
-	log.Printf("object_count=%d", object_count)
-	log.Printf("bucket_count=%d", bucket_count)
-	log.Printf("duration=%d", duration_secs)
-	log.Printf("threads=%d", threads)
-	log.Printf("loops=%d", loops)
-	log.Printf("size=%s", sizeArg)
-	log.Printf("interval=%f", interval)
-	log.Printf("force_http1=%t", force_http1)
-	log.Printf("randomize_suffix=%t", randomize_suffix)
-	log.Printf("randomize_seed=%d", randomize_seed)
+	logInfof("Parameters:")
+	logInfof("url=%s", url_host)
+	logInfof("cred_command=%s", cred_command)
+	logInfof("cred_file=%s", cred_file)
+	logInfof("cred_refresh=%s", cred_refresh)
+	if len(endpoints) > 1 {
+		for _, e := range endpoints[1:] {
+			logInfof("url=%s", e.url)
+		}
+		logInfof("failover_after=%d", failover_after)
+		logInfof("failover_recheck=%s", failover_recheck)
+	}
+	logInfof("preset=%s", preset)
+	logInfof("dialer=%s", dialer_arg)
+	logInfof("ip_version=%s", ip_version)
+	logInfof("per_conn_bw=%d", per_conn_bw)
+	logInfof("object_prefix=%s", object_prefix)
+	logInfof("key_depth=%d", key_depth)
+	logInfof("bucket_prefix=%s", bucket_prefix)
+	logInfof("bucket_template=%s", bucket_template)
+	logInfof("bucket_file=%s", bucket_file)
+	logInfof("placement=%s", placement_file)
+	logInfof("minio_info=%t", minio_info)
+	logInfof("minio_admin_endpoint=%s", minio_admin_endpoint)
+	logInfof("rgw_stats=%t", rgw_stats)
+	logInfof("rgw_admin_endpoint=%s", rgw_admin_endpoint)
+	logInfof("bucket_shards=%d", bucket_shards)
+	logInfof("shard_command=%s", shard_command)
+	logInfof("phase_gap=%s", phase_gap)
+	logInfof("settle=%t", settle)
+	logInfof("settle_rate=%.1f", settle_rate)
+	logInfof("settle_window=%s", settle_window)
+	logInfof("settle_margin=%.1f", settle_margin)
+	logInfof("settle_timeout=%s", settle_timeout)
+	logInfof("pre_phase_cmd=%s", pre_phase_cmd)
+	logInfof("post_phase_cmd=%s", post_phase_cmd)
+	logInfof("bg_mode=%s", bg_mode)
+	logInfof("bg_tests=%s", bg_tests)
+	logInfof("bg_threads=%d", bg_threads)
+	logInfof("bg_rate=%d", bg_rate)
+	logInfof("bg_size=%d", bg_size)
+	logInfof("workers=%s", workers_file)
+	for _, g := range worker_groups {
+		logInfof("worker group %s: threads=%d endpoint=%s buckets=%v rate=%.0f read_pct=%.0f", g.name, g.threads, g.endpoint, g.buckets, g.rate, g.read_pct)
+	}
+	logInfof("region=%s", region)
+	logInfof("modes=%s", modes)
+	logInfof("output=%s", output)
+	logInfof("json_output=%s", json_output)
+	logInfof("output_detail=%s", output_detail)
+	logInfof("report=%s", report_output)
+	logInfof("md=%s", markdown_output)
+	logInfof("control=%s", control_addr)
+	logInfof("run_id=%s", run_id)
+	logInfof("tags=%s", formatTags(run_tags))
+	logInfof("sinks=%s", sink_args.String())
+	logInfof("output_mode=%s", output_mode)
+	logInfof("soak_rotate=%s", soak_rotate)
+	logInfof("soak_summary=%s", soak_summary)
+	logInfof("heatmap=%s", heatmap_output)
+	logInfof("top_slowest=%d", top_slowest)
+	logInfof("max_keys=%d", max_keys)
+	logInfof("list_partitions=%d", list_partitions)
+	logInfof("list_check=%t", list_check)
+	logInfof("walk_delimiter=%s", walk_delimiter)
+	logInfof("expire_age=%s", expire_age)
+	logInfof("object_count=%d", object_count)
+	logInfof("max_bytes=%d", max_bytes)
+	logInfof("operation=%s", operation_name)
+	logInfof("plugins=%s", strings.Join(plugin_args, ","))
+	logInfof("script=%s", script_arg)
+	logInfof("epochs=%d", epochs)
+	logInfof("shard_range=%d", shard_range)
+	logInfof("shard_parallel=%d", shard_parallel)
+	logInfof("mpu_threshold=%d", mpu_threshold)
+	logInfof("mpu_part_size=%d", mpu_part_size)
+	logInfof("mpu_concurrency=%d", mpu_concurrency)
+	logInfof("bucket_count=%d", bucket_count)
+	logInfof("duration=%d", duration_secs)
+	logInfof("threads=%d", threads)
+	logInfof("loops=%d", loops)
+	logInfof("size=%s", sizeArg)
+	logInfof("size_max=%d", object_size_max)
+	logInfof("interval=%f", interval)
+	logInfof("force_http1=%t", force_http1)
+	logInfof("gomaxprocs=%d", gomaxprocs)
+	logInfof("gogc=%s", gogc)
+	logInfof("cpu_sets=%s", cpu_set_args.String())
+	logInfof("max_conns=%d", max_conns)
+	logInfof("max_idle_conns=%d", max_idle_conns)
+	logInfof("conn_lifetime=%f", conn_lifetime)
+	logInfof("kill_interval=%s", kill_interval)
+	logInfof("kill_fraction=%f", kill_fraction)
+	logInfof("kill_idle_only=%t", kill_idle_only)
+	logInfof("disable_keepalive=%t", disable_keepalive)
+	logInfof("headers=%s", request_headers.String())
+	logInfof("content_types=%s", content_types.String())
+	logInfof("cache_controls=%s", cache_controls.String())
+	logInfof("content_dispositions=%s", content_dispositions.String())
+	logInfof("cond_header=%s", cond_header)
+	logInfof("cache_headers=%s", cache_headers.String())
+	logInfof("select_format=%s", select_format)
+	logInfof("source=%s", source_arg)
+	logInfof("data_pool=%d", data_pool)
+	logInfof("grow=%t", grow)
+	logInfof("key_shard=%t", key_shard)
+	logInfof("subset=%.0f%%", subset*100)
+	logInfof("overlap=%s", overlap_arg)
+	logInfof("select_expr=%s", select_expr)
+	logInfof("checksum=%s", checksum_algorithm)
+	logInfof("content_md5=%t", content_md5)
+	logInfof("upload_mode=%s", upload_mode)
+	logInfof("chunk_size=%d", chunk_size)
+	logInfof("read_buffer=%d", read_buffer)
+	logInfof("integrity=%t", integrity)
+	logInfof("verify_range=%d", verify_range)
+	logInfof("sse=%s", sse_algorithm)
+	logInfof("sse_kms_key=%s", sse_kms_key)
+	logInfof("sse_bucket_key=%t", sse_bucket_key)
+	logInfof("policy=%s", policy_file)
+	logInfof("acl=%s", object_acl)
+	logInfof("notify_topic=%s", notify_topic)
+	logInfof("notify_events=%s", notify_events.String())
+	logInfof("restore_days=%d", restore_days)
+	logInfof("restore_tier=%s", restore_tier)
+	logInfof("restore_poll=%f", restore_poll)
+	logInfof("restore_timeout=%f", restore_timeout)
+	logInfof("staircase=%s", staircase_arg)
+	logInfof("staircase_tests=%s", staircase_tests)
+	logInfof("replica_endpoint=%s", replica_endpoint)
+	logInfof("replica_check=%s", replica_check)
+	logInfof("replica_poll=%s", replica_poll)
+	logInfof("replica_timeout=%s", replica_timeout)
+	logInfof("delete_verify=%s", delete_verify)
+	logInfof("delete_verify_poll=%s", delete_verify_poll)
+	logInfof("delete_verify_timeout=%s", delete_verify_timeout)
+	logInfof("block_size=%d", block_size)
+	logInfof("block_objects=%d", block_objects)
+	logInfof("contention_keys=%d", contention_keys)
+	logInfof("contention_conditional=%t", contention_conditional)
+	logInfof("rmw_region=%d", rmw_region)
+	logInfof("sign_payload=%t", sign_payload)
+	logInfof("read_pct=%f", read_pct)
+	logInfof("write_overlap=%f", write_overlap)
+	logInfof("zipf=%f", key_zipf)
+	logInfof("cond_pct=%f", cond_pct)
+	logInfof("manifest_out=%s", manifest_out)
+	logInfof("manifest_in=%s", manifest_in)
+	logInfof("existing_objects=%t", existing_objects)
+	logInfof("quota_objects=%d", quota_objects)
+	logInfof("quota_size=%s", quota_size_arg)
+	logInfof("quota_scope=%s", quota_scope)
+	logInfof("profile=%s", profile_file)
+	logInfof("arrival=%s", arrival)
+	if arrival == "pareto" {
+		logInfof("pareto_shape=%f", pareto_shape)
+	}
+	logInfof("burst=%s", burst_arg)
+	logInfof("think_time=%s", think_time)
+	logInfof("duty_cycle=%.0f%%", duty_cycle*100)
+	logInfof("backoff_base=%f", backoff_base)
+	logInfof("backoff_max=%f", backoff_max)
+	logInfof("clock_skew=%s", clock_skew)
+	logInfof("max_clock_skew=%f", max_clock_skew)
+	logInfof("probe=%t", probe)
+	logInfof("summary=%s", summary_output)
+	logInfof("history_db=%s", history_db)
+	for _, s := range slos {
+		logInfof("slo=%s", s.spec)
+	}
+	logInfof("sla_lat99=%f", sla_max_lat99)
+	logInfof("sla_iops=%f", sla_min_iops)
+	logInfof("sla_mbps=%f", sla_min_mbps)
+	logInfof("sla_modes=%s", sla_modes_arg)
+	logInfof("log_level=%s", log_level)
+	logInfof("log_format=%s", log_format)
+	logInfof("quiet=%t", quiet)
+	logInfof("debug_sample=%f", debug_sample)
+	logInfof("randomize_suffix=%t", randomize_suffix)
+	logInfof("seed=%d", seed)
+	logInfof("randomize_seed=%d", randomize_seed)
+
+	setupRuntime()
 
 	// Init Data
 	initData()
 
 	// Setup the slice of buckets
-	for i := int64(0); i < bucket_count; i++ {
-		buckets = append(buckets, fmt.Sprintf("%s%012d", bucket_prefix, i))
+	setupBuckets()
+
+	if clock_skew != "ignore" {
+		checkClockSkew()
+	}
+	if probe {
+		runProbe()
+	}
+	if existing_objects {
+		discoverObjects()
 	}
 
+	openSinks()
+	if control_addr != "" {
+		startControl()
+	}
+	if manifest_out != "" {
+		openManifest()
+	}
+	minioBeforeRun()
+
 	// Loop running the tests
 	oStats := make([]OutputStats, 0)
 	for loop := 0; loop < loops; loop++ {
-		for _, r := range modes {
-			oStats = append(oStats, runWrapper(loop, r)...)
-		}
-	}
-
-	// Write CSV Output
-	if output != "" {
-		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY, 0777)
-		defer file.Close()
-		if err != nil {
-			log.Fatal("Could not open CSV file for writing.")
-		} else {
-			csvWriter := csv.NewWriter(file)
-			for i, o := range oStats {
-				if i == 0 {
-					o.csv_header(csvWriter)
-				}
-				o.csv(csvWriter)
+		for i, r := range modes {
+			// The GET test after a PUT test ran alongside it
+			if i > 0 && overlapsGet(i-1) {
+				continue
 			}
-			csvWriter.Flush()
-		}
-	}
-
-	// Write JSON output
-	if json_output != "" {
-		file, err := os.OpenFile(json_output, os.O_CREATE|os.O_WRONLY, 0777)
-		defer file.Close()
-		if err != nil {
-			log.Fatal("Could not open JSON file for writing.")
-		}
-		data, err := json.Marshal(oStats)
-		if err != nil {
-			log.Fatal("Error marshaling JSON: ", err)
-		}
-		_, err = file.Write(data)
-		if err != nil {
-			log.Fatal("Error writing to JSON file: ", err)
+			if r == 'F' {
+				oStats = append(oStats, runStaircase(loop, loop == 0 && i == 0)...)
+				continue
+			}
+			overlap_get = overlapsGet(i)
+			phase := string(r)
+			if overlap_get {
+				phase = modes[i : i+2]
+			}
+			oStats = append(oStats, runPhase(loop, r, phase, loop == 0 && i == 0)...)
 		}
-		file.Sync()
 	}
+	closeManifest()
+	minioAfterRun()
+	oStats = append(oStats, aggregateLoops(oStats)...)
+
+	writeSinks(oStats)
+	writeReport(oStats)
+	writeMarkdown(oStats)
+	writeHeatmap()
+	writeSummary()
+	recordHistory()
+	os.Exit(exit_code)
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var integrity bool
+var verify_range int64
+
+// Layout of the 512 byte blocks of -integrity objects:
+//
+//	0    "HSBI"
+//	4    offset of the block in the object, big endian
+//	12   FNV-1a hash of the object key
+//	20   length of the key
+//	22   the key, up to integrityKeyMax bytes
+//	...  filler derived from the offset
+//	508  CRC32 of the bytes before it
+const (
+	integrityBlock  = 512
+	integrityMagic  = "HSBI"
+	integrityKeyMax = 200
+	integrityCRC    = integrityBlock - 4
+)
+
+func keyHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// integrityBlockAt -- fill b with the block of key at offset
+func integrityBlockAt(b []byte, key string, offset int64) {
+	copy(b, integrityMagic)
+	binary.BigEndian.PutUint64(b[4:], uint64(offset))
+	binary.BigEndian.PutUint64(b[12:], keyHash(key))
+	binary.BigEndian.PutUint16(b[20:], uint16(len(key)))
+	n := copy(b[22:22+integrityKeyMax], key)
+	seed := byte(offset / integrityBlock)
+	for i := 22 + n; i < integrityCRC; i++ {
+		b[i] = seed + byte(i)
+	}
+	binary.BigEndian.PutUint32(b[integrityCRC:], crc32.ChecksumIEEE(b[:integrityCRC]))
+}
+
+// fillIntegrity -- fill buf with the -integrity data of key, buf being the
+// whole object
+func fillIntegrity(buf []byte, key string) {
+	var block [integrityBlock]byte
+	for off := 0; off < len(buf); off += integrityBlock {
+		integrityBlockAt(block[:], key, int64(off))
+		copy(buf[off:], block[:])
+	}
+}
+
+// integrityBuffer -- a buffer for a writer thread to make -integrity objects in
+func integrityBuffer() []byte {
+	if !integrity {
+		return nil
+	}
+	return make([]byte, object_size)
+}
+
+// objectBody -- the body of a PUT of key. Without -integrity every object is
+// the payloadBody, with it the data is made for the key in buf.
+func objectBody(buf []byte, key string) io.ReadSeeker {
+	if !integrity {
+		return payloadBody(key)
+	}
+	fillIntegrity(buf, key)
+	return bytes.NewReader(buf)
+}
+
+// verifyIntegrity -- check data read from offset of key block by block,
+// describing the first mismatch
+func verifyIntegrity(data []byte, key string, offset int64) error {
+	var want [integrityBlock]byte
+	pos := offset
+	for len(data) > 0 {
+		blockStart := pos - pos%integrityBlock
+		skip := pos - blockStart
+		n := min(int64(len(data)), integrityBlock-skip)
+		integrityBlockAt(want[:], key, blockStart)
+		got := data[:n]
+		if !bytes.Equal(got, want[skip:skip+n]) {
+			return describeMismatch(got, want[skip:skip+n], key, blockStart, skip)
+		}
+		data = data[n:]
+		pos += n
+	}
+	return nil
+}
+
+// describeMismatch -- say what a bad block holds, when enough of it was read
+// to decode its header
+func describeMismatch(got, want []byte, key string, blockStart, skip int64) error {
+	if skip == 0 && len(got) == integrityBlock {
+		switch {
+		case string(got[:4]) != integrityMagic:
+			return fmt.Errorf("block at offset %d is not -integrity data", blockStart)
+		case crc32.ChecksumIEEE(got[:integrityCRC]) != binary.BigEndian.Uint32(got[integrityCRC:]):
+			return fmt.Errorf("block at offset %d is corrupt, its CRC does not match", blockStart)
+		case binary.BigEndian.Uint64(got[12:]) != keyHash(key):
+			klen := min(int(binary.BigEndian.Uint16(got[20:])), integrityKeyMax)
+			return fmt.Errorf("block at offset %d holds data of key %q offset %d", blockStart, got[22:22+klen], binary.BigEndian.Uint64(got[4:]))
+		case binary.BigEndian.Uint64(got[4:]) != uint64(blockStart):
+			return fmt.Errorf("block at offset %d holds the block of offset %d", blockStart, binary.BigEndian.Uint64(got[4:]))
+		}
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return fmt.Errorf("byte at offset %d differs", blockStart+skip+int64(i))
+		}
+	}
+	return nil
+}
+
+// verifyRead -- check a whole object or -verify-range read for truncation
+// as well as its contents
+func verifyRead(data []byte, key string, offset, size int64) error {
+	want := size
+	if verify_range > 0 && verify_range < size {
+		want = verify_range
+	}
+	if int64(len(data)) != want {
+		return fmt.Errorf("read %d bytes, expected %d", len(data), want)
+	}
+	return verifyIntegrity(data, key, offset)
+}
+
+func (stats *Stats) addCorrupt(thread_num int) {
+	stats.current(thread_num).corrupt++
+	stats.threadStats[thread_num].mu.Unlock()
+}
+
+// runVerify -- read objects, or random -verify-range ranges of them at any
+// offset, and check every byte against the -integrity pattern
+func runVerify(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	buf := make([]byte, 0, object_size)
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		objnum := atomic.AddInt64(&op_counter, 1)
+		if loop_objects && duration_secs > -1 {
+			objnum = objnum % object_count
+		}
+		if object_count > -1 && objnum >= object_count {
+			atomic.AddInt64(&op_counter, -1)
+			break
+		}
+
+		bucket, key, size := objectName(thread_num, objnum, rand)
+		r := &s3.GetObjectInput{Bucket: bucket, Key: &key}
+		offset := int64(0)
+		if verify_range > 0 && verify_range < size {
+			offset = int64(rand.intn(int(size - verify_range + 1)))
+			rng := fmt.Sprintf("bytes=%d-%d", offset, offset+verify_range-1)
+			r.Range = &rng
+		}
+
+		start := time.Now().UnixNano()
+		req, resp := svc.GetObjectRequest(r)
+		err := req.Send()
+		if err == nil {
+			buf = buf[:0]
+			w := bytes.NewBuffer(buf)
+			_, err = io.Copy(w, resp.Body)
+			resp.Body.Close()
+			buf = w.Bytes()
+		}
+		end := time.Now().UnixNano()
+
+		if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, "verify", err) {
+				break
+			}
+		} else if verr := verifyRead(buf, key, offset, size); verr != nil {
+			stats.addCorrupt(thread_num)
+			logWarn("verify mismatch", stats.attrs(thread_num, "error", verr, "bucket", *bucket, "key", key, "first", offset, "last", offset+int64(len(buf))-1)...)
+		} else {
+			stats.addBucketOp(thread_num, *bucket, int64(len(buf)), end-start)
+		}
+		think(start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
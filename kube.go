@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kubeOptions -- the flags of "hsbench kube"
+type kubeOptions struct {
+	name, namespace, image, secret string
+	cpu, memory                    string
+	workers                        int
+	timeout, poll                  time.Duration
+	results, kubectl               string
+	dryRun, cleanup                bool
+}
+
+// runKube -- render an indexed Kubernetes Job running hsbench workers with
+// the given options, create it, wait for it to complete and gather the log
+// of every worker
+func runKube(args []string) int {
+	var k kubeOptions
+	kflag := flag.NewFlagSet("kube", flag.ContinueOnError)
+	kflag.StringVar(&k.name, "name", "hsbench", "Name of the Job")
+	kflag.StringVar(&k.namespace, "namespace", "", "Namespace of the Job, the current kubectl namespace when empty")
+	kflag.StringVar(&k.image, "image", "", "Container image with hsbench as its entrypoint")
+	kflag.StringVar(&k.secret, "secret", "", "Secret with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY keys to pass to the workers instead of -a and -s")
+	kflag.StringVar(&k.cpu, "cpu", "", "CPU requested and limited for each worker, ie 2")
+	kflag.StringVar(&k.memory, "memory", "", "Memory requested and limited for each worker, ie 4Gi")
+	kflag.IntVar(&k.workers, "workers", 1, "Number of workers, all running at once")
+	kflag.DurationVar(&k.timeout, "timeout", time.Hour, "Maximum time to wait for the workers to finish")
+	kflag.DurationVar(&k.poll, "poll", 5*time.Second, "Interval between checks of the Job status")
+	kflag.StringVar(&k.results, "results", ".", "Directory to write the log of every worker to, as <pod>.log")
+	kflag.StringVar(&k.kubectl, "kubectl", "kubectl", "kubectl command used to reach the cluster")
+	kflag.BoolVar(&k.dryRun, "dry-run", false, "Print the Job manifest and exit")
+	kflag.BoolVar(&k.cleanup, "delete", false, "Delete the Job and its pods once the logs are gathered")
+	kflag.Usage = func() {
+		fmt.Fprintf(kflag.Output(), "\nUSAGE: %s kube [OPTIONS] -- [HSBENCH OPTIONS]\n\n", os.Args[0])
+		fmt.Fprintf(kflag.Output(), "OPTIONS:\n")
+		kflag.PrintDefaults()
+		fmt.Fprintf(kflag.Output(), `
+NOTES:
+  - Every worker runs hsbench with the options after --. The index of
+    the worker, 0 to -workers minus 1, is in $(JOB_COMPLETION_INDEX) for
+    options to tell workers apart, ie -bp bench$(JOB_COMPLETION_INDEX)-.
+
+  - Result files written inside the workers are lost with their pods,
+    pass -j /dev/stdout or -summary /dev/stdout to have them in the logs.
+`)
+	}
+	if err := kflag.Parse(args); err != nil {
+		return exitConfigError
+	}
+	if err := applyEnv(kflag, "HSBENCH_KUBE_"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfigError
+	}
+	if k.image == "" {
+		fmt.Fprintf(os.Stderr, "Missing -image argument\n")
+		return exitConfigError
+	}
+	if k.workers < 1 {
+		fmt.Fprintf(os.Stderr, "Invalid -workers argument %d, must be at least 1\n", k.workers)
+		return exitConfigError
+	}
+
+	manifest, err := json.MarshalIndent(kubeJob(&k, kflag.Args()), "", "  ")
+	if err != nil {
+		logFatal("Error marshaling Job manifest: ", err)
+	}
+	if k.dryRun {
+		fmt.Println(string(manifest))
+		return exitOK
+	}
+
+	if _, err := k.run(manifest, "create", "-f", "-"); err != nil {
+		logErrorf("Could not create Job %s: %v", k.name, err)
+		return exitFatal
+	}
+	logInfof("Created Job %s with %d workers", k.name, k.workers)
+	passed := k.wait()
+	if !k.gather() {
+		passed = false
+	}
+	if k.cleanup {
+		if _, err := k.run(nil, "delete", "job", k.name, "--wait=false"); err != nil {
+			logErrorf("Could not delete Job %s: %v", k.name, err)
+		}
+	}
+	if !passed {
+		return exitPhaseFailure
+	}
+	return exitOK
+}
+
+// kubeJob -- the manifest of the Job, one pod per worker and no retries, since
+// a rerun worker would skew the results
+func kubeJob(k *kubeOptions, args []string) map[string]interface{} {
+	container := map[string]interface{}{
+		"name":  "hsbench",
+		"image": k.image,
+		"args":  args,
+		"env": []interface{}{map[string]interface{}{
+			"name": "JOB_COMPLETION_INDEX",
+			"valueFrom": map[string]interface{}{"fieldRef": map[string]interface{}{
+				"fieldPath": "metadata.annotations['batch.kubernetes.io/job-completion-index']"}},
+		}},
+	}
+	if k.secret != "" {
+		container["envFrom"] = []interface{}{map[string]interface{}{
+			"secretRef": map[string]interface{}{"name": k.secret}}}
+	}
+	resources := map[string]interface{}{}
+	if k.cpu != "" {
+		resources["cpu"] = k.cpu
+	}
+	if k.memory != "" {
+		resources["memory"] = k.memory
+	}
+	if len(resources) > 0 {
+		container["resources"] = map[string]interface{}{"requests": resources, "limits": resources}
+	}
+	metadata := map[string]interface{}{"name": k.name, "labels": map[string]interface{}{"app": "hsbench"}}
+	if k.namespace != "" {
+		metadata["namespace"] = k.namespace
+	}
+	return map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"completions":    k.workers,
+			"parallelism":    k.workers,
+			"completionMode": "Indexed",
+			"backoffLimit":   0,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "hsbench"}},
+				"spec": map[string]interface{}{
+					"restartPolicy": "Never",
+					"containers":    []interface{}{container},
+				},
+			},
+		},
+	}
+}
+
+// run -- run kubectl in the Job's namespace with the given input, returning
+// what it prints
+func (k *kubeOptions) run(input []byte, args ...string) ([]byte, error) {
+	if k.namespace != "" {
+		args = append([]string{"--namespace", k.namespace}, args...)
+	}
+	cmd := exec.Command(k.kubectl, args...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// wait -- poll the Job until every worker succeeded, one failed or -timeout
+// passed
+func (k *kubeOptions) wait() bool {
+	deadline := time.Now().Add(k.timeout)
+	for {
+		out, err := k.run(nil, "get", "job", k.name, "-o", "jsonpath={.status.succeeded},{.status.failed}")
+		if err != nil {
+			logWarnf("Could not get the status of Job %s: %v", k.name, err)
+		} else {
+			// Counts not set yet are printed empty
+			var succeeded, failed int
+			counts := strings.SplitN(strings.TrimSpace(string(out)), ",", 2)
+			succeeded, _ = strconv.Atoi(counts[0])
+			if len(counts) > 1 {
+				failed, _ = strconv.Atoi(counts[1])
+			}
+			if failed > 0 {
+				logErrorf("Job %s failed: %d of %d workers failed", k.name, failed, k.workers)
+				return false
+			}
+			if succeeded >= k.workers {
+				logInfof("Job %s completed: %d workers succeeded", k.name, succeeded)
+				return true
+			}
+			logDebugf("Job %s: %d of %d workers succeeded", k.name, succeeded, k.workers)
+		}
+		if time.Now().After(deadline) {
+			logErrorf("Job %s did not complete within %s", k.name, k.timeout)
+			return false
+		}
+		time.Sleep(k.poll)
+	}
+}
+
+// gather -- write the log of every pod of the Job to the -results directory
+func (k *kubeOptions) gather() bool {
+	out, err := k.run(nil, "get", "pods", "-l", "job-name="+k.name, "-o", `jsonpath={range .items[*]}{.metadata.name}{"\n"}{end}`)
+	if err != nil {
+		logErrorf("Could not list the pods of Job %s: %v", k.name, err)
+		return false
+	}
+	if err := os.MkdirAll(k.results, 0755); err != nil {
+		logErrorf("Could not create -results directory: %v", err)
+		return false
+	}
+	ok := true
+	for _, pod := range strings.Fields(string(out)) {
+		podLog, err := k.run(nil, "logs", pod)
+		if err != nil {
+			logErrorf("Could not get the log of pod %s: %v", pod, err)
+			ok = false
+			continue
+		}
+		path := filepath.Join(k.results, pod+".log")
+		if err := os.WriteFile(path, podLog, 0644); err != nil {
+			logErrorf("Could not write %s: %v", path, err)
+			ok = false
+			continue
+		}
+		logInfof("Wrote the log of pod %s to %s", pod, path)
+	}
+	return ok
+}
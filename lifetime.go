@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+var conn_lifetime float64
+
+// agedConn -- a connection remembering when it was opened, for
+// -conn-lifetime
+type agedConn struct {
+	net.Conn
+	opened time.Time
+}
+
+// agingDialer -- wrap a dial function to date the connections it opens
+func agingDialer(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &agedConn{Conn: conn, opened: time.Now()}, nil
+	}
+}
+
+// lifetimeTransport -- retire the connections older than -conn-lifetime.
+// The transport has no notion of a connection's age, so every request is
+// traced and a pooled connection it is handed past its lifetime is closed
+// before the request is written. The transport then sends the request on a
+// new connection, as it does when the server closed a pooled one, so busy
+// connections are recycled between requests rather than in the middle of
+// one.
+type lifetimeTransport struct {
+	http.RoundTripper
+	lifetime time.Duration
+}
+
+func (t *lifetimeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				return
+			}
+			conn := info.Conn
+			if tc, ok := conn.(*tls.Conn); ok {
+				// An HTTP/2 connection carries the requests of other
+				// threads at the same time
+				if tc.ConnectionState().NegotiatedProtocol == "h2" {
+					return
+				}
+				conn = tc.NetConn()
+			}
+			if ac, ok := conn.(*agedConn); ok && time.Since(ac.opened) >= t.lifetime {
+				info.Conn.Close()
+			}
+		},
+	}
+	return t.RoundTripper.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var list_check bool
+
+// maxListProblems is the number of -list-check problems logged one by one
+const maxListProblems = 10
+
+// What -list-check found in the running LIST test
+var list_keys, list_problems int64
+
+// listChecker -- follows the pages of one partition of a bucket listing
+type listChecker struct {
+	bucket string
+	last   string
+	until  string
+}
+
+// resetListCheck -- start counting afresh for a new LIST test
+func resetListCheck() {
+	atomic.StoreInt64(&list_keys, 0)
+	atomic.StoreInt64(&list_problems, 0)
+}
+
+// listProblem -- count a discrepancy, logging the first few
+func listProblem(format string, v ...interface{}) {
+	n := atomic.AddInt64(&list_problems, 1)
+	if n <= maxListProblems {
+		logWarnf("List check: "+format, v...)
+	}
+	if n == maxListProblems {
+		logWarnf("List check: not logging further problems")
+	}
+}
+
+// page -- count the keys of a page up to the end of the partition, checking
+// each sorts after the one before it, or after the marker for the first
+func (c *listChecker) page(p *s3.ListObjectsOutput) {
+	n := int64(0)
+	for _, o := range p.Contents {
+		key := aws.StringValue(o.Key)
+		if c.until != "" && key > c.until {
+			break
+		}
+		if key == c.last {
+			listProblem("bucket %s listed key %s twice", c.bucket, key)
+		} else if key < c.last {
+			listProblem("bucket %s listed key %s after %s", c.bucket, key, c.last)
+		}
+		c.last = key
+		n++
+	}
+	atomic.AddInt64(&list_keys, n)
+}
+
+// checkListCount -- compare the keys the LIST test found with the objects
+// written, once every thread has finished
+func checkListCount() {
+	keys := atomic.LoadInt64(&list_keys)
+	if object_count <= 0 {
+		logInfof("List check: listed %d keys, the number of objects written is unknown", keys)
+		return
+	}
+	if keys != object_count {
+		listProblem("listed %d keys, expected the %d objects written", keys, object_count)
+		return
+	}
+	logInfof("List check: listed all %d objects", keys)
+}
+
+// listCheckFailure -- why a LIST test failed -list-check, or empty
+func listCheckFailure() string {
+	if n := atomic.LoadInt64(&list_problems); n > 0 {
+		return fmt.Sprintf("%d -list-check problems", n)
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var log_level, log_format string
+var quiet bool
+
+// classicHandler -- slog handler keeping the traditional hsbench log lines.
+// It needs its own logger since slog.SetDefault redirects the log package.
+type classicHandler struct {
+	level  slog.Leveler
+	attrs  []slog.Attr
+	logger *log.Logger
+}
+
+func (h *classicHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *classicHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	return h.logger.Output(4, b.String())
+}
+
+func (h *classicHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &classicHandler{h.level, append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...), h.logger}
+}
+
+func (h *classicHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+// setupLogging -- install the logger selected by -log-level, -log-format and -quiet
+func setupLogging() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(log_level)); err != nil {
+		configFatalf("Invalid -log-level argument %q, must be debug, info, warn or error", log_level)
+	}
+	if quiet && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	switch log_format {
+	case "text":
+		slog.SetDefault(slog.New(&classicHandler{level: level, logger: log.New(os.Stderr, "", log.LstdFlags)}))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	default:
+		configFatalf("Invalid -log-format argument %q, must be text or json", log_format)
+	}
+}
+
+func logf(level slog.Level, format string, v ...interface{}) {
+	ctx := context.Background()
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+	slog.Default().Log(ctx, level, fmt.Sprintf(format, v...))
+}
+
+func logDebugf(format string, v ...interface{}) { logf(slog.LevelDebug, format, v...) }
+func logInfof(format string, v ...interface{})  { logf(slog.LevelInfo, format, v...) }
+func logWarnf(format string, v ...interface{})  { logf(slog.LevelWarn, format, v...) }
+func logErrorf(format string, v ...interface{}) { logf(slog.LevelError, format, v...) }
+
+// logAttrs -- log msg with key/value attributes, which -log-format json keeps
+// as fields of their own
+func logAttrs(level slog.Level, msg string, args ...interface{}) {
+	slog.Default().Log(context.Background(), level, msg, args...)
+}
+
+func logDebug(msg string, args ...interface{}) { logAttrs(slog.LevelDebug, msg, args...) }
+func logWarn(msg string, args ...interface{})  { logAttrs(slog.LevelWarn, msg, args...) }
+
+// attrs -- the loop, mode and thread attributes of a thread of the phase,
+// followed by args
+func (stats *Stats) attrs(thread_num int, args ...interface{}) []interface{} {
+	return append([]interface{}{"loop", stats.loop, "mode", stats.mode, "thread", thread_num}, args...)
+}
+
+// logError -- log a failed request of a thread of the phase
+func (stats *Stats) logError(thread_num int, what string, err error, args ...interface{}) {
+	logWarn(what+" err", stats.attrs(thread_num, append([]interface{}{"error", err}, args...)...)...)
+}
+
+// logFatal -- log an unexpected error and exit
+func logFatal(v ...interface{}) {
+	logf(slog.LevelError, "%s", fmt.Sprint(v...))
+	os.Exit(exitFatal)
+}
+
+// logFatalf -- log an unexpected error and exit
+func logFatalf(format string, v ...interface{}) {
+	logf(slog.LevelError, format, v...)
+	os.Exit(exitFatal)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var manifest_out, manifest_in string
+
+// manifestEntry -- one object written by a PUT test
+type manifestEntry struct {
+	bucket   string
+	key      string
+	size     int64
+	etag     string
+	checksum string
+}
+
+// object_index holds the objects read from -manifest-in, which replace the
+// numbered object names when set
+var object_index []manifestEntry
+
+var manifestMu sync.Mutex
+var manifestFile *os.File
+var manifestWriter *csv.Writer
+
+// openManifest -- start the -manifest-out file
+func openManifest() {
+	var err error
+	manifestFile, err = os.OpenFile(manifest_out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		logFatalf("Could not open manifest file for writing: %v", err)
+	}
+	manifestWriter = csv.NewWriter(manifestFile)
+	if err := manifestWriter.Write([]string{"Bucket", "Key", "Size", "ETag", "Checksum"}); err != nil {
+		logFatalf("Error writing manifest: %v", err)
+	}
+}
+
+// addManifest -- record an object written by a PUT test
+func addManifest(bucket string, key string, etag *string) {
+	if manifestWriter == nil {
+		return
+	}
+	e := ""
+	if etag != nil {
+		e = *etag
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	err := manifestWriter.Write([]string{bucket, key, strconv.FormatInt(keySize(key), 10), e, payloadFor(key).checksum})
+	if err != nil {
+		logFatalf("Error writing manifest: %v", err)
+	}
+}
+
+// closeManifest -- flush the -manifest-out file
+func closeManifest() {
+	if manifestWriter == nil {
+		return
+	}
+	manifestWriter.Flush()
+	if err := manifestWriter.Error(); err != nil {
+		logFatalf("Error writing manifest: %v", err)
+	}
+	manifestFile.Close()
+}
+
+// readManifest -- load the objects listed in a manifest written by -manifest-out
+func readManifest(name string) ([]manifestEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 5
+	index := make([]manifestEntry, 0)
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && rec[0] == "Bucket" {
+			continue
+		}
+		size, err := strconv.ParseInt(rec[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid size %q", line, rec[2])
+		}
+		index = append(index, manifestEntry{rec[0], rec[1], size, rec[3], rec[4]})
+	}
+	if len(index) == 0 {
+		return nil, fmt.Errorf("no objects in manifest")
+	}
+	return index, nil
+}
+
+// objectName -- the bucket, key and size of object objnum, from the object
+// index if there is one and otherwise following the PUT test's naming
+func objectName(thread_num int, objnum int64, rand *ThreadSafeUUID) (*string, string, int64) {
+	if object_index != nil {
+		e := &object_index[objnum%int64(len(object_index))]
+		return &e.bucket, e.key, e.size
+	}
+	key := objectKey(objnum)
+	return &buckets[workerBucket(thread_num, objnum)], key, keySize(key)
+}
+
+// objectKey -- the key PUT tests give object objnum. With -rs the suffix is
+// a UUID derived from -sd and objnum, so the objects can be read back.
+func objectKey(objnum int64) string {
+	if randomize_suffix {
+		return object_prefix + keyDirs(objnum) + keyUUID(randomize_seed, objnum).String()
+	}
+	return fmt.Sprintf("%s%s%012d", object_prefix, keyDirs(objnum), objnum)
+}
+
+var key_depth int
+
+// keyDirs -- the -key-depth directories of object objnum, ie 56/34/ for
+// object 123456 at depth 2, spreading the objects over 100 directories at
+// every level
+func keyDirs(objnum int64) string {
+	var b strings.Builder
+	for i := 0; i < key_depth; i++ {
+		fmt.Fprintf(&b, "%02d/", objnum%100)
+		objnum /= 100
+	}
+	return b.String()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var markdown_output string
+
+// writeMarkdown -- write a Markdown table of the per-phase totals if requested
+func writeMarkdown(oStats []OutputStats) {
+	if markdown_output == "" {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("| Loop | Mode | Dur(s) | Ops | MB/s | IO/s | avg (ms) | 50% (ms) | 99% (ms) | max (ms) | Slowdowns |\n")
+	b.WriteString("|---:|:---|---:|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	for _, p := range splitPhases(oStats) {
+		o := p.Total
+		fmt.Fprintf(&b, "| %d | %s | %.1f | %d | %.2f | %.0f | %.2f | %.2f | %.2f | %.2f | %d |\n",
+			o.Loop, o.Mode, o.Seconds, o.Ops, o.Mbps, o.Iops, o.AvgLat, o.Lat50, o.Lat99, o.MaxLat, o.Slowdowns)
+	}
+	if err := os.WriteFile(outputPath(markdown_output), []byte(b.String()), 0644); err != nil {
+		logFatal("Error writing Markdown summary: ", err)
+	}
+}
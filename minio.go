@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var minio_info bool
+var minio_admin_endpoint, minio_admin_key, minio_admin_secret string
+
+// MinIOState -- the server info and background healing status the MinIO
+// admin API reported at one point, kept as it was returned
+type MinIOState struct {
+	Time time.Time
+	Info json.RawMessage `json:",omitempty"`
+	Heal json.RawMessage `json:",omitempty"`
+}
+
+// MinIOCapture -- the state of the MinIO cluster before and after the run
+type MinIOCapture struct {
+	Before *MinIOState `json:",omitempty"`
+	After  *MinIOState `json:",omitempty"`
+}
+
+var minio_capture *MinIOCapture
+
+// minioServerInfo -- the parts of a server info reply logged for a quick
+// look at the cluster's health
+type minioServerInfo struct {
+	Mode    string `json:"mode"`
+	Servers []struct {
+		State  string `json:"state"`
+		Drives []struct {
+			State string `json:"state"`
+		} `json:"drives"`
+	} `json:"servers"`
+}
+
+// minioHealStatus -- the parts of a background healing status reply logged
+type minioHealStatus struct {
+	OfflineNodes []string          `json:"offline_nodes"`
+	HealDisks    []json.RawMessage `json:"healDisks"`
+}
+
+// captureMinIO -- ask the MinIO admin API for the server info and healing
+// status, logging a line about each and keeping them for the -summary file
+func captureMinIO(when string) *MinIOState {
+	endpoint := minio_admin_endpoint
+	if endpoint == "" {
+		endpoint = url_host
+	}
+	creds := adminCredentials(minio_admin_key, minio_admin_secret)
+	state := &MinIOState{Time: time.Now().UTC()}
+
+	_, body, err := adminCall("GET", endpoint, "/minio/admin/v3/info", url.Values{}, creds)
+	if err == nil {
+		var info minioServerInfo
+		if err = json.Unmarshal(body, &info); err == nil {
+			state.Info = body
+			logInfof("MinIO %s: %s", when, describeMinIOInfo(&info))
+		}
+	}
+	if err != nil {
+		logWarnf("Could not get MinIO server info %s: %v", when, err)
+	}
+
+	_, body, err = adminCall("POST", endpoint, "/minio/admin/v3/background-heal/status", url.Values{}, creds)
+	if err == nil {
+		var heal minioHealStatus
+		if err = json.Unmarshal(body, &heal); err == nil {
+			state.Heal = body
+			logInfof("MinIO healing %s: %d drives healing, %d nodes offline", when, len(heal.HealDisks), len(heal.OfflineNodes))
+		}
+	}
+	if err != nil {
+		logWarnf("Could not get MinIO healing status %s: %v", when, err)
+	}
+	return state
+}
+
+// describeMinIOInfo -- the mode, servers and drive states of a server info
+func describeMinIOInfo(info *minioServerInfo) string {
+	servers := make(map[string]int)
+	drives := make(map[string]int)
+	for _, s := range info.Servers {
+		servers[s.State]++
+		for _, d := range s.Drives {
+			drives[d.State]++
+		}
+	}
+	return fmt.Sprintf("mode %s, servers %s, drives %s", info.Mode, countStates(servers), countStates(drives))
+}
+
+// countStates -- "3 online, 1 offline" in a stable order
+func countStates(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	states := make([]string, 0, len(counts))
+	for s := range counts {
+		states = append(states, s)
+	}
+	sort.Strings(states)
+	parts := make([]string, len(states))
+	for i, s := range states {
+		parts[i] = fmt.Sprintf("%d %s", counts[s], s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// minioBeforeRun -- capture the cluster state with -minio-info before the
+// tests start
+func minioBeforeRun() {
+	if minio_info {
+		minio_capture = &MinIOCapture{Before: captureMinIO("before the run")}
+	}
+}
+
+// minioAfterRun -- capture the cluster state once the tests are over
+func minioAfterRun() {
+	if minio_capture != nil {
+		minio_capture.After = captureMinIO("after the run")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var read_pct float64
+var write_overlap float64
+
+// key_zipf -- the exponent of the Zipf distribution reads pick objects by,
+// 0 to pick them uniformly
+var key_zipf float64
+
+// cond_pct -- percentage of reads sent with the -cond-header condition
+var cond_pct float64
+
+// write_counter numbers the new objects written by the mixed test, which
+// follow the prefilled range
+var write_counter int64
+
+// runMixed -- interleave GETs and PUTs, -read-pct percent of them reads.
+// Reads pick a random prefilled object, the lowest numbered ones most often
+// with -zipf, and -cond-pct of them are conditional. Writes overwrite one
+// with chance -write-overlap and otherwise create an object past the
+// prefilled range.
+func runMixed(thread_num int, rand *ThreadSafeUUID, reads *Stats, writes *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	buf := integrityBuffer()
+	since := time.Now().UTC()
+	for {
+		reads.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		opnum := atomic.AddInt64(&op_counter, 1)
+		if duration_secs <= -1 && opnum >= object_count {
+			atomic.AddInt64(&op_counter, -1)
+			break
+		}
+
+		read := float64(rand.intn(10000)) < workerReadPct(thread_num)*100
+		var objnum int64
+		if read && key_zipf > 0 {
+			objnum = rand.zipf(key_zipf, object_count)
+		} else if read || float64(rand.intn(10000)) < write_overlap*10000 {
+			objnum = int64(rand.intn(int(object_count)))
+		} else {
+			objnum = object_count + atomic.AddInt64(&write_counter, 1) - 1
+		}
+		bucket_num := workerBucket(thread_num, objnum)
+		key := objectKey(objnum)
+
+		stats := writes
+		var err error
+		notModified := false
+		start := time.Now().UnixNano()
+		if read {
+			stats = reads
+			r := &s3.GetObjectInput{
+				Bucket: &buckets[bucket_num],
+				Key:    &key,
+			}
+			if cond_pct > 0 && float64(rand.intn(10000)) < cond_pct*100 {
+				setCondition(r, key, since)
+			}
+			req, resp := svc.GetObjectRequest(r)
+			err = req.Send()
+			if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotModified {
+				notModified, err = true, nil
+			}
+			if err == nil {
+				if !notModified {
+					drainBody(resp.Body)
+				}
+				reads.addCacheStatus(thread_num, req)
+			}
+		} else {
+			r := &s3.PutObjectInput{
+				Bucket:             &buckets[bucket_num],
+				Key:                &key,
+				Body:               objectBody(buf, key),
+				ContentType:        content_types.pick(rand),
+				CacheControl:       cache_controls.pick(rand),
+				ContentDisposition: content_dispositions.pick(rand),
+			}
+			setContentMD5(r)
+			req, _ := svc.PutObjectRequest(r)
+			// Disable payload checksum calculation (very expensive)
+			req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+			setUploadMode(req)
+			setChecksum(req)
+			err = req.Send()
+		}
+		end := time.Now().UnixNano()
+
+		if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, "mixed", err) {
+				break
+			}
+		} else if notModified {
+			stats.addNotModified(thread_num, end-start)
+		} else {
+			stats.addBucketOp(thread_num, buckets[bucket_num], keySize(key), end-start)
+		}
+		think(start)
+	}
+	reads.finish(thread_num)
+	writes.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// The -mpu-* options: PUT tests send objects of -mpu-threshold bytes or more
+// as multipart uploads
+var mpu_threshold_arg, mpu_part_size_arg string
+var mpu_threshold, mpu_part_size int64
+var mpu_concurrency int
+
+// useMultipart -- whether a PUT of size bytes goes as a multipart upload
+func useMultipart(size int64) bool {
+	return mpu_threshold > 0 && size >= mpu_threshold
+}
+
+// putMultipart -- send the PUT r as a multipart upload of -mpu-part-size
+// parts, -mpu-concurrency of them at a time, the way the SDK's upload
+// manager does. The whole upload counts as one operation, so its latency
+// is the transfer time of the object. Returns the ETag.
+func putMultipart(svc *s3.S3, r *s3.PutObjectInput) (*string, error) {
+	u := s3manager.NewUploaderWithClient(svc, func(u *s3manager.Uploader) {
+		u.PartSize = mpu_part_size
+		u.Concurrency = mpu_concurrency
+		u.RequestOptions = append(u.RequestOptions, func(req *request.Request) {
+			// Disable payload checksum calculation (very expensive)
+			req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		})
+	})
+	out, err := u.Upload(&s3manager.UploadInput{
+		Bucket:             r.Bucket,
+		Key:                r.Key,
+		Body:               r.Body,
+		ContentType:        r.ContentType,
+		CacheControl:       r.CacheControl,
+		ContentDisposition: r.ContentDisposition,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.ETag, nil
+}
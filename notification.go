@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// configureNotifications -- point every bucket's notifications at the test
+// topic so the following PUT phase pays the cost of event delivery.
+func configureNotifications() {
+	svc := newS3Client()
+	events := []string(notify_events)
+	if len(events) == 0 {
+		events = []string{s3.EventS3ObjectCreated}
+	}
+	for i := range buckets {
+		_, err := svc.PutBucketNotificationConfiguration(&s3.PutBucketNotificationConfigurationInput{
+			Bucket: &buckets[i],
+			NotificationConfiguration: &s3.NotificationConfiguration{
+				TopicConfigurations: []*s3.TopicConfiguration{{
+					Id:       aws.String("hsbench"),
+					TopicArn: &notify_topic,
+					Events:   aws.StringSlice(events),
+				}},
+			},
+		})
+		if err != nil {
+			logFatalf("FATAL: Unable to configure notifications on bucket %s: %v", buckets[i], err)
+		}
+	}
+	logInfof("Configured notifications to %s on %d buckets", notify_topic, len(buckets))
+}
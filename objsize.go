@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math"
+)
+
+var object_size_max_arg string
+
+// object_size_max -- with -z-max, object sizes are spread from -z up to this
+var object_size_max int64
+
+// keySize -- the size of the object key. With -z-max the sizes are spread
+// log-uniformly from -z to -z-max by the hash of the key, so small objects
+// are as common as they are among web assets and later tests know the size
+// of every object without keeping track of it.
+func keySize(key string) int64 {
+	if object_size_max <= object_size {
+		return object_size
+	}
+	u := float64(keyHash(key)>>11) / (1 << 53)
+	lo, hi := math.Log(float64(object_size)), math.Log(float64(object_size_max+1))
+	return min(object_size_max, int64(math.Exp(lo+u*(hi-lo))))
+}
+
+// payloadSize -- the size of the buffers PUT bodies are cut from
+func payloadSize() int64 {
+	return max(object_size, object_size_max)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Operation -- a request type of its own, ie a vendor API, that the 'o'
+// mode sends with the scheduling and stats of the built in tests
+type Operation interface {
+	// Do sends one request for the object key in bucket with the thread's
+	// client, returning the bytes it moved
+	Do(svc *s3.S3, bucket string, key string) (int64, error)
+}
+
+// OperationFunc -- a function that is an Operation
+type OperationFunc func(svc *s3.S3, bucket string, key string) (int64, error)
+
+func (f OperationFunc) Do(svc *s3.S3, bucket string, key string) (int64, error) {
+	return f(svc, bucket, key)
+}
+
+// operations -- the Operations -operation can name
+var operations = make(map[string]Operation)
+
+var operation_name string
+var plugin_args stringListFlag
+
+// registerOperation -- make op available to -operation as name. Operations
+// built in register from an init function of their own file in this
+// package; -plugin files export them instead.
+func registerOperation(name string, op Operation) {
+	if _, ok := operations[name]; ok {
+		logFatalf("Operation %s registered twice", name)
+	}
+	operations[name] = op
+}
+
+// loadPlugin -- register the operations of a Go plugin built with
+// -buildmode=plugin against the same SDK version. It exports them as
+//
+//	var Operations = map[string]func(*s3.S3, string, string) (int64, error){...}
+func loadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	sym, err := p.Lookup("Operations")
+	if err != nil {
+		return err
+	}
+	ops, ok := sym.(*map[string]func(*s3.S3, string, string) (int64, error))
+	if !ok {
+		return fmt.Errorf("Operations is a %T, not a map[string]func(*s3.S3, string, string) (int64, error)", sym)
+	}
+	for name, f := range *ops {
+		registerOperation(name, OperationFunc(f))
+	}
+	return nil
+}
+
+// setupOperations -- load the -plugin files and check the 'o' mode has an
+// operation. This waits for main since the init functions registering the
+// built in operations may run after the flags are checked.
+func setupOperations() {
+	for _, path := range plugin_args {
+		if err := loadPlugin(path); err != nil {
+			configFatalf("Could not load -plugin %s: %v", path, err)
+		}
+	}
+	if strings.ContainsRune(modes, 'o') {
+		if _, ok := operations[operation_name]; !ok {
+			configFatalf("The 'o' mode needs an -operation of %s", strings.Join(operationNames(), ", "))
+		}
+	}
+}
+
+// operationNames -- the registered operations in a stable order
+func operationNames() []string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// operationMode -- the mode the rows of -operation are reported as
+func operationMode() string {
+	return strings.ToUpper(operation_name)
+}
+
+// runOperation -- the 'o' mode, send -operation for the objects
+func runOperation(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	op := operations[operation_name]
+	next := func() (int64, bool) {
+		return nextObject(thread_num, loop_objects && duration_secs > -1, true)
+	}
+	runRequests(thread_num, stats, operation_name, next, func(objnum int64) (string, func() (int64, error)) {
+		bucket, key, _ := objectName(thread_num, objnum, rand)
+		return *bucket, func() (int64, error) {
+			return op.Do(svc, *bucket, key)
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
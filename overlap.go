@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var overlap_arg string
+
+// overlap -- the share of the objects a PUT test writes before the GET test
+// after it starts alongside it, or -1 to run the tests one after the other
+var overlap float64 = -1
+
+// overlap_get -- set while a PUT test runs with the GET test after it
+var overlap_get bool
+
+var overlap_counter int64
+
+// put_inflight -- the object each PUT thread is writing, math.MaxInt64 when
+// none, so overlapped reads keep to the objects already written
+var put_inflight []int64
+
+// overlapsGet -- whether mode i of -m is a PUT test run with the GET test
+// following it
+func overlapsGet(i int) bool {
+	return overlap >= 0 && modes[i] == 'p' && i+1 < len(modes) && modes[i+1] == 'g'
+}
+
+// markWriting -- note the object thread_num is about to write. Called with
+// a lower bound before the thread takes its object and again with it.
+func markWriting(thread_num int, objnum int64) {
+	if put_inflight != nil {
+		atomic.StoreInt64(&put_inflight[thread_num], objnum)
+	}
+}
+
+// markWritten -- note that thread_num is done with its object
+func markWritten(thread_num int) {
+	markWriting(thread_num, math.MaxInt64)
+}
+
+// writtenObjects -- the number of objects from 0 up that have been written
+func writtenObjects() int64 {
+	written := atomic.LoadInt64(&op_counter) + 1
+	for i := range put_inflight {
+		written = min(written, atomic.LoadInt64(&put_inflight[i]))
+	}
+	return written
+}
+
+// startOverlap -- start the GET test once writes has written -overlap of
+// the objects. The GET stats are sent on the channel returned, nil if the
+// PUT test ended first.
+func startOverlap(loop int, rnd *ThreadSafeUUID, writes *Stats, intervalNano int64) chan *Stats {
+	overlap_counter = -1
+	put_inflight = make([]int64, threads)
+	for i := range put_inflight {
+		put_inflight[i] = math.MaxInt64
+	}
+	atomic.AddInt64(&running_threads, int64(threads))
+	started := make(chan *Stats, 1)
+	threshold := int64(math.Ceil(overlap * float64(object_count)))
+	go func() {
+		for writtenObjects() < threshold {
+			select {
+			case <-writes.done:
+				atomic.AddInt64(&running_threads, -int64(threads))
+				started <- nil
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+		logInfof("Running Loop %d OBJECT GET TEST alongside PUT, %d objects written", loop, writtenObjects())
+		stats := makeStats(loop, "OGET", threads, intervalNano)
+		for n := 0; n < threads; n++ {
+			worker(n, func() { runOverlapGet(n, rnd, stats, writes) })
+		}
+		started <- stats
+	}()
+	return started
+}
+
+// runOverlapGet -- read every object once, as soon as the PUT test running
+// alongside has written it
+func runOverlapGet(thread_num int, rand *ThreadSafeUUID, stats *Stats, writes *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		objnum := atomic.AddInt64(&overlap_counter, 1)
+		if objnum >= object_count {
+			break
+		}
+		written := true
+		for objnum >= writtenObjects() && written {
+			select {
+			case <-writes.done:
+				written = objnum < writtenObjects()
+			case <-time.After(time.Millisecond):
+			}
+		}
+		if !written {
+			break
+		}
+
+		bucket, key, size := objectName(thread_num, objnum, rand)
+		start := time.Now().UnixNano()
+		req, resp := svc.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: bucket,
+			Key:    &key,
+		})
+		err := req.Send()
+		end := time.Now().UnixNano()
+
+		if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, "download", err) {
+				break
+			}
+		} else {
+			drainBody(resp.Body)
+			stats.addCacheStatus(thread_num, req)
+			stats.addBucketOp(thread_num, *bucket, size, end-start)
+		}
+		think(start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var think_time time.Duration
+var duty_cycle_arg string
+
+// duty_cycle is the fraction of time each thread spends in requests
+var duty_cycle float64 = 1
+
+var burst_arg string
+
+// burst_on and burst_period are the parsed -burst on/off cycle, zero to run
+// steadily
+var burst_on time.Duration
+var burst_period time.Duration
+
+// parseBurst -- parse "<on>:<period>", ie 5s:30s for 5 seconds of load
+// every 30 seconds
+func parseBurst(s string) (time.Duration, time.Duration, error) {
+	on, period, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected <on>:<period>")
+	}
+	onDur, err := time.ParseDuration(on)
+	if err != nil {
+		return 0, 0, err
+	}
+	periodDur, err := time.ParseDuration(period)
+	if err != nil {
+		return 0, 0, err
+	}
+	if onDur <= 0 || onDur > periodDur {
+		return 0, 0, fmt.Errorf("the on time must be above zero and no longer than the period")
+	}
+	return onDur, periodDur, nil
+}
+
+// waitBurst -- outside the on part of the burst cycle, sleep until the next
+// burst begins. Cycles start with the test.
+func (stats *Stats) waitBurst() {
+	if burst_period <= 0 {
+		return
+	}
+	into := (time.Now().UnixNano() - stats.startNano) % int64(burst_period)
+	if into >= int64(burst_on) {
+		sleepUntil(time.Now().UnixNano() + int64(burst_period) - into)
+	}
+}
+
+// parseFraction -- accept 50% or 0.5
+func parseFraction(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// think -- pause after an operation that began at start, for the think time
+// plus whatever idle time keeps the thread at its duty cycle. Pauses never
+// run past the end of the test.
+func think(start int64) {
+	pause := think_time
+	if duty_cycle < 1 {
+		busy := time.Now().UnixNano() - start
+		pause += time.Duration(float64(busy) * (1 - duty_cycle) / duty_cycle)
+	}
+	if pause <= 0 {
+		return
+	}
+	if duration_secs > -1 {
+		if left := time.Until(endtime); left < pause {
+			pause = left
+		}
+	}
+	time.Sleep(pause)
+}
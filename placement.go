@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var placement_file string
+
+// bucketPlacement -- the LocationConstraint buckets matching a pattern are
+// created with
+type bucketPlacement struct {
+	pattern    string
+	constraint string
+}
+
+var placements []bucketPlacement
+
+// readPlacements -- parse "<bucket or glob> <LocationConstraint>" lines,
+// skipping blank lines and # comments. RGW placement targets are given in
+// its "<zonegroup>:<placement-id>" LocationConstraint form.
+func readPlacements(name string) ([]bucketPlacement, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := make([]bucketPlacement, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<bucket> <location constraint>\"", line)
+		}
+		if _, err := path.Match(fields[0], ""); err != nil {
+			return nil, fmt.Errorf("line %d: invalid bucket pattern %q", line, fields[0])
+		}
+		p = append(p, bucketPlacement{fields[0], fields[1]})
+	}
+	return p, scanner.Err()
+}
+
+// placementFor -- the LocationConstraint of the first pattern matching bucket
+func placementFor(bucket string) string {
+	for _, p := range placements {
+		if ok, _ := path.Match(p.pattern, bucket); ok {
+			return p.constraint
+		}
+	}
+	return ""
+}
+
+// bucketConfiguration -- the create configuration for bucket, nil to use the
+// endpoint's default placement
+func bucketConfiguration(bucket string) *s3.CreateBucketConfiguration {
+	c := placementFor(bucket)
+	if c == "" {
+		return nil
+	}
+	return &s3.CreateBucketConfiguration{LocationConstraint: &c}
+}
+
+// addBucketOp -- record an operation, and with -output-detail bucket also
+// against the bucket it was made on
+func (stats *Stats) addBucketOp(thread_num int, bucket string, bytes int64, latNano int64) {
+	if output_detail == "bucket" {
+		ts := &stats.threadStats[thread_num]
+		ts.mu.Lock()
+		if ts.buckets == nil {
+			ts.buckets = make(map[string]*IntervalStats)
+		}
+		is, ok := ts.buckets[bucket]
+		if !ok {
+			is = &IntervalStats{}
+			ts.buckets[bucket] = is
+		}
+		is.bytes += bytes
+		is.latNano = append(is.latNano, latNano)
+		ts.mu.Unlock()
+	}
+	stats.addOp(thread_num, bytes, latNano)
+}
+
+// makeBucketOutputStats -- a BUCKET row per bucket covering the whole test
+func (stats *Stats) makeBucketOutputStats() []OutputStats {
+	totals := make(map[string]*IntervalStats)
+	for t := range stats.threadStats {
+		for bucket, bis := range stats.threadStats[t].buckets {
+			is, ok := totals[bucket]
+			if !ok {
+				total := stats.newIntervalStats("BUCKET", stats.endNano-stats.startNano)
+				// Busy time is not kept per bucket
+				total.threads = 0
+				total.startNano = stats.startNano
+				is = &total
+				totals[bucket] = is
+			}
+			is.merge(bis)
+		}
+	}
+	os := make([]OutputStats, 0, len(totals))
+	for _, bucket := range buckets {
+		is, ok := totals[bucket]
+		if !ok {
+			continue
+		}
+		sort.Slice(is.latNano, func(i, j int) bool { return is.latNano[i] < is.latNano[j] })
+		o := is.makeOutputStats()
+		o.Bucket = bucket
+		os = append(os, o)
+	}
+	return os
+}
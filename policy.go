@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// defaultBucketPolicy grants anonymous read access; %s is the bucket name
+const defaultBucketPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::%s/*"]}]}`
+
+// policyFor -- return the policy document to apply to a bucket
+func policyFor(bucket string) string {
+	if bucket_policy != "" {
+		return strings.ReplaceAll(bucket_policy, "${bucket}", bucket)
+	}
+	return fmt.Sprintf(defaultBucketPolicy, bucket)
+}
+
+// runBucketPolicy -- put, get or delete bucket policies.  Buckets are cycled
+// until the duration expires so small bucket counts still give useful samples.
+func runBucketPolicy(thread_num int, mode rune, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	next := func() (int64, bool) {
+		opnum := atomic.AddInt64(&op_counter, 1)
+		if (duration_secs < 0 || object_count > -1) && opnum >= max(object_count, bucket_count) {
+			atomic.AddInt64(&op_counter, -1)
+			return 0, false
+		}
+		return opnum, true
+	}
+	runRequests(thread_num, stats, "bucket policy", next, func(opnum int64) (string, func() (int64, error)) {
+		bucket := &buckets[opnum%bucket_count]
+		return "", func() (int64, error) {
+			var err error
+			switch mode {
+			case 'P':
+				policy := policyFor(*bucket)
+				_, err = svc.PutBucketPolicy(&s3.PutBucketPolicyInput{Bucket: bucket, Policy: &policy})
+			case 'G':
+				_, err = svc.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: bucket})
+			case 'D':
+				_, err = svc.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{Bucket: bucket})
+			}
+			return 0, err
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
+
+// runObjectAcl -- put or get the ACL of each object
+func runObjectAcl(thread_num int, mode rune, rand *ThreadSafeUUID, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	next := func() (int64, bool) {
+		return nextCounted(loop_objects && duration_secs > -1, false)
+	}
+	runRequests(thread_num, stats, "object acl", next, func(objnum int64) (string, func() (int64, error)) {
+		bucket, key, _ := objectName(thread_num, objnum, rand)
+		return *bucket, func() (int64, error) {
+			var err error
+			if mode == 'A' {
+				_, err = svc.PutObjectAcl(&s3.PutObjectAclInput{Bucket: bucket, Key: &key, ACL: &object_acl})
+			} else {
+				_, err = svc.GetObjectAcl(&s3.GetObjectAclInput{Bucket: bucket, Key: &key})
+			}
+			return 0, err
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
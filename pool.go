@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+)
+
+var data_pool int
+
+// payloadData -- one of the -data-pool buffers PUT bodies are taken from,
+// with the ETag and checksum objects written from it get
+type payloadData struct {
+	data     []byte
+	md5      string
+	etag     string
+	checksum string
+}
+
+var object_pool []payloadData
+
+// noPayload -- stands in for the pool when PUT bodies come from the -source
+var noPayload payloadData
+
+// makePayload -- fill a buffer for the pool and work out its hashes
+func makePayload() payloadData {
+	p := payloadData{data: make([]byte, payloadSize())}
+	if select_format != "" {
+		fillSelectRecords(p.data, select_format)
+	} else if !zero_object_data {
+		data_rand.read(p.data)
+	}
+	sum := md5.Sum(p.data)
+	p.md5 = base64.StdEncoding.EncodeToString(sum[:])
+	p.etag = fmt.Sprintf("\"%x\"", sum)
+	if checksum_algorithm != "" {
+		h := newChecksumHash(checksum_algorithm)
+		h.Write(p.data)
+		p.checksum = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	return p
+}
+
+// payloadFor -- the pool buffer of key. Keys are spread over the pool by
+// their hash, so later tests can tell which buffer an object was written
+// from without keeping track of it.
+func payloadFor(key string) *payloadData {
+	switch len(object_pool) {
+	case 0:
+		return &noPayload
+	case 1:
+		return &object_pool[0]
+	}
+	return &object_pool[keyHash(key)%uint64(len(object_pool))]
+}
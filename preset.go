@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var preset string
+
+// presetArg -- one flag a preset sets
+type presetArg struct {
+	name, value string
+}
+
+// presets -- canned workloads, so the same test can be run at several sites
+// by name. Flags given on the command line or through the environment win
+// over the preset's.
+var presets = map[string][]presetArg{
+	// Multi-GB objects put as multipart uploads and streamed back
+	"largeobject": {
+		{"m", "cipgdx"},
+		{"z", "2G"},
+		{"mpu-threshold", "64M"},
+		{"mpu-part-size", "64M"},
+		{"mpu-concurrency", "8"},
+		{"t", "4"},
+		{"d", "300"},
+	},
+	// A CDN origin: small objects, mostly reads of hot objects, a third of
+	// them revalidations, judged against latency objectives
+	"webasset": {
+		{"m", "cipMcx"},
+		{"z", "1K"},
+		{"z-max", "256K"},
+		{"read-pct", "95"},
+		{"zipf", "1.1"},
+		{"cond-pct", "30"},
+		{"cond-header", "date"},
+		{"t", "64"},
+		{"d", "60"},
+		{"slo", "99% MGET < 100ms"},
+		{"slo", "95% MGET < 20ms"},
+	},
+	// Training data shards read like a PyTorch or TensorFlow S3 data
+	// loader, in epochs of shuffled shards read in parallel ranges
+	"mltrain": {
+		{"m", "cipTdx"},
+		{"z", "256M"},
+		{"n", "64"},
+		{"d", "-1"},
+		{"mpu-threshold", "64M"},
+		{"epochs", "3"},
+		{"shard-range", "8M"},
+		{"shard-parallel", "4"},
+		{"t", "8"},
+	},
+	// Tiny objects under deep prefixes, HEADs and listings of them, and a
+	// delete and rewrite cycle, all of it load on the metadata path
+	"smallfile": {
+		{"m", "ciphldphldx"},
+		{"z", "1K"},
+		{"key-depth", "4"},
+		{"t", "64"},
+		{"d", "60"},
+	},
+}
+
+// presetNames -- the names of the presets in a stable order
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreset -- set the flags of -preset that were not given
+func applyPreset(fs *flag.FlagSet) error {
+	if preset == "" {
+		return nil
+	}
+	args, ok := presets[preset]
+	if !ok {
+		return fmt.Errorf("unknown -preset %q, must be one of %s", preset, strings.Join(presetNames(), ", "))
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, a := range args {
+		if given[a.name] {
+			continue
+		}
+		if err := fs.Set(a.name, a.value); err != nil {
+			return fmt.Errorf("-preset %s: invalid -%s %q: %v", preset, a.name, a.value, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// maxSinglePutSize is the largest object S3 accepts in a single PUT
+const maxSinglePutSize = 5 * 1024 * 1024 * 1024
+
+var probe bool
+
+// ProbeResult -- whether the endpoint supports one feature
+type ProbeResult struct {
+	Name      string
+	Supported bool
+	Detail    string `json:",omitempty"`
+}
+
+// Capabilities -- what the pre-flight probe learned about the endpoint
+type Capabilities struct {
+	Features         []ProbeResult
+	ClockSkewSeconds float64
+}
+
+var capabilities *Capabilities
+
+func (c *Capabilities) record(name string, err error) bool {
+	r := ProbeResult{Name: name, Supported: err == nil}
+	if aerr, ok := err.(awserr.Error); ok {
+		r.Detail = aerr.Code()
+	} else if err != nil {
+		r.Detail = err.Error()
+	}
+	c.Features = append(c.Features, r)
+	if r.Supported {
+		logInfof("Probe %s: supported", name)
+	} else {
+		logWarnf("Probe %s: not supported (%s)", name, r.Detail)
+	}
+	return r.Supported
+}
+
+func (c *Capabilities) supported(name string) bool {
+	for _, f := range c.Features {
+		if f.Name == name {
+			return f.Supported
+		}
+	}
+	return true
+}
+
+// measureClockSkew -- compare the server Date header with the local clock,
+// returning how far the server is ahead of us
+func measureClockSkew(svc *s3.S3, bucket *string) (time.Duration, error) {
+	before := time.Now()
+	req, _ := svc.HeadBucketRequest(&s3.HeadBucketInput{Bucket: bucket})
+	err := req.Send()
+	after := time.Now()
+	if req.HTTPResponse == nil {
+		return 0, err
+	}
+	date, perr := http.ParseTime(req.HTTPResponse.Header.Get("Date"))
+	if perr != nil {
+		return 0, perr
+	}
+	// The Date header has second precision, so compare with the midpoint
+	local := before.Add(after.Sub(before) / 2)
+	return date.Sub(local), err
+}
+
+// runProbe -- check which S3 features the endpoint supports before running
+// any tests, and refuse to run tests that need missing features.
+func runProbe() {
+	logInfof("Probing endpoint %s", url_host)
+	svc := newS3Client()
+	c := &Capabilities{}
+	capabilities = c
+	bucket := &buckets[0]
+	key := aws.String(object_prefix + "hsbench-probe")
+
+	skew, err := measureClockSkew(svc, bucket)
+	c.ClockSkewSeconds = skew.Seconds()
+	created := false
+	if isNoSuchBucket(err) {
+		_, err = svc.CreateBucket(&s3.CreateBucketInput{Bucket: bucket})
+		created = err == nil
+	}
+	if !c.record("HeadBucket", err) {
+		logWarnf("Skipping object probes, bucket %s is not accessible", *bucket)
+		return
+	}
+	logInfof("Probe clock skew: %.1fs", c.ClockSkewSeconds)
+
+	_, err = svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: bucket, MaxKeys: aws.Int64(1)})
+	c.record("ListObjectsV2", err)
+
+	_, err = svc.PutObject(&s3.PutObjectInput{Bucket: bucket, Key: key, Body: bytes.NewReader([]byte("hsbench"))})
+	if c.record("PutObject", err) {
+		_, err = svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+			Bucket:  bucket,
+			Key:     key,
+			Tagging: &s3.Tagging{TagSet: []*s3.Tag{{Key: aws.String("hsbench"), Value: aws.String("probe")}}},
+		})
+		c.record("Tagging", err)
+
+		_, err = svc.GetObjectAttributes(&s3.GetObjectAttributesInput{
+			Bucket:           bucket,
+			Key:              key,
+			ObjectAttributes: aws.StringSlice([]string{s3.ObjectAttributesObjectSize}),
+		})
+		c.record("GetObjectAttributes", err)
+	}
+
+	// A checksum is only supported if the server stores and returns it
+	h := newChecksumHash(s3.ChecksumAlgorithmCrc32)
+	h.Write([]byte("hsbench"))
+	crc := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	out, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:            bucket,
+		Key:               key,
+		Body:              bytes.NewReader([]byte("hsbench")),
+		ChecksumAlgorithm: aws.String(s3.ChecksumAlgorithmCrc32),
+		ChecksumCRC32:     &crc,
+	})
+	if err == nil && aws.StringValue(out.ChecksumCRC32) == "" {
+		err = awserr.New("ChecksumIgnored", "checksum not returned", nil)
+	}
+	c.record("Checksums", err)
+
+	mpu, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: bucket, Key: key})
+	if c.record("MultipartUpload", err) {
+		svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{Bucket: bucket, Key: key, UploadId: mpu.UploadId})
+	}
+
+	svc.DeleteObject(&s3.DeleteObjectInput{Bucket: bucket, Key: key})
+	if created {
+		svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: bucket})
+	}
+
+	if object_size > maxSinglePutSize {
+		logWarnf("Probe: object size %d is above the %d byte single PUT limit", object_size, int64(maxSinglePutSize))
+	}
+
+	// Refuse to start tests that depend on missing features
+	missing := make([]string, 0)
+	if strings.ContainsRune(modes, 'c') && !c.supported("ListObjectsV2") {
+		missing = append(missing, "ListObjectsV2 (mode c)")
+	}
+	if strings.ContainsRune(modes, 'a') && !c.supported("GetObjectAttributes") {
+		missing = append(missing, "GetObjectAttributes (mode a)")
+	}
+	if checksum_algorithm != "" && !c.supported("Checksums") {
+		missing = append(missing, "Checksums (-checksum)")
+	}
+	if len(missing) > 0 {
+		exit_code = exitConfigError
+		writeSummary()
+		configFatalf("Endpoint lacks features the workload needs: %s", strings.Join(missing, ", "))
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var profile_file string
+var arrival string
+var pareto_shape float64
+
+// load_profile is the offered rate over time read from -profile, nil for
+// closed-loop tests where every thread runs flat out
+var load_profile loadProfile
+
+// profilePoint -- the offered rate, in operations per second across all
+// threads, at a number of seconds into a test
+type profilePoint struct {
+	secs float64
+	rate float64
+}
+
+// loadProfile -- points in time order, the rate is interpolated linearly
+// between them and held after the last one
+type loadProfile []profilePoint
+
+// readProfile -- parse a profile file of "<time> <ops/s>" lines, where time
+// is seconds or a duration like 90s or 5m. Blank lines and # comments are
+// skipped.
+func readProfile(name string) (loadProfile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var p loadProfile
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<time> <ops/s>\"", line)
+		}
+		secs, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			d, derr := time.ParseDuration(fields[0])
+			if derr != nil {
+				return nil, fmt.Errorf("line %d: invalid time %q", line, fields[0])
+			}
+			secs = d.Seconds()
+		}
+		rate, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, fields[1])
+		}
+		if len(p) > 0 && secs <= p[len(p)-1].secs {
+			return nil, fmt.Errorf("line %d: times must increase", line)
+		}
+		p = append(p, profilePoint{secs, rate})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("no rates in profile")
+	}
+	return p, nil
+}
+
+// rate -- the offered rate secs into the test
+func (p loadProfile) rate(secs float64) float64 {
+	if secs <= p[0].secs {
+		return p[0].rate
+	}
+	for i := 1; i < len(p); i++ {
+		if secs < p[i].secs {
+			a, b := p[i-1], p[i]
+			return a.rate + (b.rate-a.rate)*(secs-a.secs)/(b.secs-a.secs)
+		}
+	}
+	return p[len(p)-1].rate
+}
+
+// average -- the mean offered rate between two points of the test, exact
+// for a piecewise linear profile
+func (p loadProfile) average(from, to float64) float64 {
+	if to <= from {
+		return p.rate(from)
+	}
+	area := 0.0
+	last := from
+	for _, pt := range p {
+		if pt.secs > from && pt.secs < to {
+			area += (p.rate(last) + p.rate(pt.secs)) / 2 * (pt.secs - last)
+			last = pt.secs
+		}
+	}
+	area += (p.rate(last) + p.rate(to)) / 2 * (to - last)
+	return area / (to - from)
+}
+
+// interArrival -- the gap to the next arrival at the given rate, drawn from
+// the -arrival distribution with a mean of 1/rate
+func interArrival(rate float64) int64 {
+	mean := float64(time.Second) / rate
+	switch arrival {
+	case "exponential":
+		return int64(mean * arrival_rand.expFloat64())
+	case "pareto":
+		// Scale the minimum so the mean stays 1/rate
+		xm := (pareto_shape - 1) / pareto_shape
+		return int64(mean * xm / math.Pow(1-arrival_rand.float64(), 1/pareto_shape))
+	}
+	return int64(mean)
+}
+
+// targetRate -- the mean offered rate between two timestamps of this test
+func (stats *Stats) targetRate(fromNano, toNano int64) float64 {
+	if rate := controlRate(); rate > 0 {
+		return rate
+	}
+	if load_profile == nil {
+		return 0
+	}
+	return load_profile.average(float64(fromNano-stats.startNano)/1e9, float64(toNano-stats.startNano)/1e9)
+}
+
+// sleepUntil -- sleep until nano, or the end of the test if that is sooner
+func sleepUntil(nano int64) {
+	wait := time.Duration(nano - time.Now().UnixNano())
+	if duration_secs > -1 {
+		wait = min(wait, time.Until(endtime))
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// arrive -- wait while paused, for the next burst and for the rate of the
+// thread's -workers group, and with a load profile or a control endpoint
+// rate for this thread's next arrival in the open-loop schedule. Arrivals missed because every thread was busy are dropped rather
+// than made up, so the accepted rate shows any shortfall.
+func (stats *Stats) arrive(thread_num int) {
+	waitResume()
+	stats.waitBurst()
+	paceWorker(thread_num)
+	if load_profile == nil && controlRate() == 0 {
+		return
+	}
+	for {
+		stats.arrivalMu.Lock()
+		now := time.Now().UnixNano()
+		if stats.nextNano < now {
+			stats.nextNano = now
+		}
+		at := stats.nextNano
+		rate := controlRate()
+		if rate == 0 && load_profile != nil {
+			rate = load_profile.rate(float64(at-stats.startNano) / 1e9)
+		}
+		if rate > 0 {
+			stats.nextNano += interArrival(rate)
+		}
+		stats.arrivalMu.Unlock()
+		if rate == 0 && load_profile == nil {
+			// The control rate was cleared, back to closed loop
+			return
+		}
+
+		if rate > 0 {
+			sleepUntil(at)
+			return
+		}
+		// Nothing is offered at the moment, check again shortly
+		sleepUntil(now + int64(100*time.Millisecond))
+		if phaseOver() {
+			return
+		}
+	}
+}
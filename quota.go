@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+var quota_objects int64
+var quota_size_arg string
+var quota_size uint64
+var quota_scope string
+
+// quotaDeclared -- whether the user told us about a quota to expect
+func quotaDeclared() bool {
+	return quota_objects > 0 || quota_size > 0
+}
+
+// isQuotaError -- whether err is the backend enforcing a quota
+func isQuotaError(err error) bool {
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusForbidden {
+		switch rerr.Code() {
+		case "QuotaExceeded", "UserQuotaExceeded", "BucketQuotaExceeded":
+			return true
+		}
+	}
+	return false
+}
+
+// rejectQuota -- with a declared quota, count a quota rejection as expected
+// rather than an error
+func (stats *Stats) rejectQuota(thread_num int, err error) bool {
+	if !quotaDeclared() || !isQuotaError(err) {
+		return false
+	}
+	stats.current(thread_num).quotaRejects++
+	stats.threadStats[thread_num].mu.Unlock()
+	return true
+}
+
+// logQuota -- compare what a test managed to write with the declared quota
+func logQuota(o OutputStats) {
+	if !quotaDeclared() || o.QuotaRejects == 0 {
+		return
+	}
+	scopes := int64(1)
+	if quota_scope == "bucket" {
+		scopes = bucket_count
+	}
+	written := int64(o.Mbps * o.Seconds * bytefmt.MEGABYTE)
+	if quota_objects > 0 {
+		logInfof("%s quota of %d objects per %s, %d objects accepted before %d rejections",
+			o.Mode, quota_objects, quota_scope, o.Ops, o.QuotaRejects)
+		if int64(o.Ops) < quota_objects*scopes {
+			logWarnf("%s quota enforced early: %d objects accepted of %d allowed", o.Mode, o.Ops, quota_objects*scopes)
+		}
+	}
+	if quota_size > 0 {
+		logInfof("%s quota of %s per %s, %s accepted before %d rejections",
+			o.Mode, bytefmt.ByteSize(quota_size), quota_scope, bytefmt.ByteSize(uint64(written)), o.QuotaRejects)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var replica_endpoint, replica_check string
+var replica_poll, replica_timeout time.Duration
+
+// Objects of the running 'y' test never seen on the replica
+var replica_missing int64
+
+type pendingReplica struct {
+	bucket string
+	key    string
+	size   int64
+	end    int64
+}
+
+// newReplicaClient -- a client of -replica-u with the credentials and
+// options of -u
+func newReplicaClient() *s3.S3 {
+	c := cfg.Copy()
+	c.Endpoint = aws.String(replica_endpoint)
+	return newS3ClientWith(c, false)
+}
+
+// replicated -- whether the replica serves the whole object, to a HEAD or a
+// GET. A 404 means it has not arrived yet.
+func replicated(svc *s3.S3, p *pendingReplica) (bool, error) {
+	var n int64
+	var err error
+	if replica_check == "get" {
+		var out *s3.GetObjectOutput
+		if out, err = svc.GetObject(&s3.GetObjectInput{Bucket: &p.bucket, Key: &p.key}); err == nil {
+			n, err = drainBody(out.Body)
+		}
+	} else {
+		var out *s3.HeadObjectOutput
+		if out, err = svc.HeadObject(&s3.HeadObjectInput{Bucket: &p.bucket, Key: &p.key}); err == nil {
+			n = aws.Int64Value(out.ContentLength)
+		}
+	}
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil && n == p.size, err
+}
+
+// checkReplicas -- check every pending object once, recording the lag of
+// those the replica now serves and giving up on those past -replica-timeout.
+// Returns the objects still pending.
+func checkReplicas(thread_num int, svc *s3.S3, pending []pendingReplica, lag *Stats) []pendingReplica {
+	remaining := pending[:0]
+	for _, p := range pending {
+		ok, err := replicated(svc, &p)
+		now := time.Now().UnixNano()
+		if err != nil {
+			lag.addSlowDown(thread_num)
+			lag.logError(thread_num, "replica check", err)
+		}
+		switch {
+		case ok:
+			lag.addBucketOp(thread_num, p.bucket, 0, now-p.end)
+		case now-p.end > replica_timeout.Nanoseconds():
+			if atomic.AddInt64(&replica_missing, 1) == 1 {
+				logWarnf("Object %s/%s not on the replica after %s", p.bucket, p.key, replica_timeout)
+			}
+		default:
+			remaining = append(remaining, p)
+		}
+	}
+	return remaining
+}
+
+// runReplication -- PUT objects to -u and poll -replica-u for them between
+// PUTs, then until all arrived once the PUTs are done.  PUT latency goes to
+// stats, the time from the end of each PUT until the replica served the
+// object goes to lag.
+func runReplication(thread_num int, stats *Stats, lag *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	replica := newReplicaClient()
+	buf := integrityBuffer()
+	pending := make([]pendingReplica, 0)
+	checked := time.Now()
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		objnum := atomic.AddInt64(&op_counter, 1)
+		if object_count > -1 && objnum >= object_count {
+			atomic.AddInt64(&op_counter, -1)
+			break
+		}
+		bucket := &buckets[workerBucket(thread_num, objnum)]
+		key := objectKey(objnum)
+		body := objectBody(buf, key)
+		size, _ := body.Seek(0, io.SeekEnd)
+		body.Seek(0, io.SeekStart)
+		r := &s3.PutObjectInput{
+			Bucket: bucket,
+			Key:    &key,
+			Body:   body,
+		}
+
+		start := time.Now().UnixNano()
+		req, _ := svc.PutObjectRequest(r)
+		// Disable payload checksum calculation (very expensive)
+		req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		err := req.Send()
+		end := time.Now().UnixNano()
+
+		if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, "replication put", err) {
+				break
+			}
+		} else {
+			stats.addBucketOp(thread_num, *bucket, size, end-start)
+			pending = append(pending, pendingReplica{*bucket, key, size, end})
+		}
+		if time.Since(checked) >= replica_poll {
+			pending = checkReplicas(thread_num, replica, pending, lag)
+			checked = time.Now()
+		}
+		think(start)
+	}
+	stats.finish(thread_num)
+
+	// Wait for the rest of this thread's objects to reach the replica
+	for len(pending) > 0 {
+		time.Sleep(time.Until(checked.Add(replica_poll)))
+		pending = checkReplicas(thread_num, replica, pending, lag)
+		checked = time.Now()
+	}
+	lag.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+var report_output string
+
+// configParam -- one command line option and the value it ran with
+type configParam struct {
+	Name  string
+	Value string
+}
+
+var runConfig []configParam
+
+// reportPhase -- the rows of one mode in one loop
+type reportPhase struct {
+	Title      string
+	Intervals  []OutputStats
+	Total      OutputStats
+	Throughput template.HTML
+	Latency    template.HTML
+}
+
+// splitPhases -- group the aggregate rows into phases, leaving out per-thread,
+// per-bucket and cross-loop rows
+func splitPhases(oStats []OutputStats) []reportPhase {
+	phases := make([]reportPhase, 0)
+	cur := reportPhase{}
+	for _, o := range oStats {
+		if o.Thread != -1 || o.Bucket != "" || o.Loop < 0 {
+			continue
+		}
+		if o.IntervalName == "TOTAL" {
+			cur.Title = fmt.Sprintf("Loop %d %s", o.Loop, o.Mode)
+			cur.Total = o
+			phases = append(phases, cur)
+			cur = reportPhase{}
+			continue
+		}
+		cur.Intervals = append(cur.Intervals, o)
+	}
+	return phases
+}
+
+// chartSeries -- one line of a chart
+type chartSeries struct {
+	Name   string
+	Color  string
+	Values []float64
+}
+
+// svgChart -- render a simple self-contained SVG line chart
+func svgChart(unit string, series []chartSeries) template.HTML {
+	const width, height, pad = 640.0, 220.0, 40.0
+	n := 0
+	top := 0.0
+	for _, s := range series {
+		n = max(n, len(s.Values))
+		for _, v := range s.Values {
+			top = math.Max(top, v)
+		}
+	}
+	if n == 0 {
+		return template.HTML("<p>No interval data.</p>")
+	}
+	if top == 0 {
+		top = 1
+	}
+	x := func(i int) float64 {
+		if n == 1 {
+			return pad
+		}
+		return pad + float64(i)*(width-2*pad)/float64(n-1)
+	}
+	y := func(v float64) float64 { return height - pad - v/top*(height-2*pad) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" font-size="11">`, width, height)
+	fmt.Fprintf(&b, `<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" stroke="#888"/>`, pad, height-pad, width-pad, height-pad)
+	fmt.Fprintf(&b, `<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" stroke="#888"/>`, pad, pad, pad, height-pad)
+	fmt.Fprintf(&b, `<text x="2" y="%.0f">%s</text>`, pad-8, template.HTMLEscapeString(unit))
+	fmt.Fprintf(&b, `<text x="2" y="%.0f">%.4g</text>`, pad+4, top)
+	fmt.Fprintf(&b, `<text x="2" y="%.0f">0</text>`, height-pad)
+	fmt.Fprintf(&b, `<text x="%.0f" y="%.0f">interval</text>`, width/2, height-8)
+	for si, s := range series {
+		points := make([]string, len(s.Values))
+		for i, v := range s.Values {
+			points[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(v))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`, s.Color, strings.Join(points, " "))
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" fill="%s">%s</text>`, width-pad-120, pad+float64(si)*14, s.Color, template.HTMLEscapeString(s.Name))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>hsbench report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+th { background: #eee; }
+td.name { text-align: left; }
+</style>
+</head>
+<body>
+<h1>hsbench report</h1>
+<p>Generated {{.Generated}}</p>
+<h2>Totals</h2>
+<table>
+<tr><th>Loop</th><th>Mode</th><th>Dur(s)</th><th>Ops</th><th>MB/s</th><th>IO/s</th><th>min</th><th>avg</th><th>50%</th><th>75%</th><th>90%</th><th>95%</th><th>99%</th><th>max</th><th>Slowdowns</th></tr>
+{{range .Phases}}{{with .Total}}<tr><td>{{.Loop}}</td><td class="name">{{.Mode}}</td><td>{{printf "%.2f" .Seconds}}</td><td>{{.Ops}}</td><td>{{printf "%.2f" .Mbps}}</td><td>{{printf "%.0f" .Iops}}</td><td>{{printf "%.2f" .MinLat}}</td><td>{{printf "%.2f" .AvgLat}}</td><td>{{printf "%.2f" .Lat50}}</td><td>{{printf "%.2f" .Lat75}}</td><td>{{printf "%.2f" .Lat90}}</td><td>{{printf "%.2f" .Lat95}}</td><td>{{printf "%.2f" .Lat99}}</td><td>{{printf "%.2f" .MaxLat}}</td><td>{{.Slowdowns}}</td></tr>
+{{end}}{{end}}</table>
+{{if .Aggregates}}<h2>Across loops</h2>
+<table>
+<tr><th>Mode</th><th>Stat</th><th>MB/s</th><th>IO/s</th><th>avg</th><th>50%</th><th>99%</th></tr>
+{{range .Aggregates}}<tr><td class="name">{{.Mode}}</td><td class="name">{{.IntervalName}}</td><td>{{printf "%.2f" .Mbps}}</td><td>{{printf "%.2f" .Iops}}</td><td>{{printf "%.2f" .AvgLat}}</td><td>{{printf "%.2f" .Lat50}}</td><td>{{printf "%.2f" .Lat99}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Phases</h2>
+{{range .Phases}}<h3>{{.Title}}</h3>
+{{.Throughput}}
+{{.Latency}}
+{{end}}<h2>Configuration</h2>
+<table>
+{{range .Config}}<tr><td class="name">{{.Name}}</td><td class="name">{{.Value}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeReport -- write a self-contained HTML report if requested
+func writeReport(oStats []OutputStats) {
+	if report_output == "" {
+		return
+	}
+	phases := splitPhases(oStats)
+	for i := range phases {
+		p := &phases[i]
+		mbps := make([]float64, len(p.Intervals))
+		iops := make([]float64, len(p.Intervals))
+		avg := make([]float64, len(p.Intervals))
+		p99 := make([]float64, len(p.Intervals))
+		for j, o := range p.Intervals {
+			mbps[j], iops[j], avg[j], p99[j] = o.Mbps, o.Iops, o.AvgLat, o.Lat99
+		}
+		p.Throughput = svgChart("MB/s, IO/s", []chartSeries{{"MB/s", "#1f77b4", mbps}, {"IO/s", "#ff7f0e", iops}})
+		p.Latency = svgChart("ms", []chartSeries{{"avg", "#2ca02c", avg}, {"99%", "#d62728", p99}})
+	}
+	aggregates := make([]OutputStats, 0)
+	for _, o := range oStats {
+		if o.Loop < 0 {
+			aggregates = append(aggregates, o)
+		}
+	}
+
+	file, err := os.Create(outputPath(report_output))
+	if err != nil {
+		logFatal("Could not open HTML report for writing: ", err)
+	}
+	defer file.Close()
+	err = reportTemplate.Execute(file, struct {
+		Generated  string
+		Phases     []reportPhase
+		Aggregates []OutputStats
+		Config     []configParam
+	}{time.Now().Format(time.RFC1123), phases, aggregates, runConfig})
+	if err != nil {
+		logFatal("Error writing HTML report: ", err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// requestFunc -- prepare the request of a test for object objnum, returning
+// the bucket it goes to, "" to not count it per bucket, and the function
+// sending it, which returns the bytes it moved. Only sending is timed.
+type requestFunc func(objnum int64) (bucket string, send func() (int64, error))
+
+// runRequests -- the loop of a test thread: wait for the next arrival, take
+// the next object from next and send its request, until the phase is over,
+// next runs out or the thread aborts on errors. The caller finishes the
+// thread's stats.
+func runRequests(thread_num int, stats *Stats, what string, next func() (int64, bool), request requestFunc) {
+	errcnt := 0
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		objnum, ok := next()
+		if !ok {
+			break
+		}
+		bucket, send := request(objnum)
+
+		start := time.Now().UnixNano()
+		n, err := send()
+		end := time.Now().UnixNano()
+
+		if err != nil {
+			if stats.requestFailed(thread_num, &errcnt, what, err) {
+				break
+			}
+		} else if bucket == "" {
+			stats.addOp(thread_num, n, end-start)
+		} else {
+			stats.addBucketOp(thread_num, bucket, n, end-start)
+		}
+		think(start)
+	}
+}
+
+// localError -- a request that failed before it was sent, ie while signing
+// it, so the server neither throttled nor slowed down
+type localError struct{ error }
+
+func (e localError) Unwrap() error { return e.error }
+
+// requestFailed -- count a failed request of thread_num towards its errors
+// unless it was throttled, and log it as what. Returns true once the thread
+// has had more than two errors and aborted.
+func (stats *Stats) requestFailed(thread_num int, errcnt *int, what string, err error, args ...interface{}) bool {
+	var local localError
+	if errors.As(err, &local) {
+		*errcnt++
+	} else {
+		if !stats.throttle(thread_num, err) {
+			*errcnt++
+		}
+		stats.addSlowDown(thread_num)
+	}
+	stats.logError(thread_num, what, err, args...)
+	if *errcnt > 2 {
+		stats.abort(thread_num, fmt.Sprintf("too many errors (%d)", *errcnt))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// restore_given_up -- restores of the test still ongoing at -restore-timeout
+var restore_given_up int64
+
+type pendingRestore struct {
+	bucket string
+	key    string
+	start  int64
+}
+
+// runRestore -- issue RestoreObject for every object, then poll HeadObject
+// until each restore has finished.  Request latency goes to stats, the time
+// from request to availability goes to restored.
+func runRestore(thread_num int, rand *ThreadSafeUUID, stats *Stats, restored *Stats) {
+	svc := newWorkerClient(thread_num)
+	pending := make([]pendingRestore, 0)
+	next := func() (int64, bool) {
+		return nextCounted(false, false)
+	}
+	runRequests(thread_num, stats, "restore", next, func(objnum int64) (string, func() (int64, error)) {
+		bucket, key, _ := objectName(thread_num, objnum, rand)
+		r := &s3.RestoreObjectInput{
+			Bucket: bucket,
+			Key:    &key,
+			RestoreRequest: &s3.RestoreRequest{
+				Days:                 &restore_days,
+				GlacierJobParameters: &s3.GlacierJobParameters{Tier: &restore_tier},
+			},
+		}
+		return *bucket, func() (int64, error) {
+			start := time.Now().UnixNano()
+			if _, err := svc.RestoreObject(r); err != nil {
+				return 0, err
+			}
+			pending = append(pending, pendingRestore{*bucket, key, start})
+			return 0, nil
+		}
+	})
+	stats.finish(thread_num)
+
+	// Wait for the restores this thread started to complete
+	poll := time.Duration(restore_poll * float64(time.Second))
+	deadline := time.Now().Add(time.Duration(restore_timeout * float64(time.Second)))
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		remaining := pending[:0]
+		for _, p := range pending {
+			out, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: &p.bucket, Key: &p.key})
+			now := time.Now().UnixNano()
+			if err != nil {
+				// Ask again on the next poll
+				restored.addSlowDown(thread_num)
+				restored.logError(thread_num, "restore head", err)
+				remaining = append(remaining, p)
+				continue
+			}
+			if out.Restore != nil && strings.Contains(*out.Restore, `ongoing-request="false"`) {
+				restored.addOp(thread_num, 0, now-p.start)
+			} else {
+				remaining = append(remaining, p)
+			}
+		}
+		pending = remaining
+		if len(pending) > 0 {
+			time.Sleep(poll)
+		}
+	}
+	if len(pending) > 0 {
+		atomic.AddInt64(&restore_given_up, int64(len(pending)))
+		logWarnf("Thread %d gave up waiting on %d restores", thread_num, len(pending))
+	}
+	restored.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+var rgw_stats bool
+var rgw_admin_endpoint, rgw_admin_key, rgw_admin_secret string
+
+// ServerStats -- the objects and bytes RGW counted in the test buckets
+// before and after a test, to check the client's accounting against
+type ServerStats struct {
+	ObjectsBefore int64
+	ObjectsAfter  int64
+	BytesBefore   int64
+	BytesAfter    int64
+	// What the client counted: successful requests and the bytes they moved
+	ClientOps   int64
+	ClientBytes int64
+}
+
+// rgwBucketInfo -- the part of an admin API bucket info reply with the
+// usage of each category, rgw.main holding the objects
+type rgwBucketInfo struct {
+	Usage map[string]struct {
+		Size       int64 `json:"size"`
+		NumObjects int64 `json:"num_objects"`
+	} `json:"usage"`
+}
+
+// adminCall -- send a request signed like an S3 request to the admin API of
+// the server at endpoint, returning the status and body of the reply
+func adminCall(method string, endpoint string, path string, query url.Values, creds *credentials.Credentials) (int, []byte, error) {
+	u := strings.TrimSuffix(endpoint, "/") + path + "?" + query.Encode()
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err = v4.NewSigner(creds).Sign(req, nil, "s3", region, time.Now()); err != nil {
+		return 0, nil, err
+	}
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		first, _, _ := strings.Cut(strings.TrimSpace(string(body)), "\n")
+		return resp.StatusCode, nil, fmt.Errorf("%s: %s", resp.Status, first)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// adminCredentials -- the keys of an admin user given with key and secret,
+// or the test's own
+func adminCredentials(key string, secret string) *credentials.Credentials {
+	if key == "" {
+		return cfg.Credentials
+	}
+	return credentials.NewStaticCredentials(key, secret, "")
+}
+
+// rgwBucketUsage -- the objects and bytes RGW counts in a bucket, 0 for a
+// bucket that does not exist
+func rgwBucketUsage(bucket string) (int64, int64, error) {
+	endpoint := rgw_admin_endpoint
+	if endpoint == "" {
+		endpoint = url_host
+	}
+	status, body, err := adminCall("GET", endpoint, "/admin/bucket", url.Values{"bucket": {bucket}, "stats": {"true"}},
+		adminCredentials(rgw_admin_key, rgw_admin_secret))
+	if err != nil || status == http.StatusNotFound {
+		return 0, 0, err
+	}
+	var info rgwBucketInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return 0, 0, fmt.Errorf("parsing bucket info: %v", err)
+	}
+	main := info.Usage["rgw.main"]
+	return main.NumObjects, main.Size, nil
+}
+
+// rgwUsage -- the objects and bytes RGW counts over all the test buckets
+func rgwUsage() (int64, int64, error) {
+	var objects, bytes int64
+	for _, bucket := range buckets {
+		o, b, err := rgwBucketUsage(bucket)
+		if err != nil {
+			return 0, 0, fmt.Errorf("bucket %s: %v", bucket, err)
+		}
+		objects += o
+		bytes += b
+	}
+	return objects, bytes, nil
+}
+
+// serverStatsBefore -- start the -rgw-stats of a test, nil when disabled or
+// RGW could not be asked
+func serverStatsBefore() *ServerStats {
+	if !rgw_stats {
+		return nil
+	}
+	objects, bytes, err := rgwUsage()
+	if err != nil {
+		logWarnf("Could not get RGW bucket stats: %v", err)
+		return nil
+	}
+	return &ServerStats{ObjectsBefore: objects, BytesBefore: bytes}
+}
+
+// after -- finish the -rgw-stats of a test and log them next to what the
+// client counted
+func (s *ServerStats) after(stats *Stats) *ServerStats {
+	if s == nil {
+		return nil
+	}
+	objects, bytes, err := rgwUsage()
+	if err != nil {
+		logWarnf("Could not get RGW bucket stats: %v", err)
+		return nil
+	}
+	s.ObjectsAfter, s.BytesAfter = objects, bytes
+	for t := range stats.threadStats {
+		ts := &stats.threadStats[t]
+		ts.mu.Lock()
+		s.ClientOps += ts.ops
+		s.ClientBytes += ts.bytes
+		ts.mu.Unlock()
+	}
+	logInfof("Loop %d %s RGW stats: objects %d -> %d (%+d), bytes %d -> %d (%+d); client: %d ops, %d bytes",
+		stats.loop, stats.mode, s.ObjectsBefore, s.ObjectsAfter, s.ObjectsAfter-s.ObjectsBefore,
+		s.BytesBefore, s.BytesAfter, s.BytesAfter-s.BytesBefore, s.ClientOps, s.ClientBytes)
+	return s
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var rmw_region int64
+
+// runReadModifyWrite -- GET an object, change a random region of it and PUT
+// the whole object back, timing the complete cycle
+func runReadModifyWrite(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	var buf bytes.Buffer
+	next := func() (int64, bool) {
+		return nextCounted(loop_objects && duration_secs > -1, false)
+	}
+	runRequests(thread_num, stats, "read-modify-write", next, func(objnum int64) (string, func() (int64, error)) {
+		bucket, key, _ := objectName(thread_num, objnum, rand)
+		return *bucket, func() (int64, error) {
+			req, resp := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: bucket, Key: &key})
+			if err := req.Send(); err != nil {
+				return 0, err
+			}
+			buf.Reset()
+			_, err := buf.ReadFrom(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return 0, err
+			}
+			// Invert a region of the object so every write changes it
+			data := buf.Bytes()
+			region := min(rmw_region, int64(len(data)))
+			offset := int64(0)
+			if int64(len(data)) > region {
+				offset = int64(rand.intn(int(int64(len(data)) - region + 1)))
+			}
+			for i := offset; i < offset+region; i++ {
+				data[i] ^= 0xff
+			}
+			preq, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+				Bucket: bucket,
+				Key:    &key,
+				Body:   bytes.NewReader(data),
+			})
+			// Disable payload checksum calculation (very expensive)
+			preq.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+			// The object crossed the wire twice
+			return 2 * int64(len(data)), preq.Send()
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
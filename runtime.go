@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+var gomaxprocs int
+var gogc string
+
+// RuntimeStats -- Go runtime allocation and GC work during a test, to rule
+// the client out as the bottleneck
+type RuntimeStats struct {
+	GOMAXPROCS   int
+	Mallocs      uint64
+	AllocMB      float64
+	AllocsPerOp  float64
+	NumGC        uint32
+	GCPauseMs    float64
+	GCCPUPercent float64
+}
+
+var runtimeBase runtime.MemStats
+
+// setupRuntime -- apply -gomaxprocs and -gogc to the process
+func setupRuntime() {
+	if gomaxprocs > 0 {
+		runtime.GOMAXPROCS(gomaxprocs)
+	}
+	switch gogc {
+	case "":
+	case "off":
+		debug.SetGCPercent(-1)
+	default:
+		percent, _ := strconv.Atoi(gogc)
+		debug.SetGCPercent(percent)
+	}
+}
+
+// checkGOGC -- validate a -gogc argument
+func checkGOGC(arg string) error {
+	if arg == "" || arg == "off" {
+		return nil
+	}
+	if percent, err := strconv.Atoi(arg); err != nil || percent <= 0 {
+		return fmt.Errorf("%q must be off or a positive percentage", arg)
+	}
+	return nil
+}
+
+// startRuntime -- take the runtime counters a test is measured from
+func startRuntime() {
+	runtime.ReadMemStats(&runtimeBase)
+}
+
+// runtimeSince -- the runtime work since startRuntime, ops being the
+// operations it is spread over
+func runtimeSince(ops int) *RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	rs := &RuntimeStats{
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		Mallocs:      m.Mallocs - runtimeBase.Mallocs,
+		AllocMB:      float64(m.TotalAlloc-runtimeBase.TotalAlloc) / (1024 * 1024),
+		NumGC:        m.NumGC - runtimeBase.NumGC,
+		GCPauseMs:    float64(m.PauseTotalNs-runtimeBase.PauseTotalNs) / 1e6,
+		GCCPUPercent: m.GCCPUFraction * 100,
+	}
+	if ops > 0 {
+		rs.AllocsPerOp = float64(rs.Mallocs) / float64(ops)
+	}
+	return rs
+}
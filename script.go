@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var script_arg string
+
+// scriptStep -- one operation of the -script
+type scriptStep struct {
+	text string
+	op   string
+	name string
+	// Byte range of a get, to -1 for the whole object
+	from, to int64
+
+	mu      sync.Mutex
+	latNano []int64
+	errors  int64
+}
+
+var script []*scriptStep
+
+// parseScript -- parse the -script, operations separated by ; or new lines:
+//
+//	put <key>
+//	get <key> [range <from>-<to>]
+//	head <key>
+//	delete <key>
+//
+// A key is a name, ie A, kept apart for every thread and iteration, or a
+// template using {thread} and {iter}. Lines starting with # are comments.
+func parseScript(arg string) ([]*scriptStep, error) {
+	if path, ok := strings.CutPrefix(arg, "file:"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		arg = string(data)
+	}
+	var steps []*scriptStep
+	for _, line := range strings.Split(arg, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, text := range strings.Split(line, ";") {
+			f := strings.Fields(text)
+			if len(f) == 0 {
+				continue
+			}
+			st := &scriptStep{text: strings.Join(f, " "), op: strings.ToLower(f[0]), to: -1}
+			switch {
+			case len(f) < 2:
+				return nil, fmt.Errorf("%q has no key", st.text)
+			case st.op != "put" && st.op != "get" && st.op != "head" && st.op != "delete":
+				return nil, fmt.Errorf("%q is not a put, get, head or delete", st.text)
+			case len(f) == 4 && st.op == "get" && f[2] == "range":
+				from, to, ok := strings.Cut(f[3], "-")
+				var err1, err2 error
+				st.from, err1 = strconv.ParseInt(from, 10, 64)
+				st.to, err2 = strconv.ParseInt(to, 10, 64)
+				if !ok || err1 != nil || err2 != nil || st.from < 0 || st.to < st.from {
+					return nil, fmt.Errorf("%q needs a range of <from>-<to>", st.text)
+				}
+			case len(f) != 2:
+				return nil, fmt.Errorf("%q has more than a key", st.text)
+			}
+			st.name = f[1]
+			steps = append(steps, st)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no operations")
+	}
+	return steps, nil
+}
+
+// key -- the key the step works on in iteration iter of thread_num
+func (st *scriptStep) key(thread_num int, iter int64) string {
+	if !strings.Contains(st.name, "{") {
+		return fmt.Sprintf("%sscript/%04d/%012d/%s", object_prefix, thread_num, iter, st.name)
+	}
+	return object_prefix + strings.NewReplacer(
+		"{thread}", strconv.Itoa(thread_num),
+		"{iter}", strconv.FormatInt(iter, 10)).Replace(st.name)
+}
+
+// run -- send the step's request, returning the bytes it moved
+func (st *scriptStep) run(svc *s3.S3, bucket *string, key string) (int64, error) {
+	switch st.op {
+	case "put":
+		size := keySize(key)
+		req, _ := svc.PutObjectRequest(&s3.PutObjectInput{Bucket: bucket, Key: &key, Body: payloadBody(key)})
+		// Disable payload checksum calculation (very expensive)
+		req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		return size, req.Send()
+	case "get":
+		r := &s3.GetObjectInput{Bucket: bucket, Key: &key}
+		if st.to >= 0 {
+			r.Range = aws.String(fmt.Sprintf("bytes=%d-%d", st.from, st.to))
+		}
+		out, err := svc.GetObject(r)
+		if err != nil {
+			return 0, err
+		}
+		return drainBody(out.Body)
+	case "head":
+		_, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: bucket, Key: &key})
+		return 0, err
+	default:
+		_, err := svc.DeleteObject(&s3.DeleteObjectInput{Bucket: bucket, Key: &key})
+		return 0, err
+	}
+}
+
+// resetScript -- forget the step latencies of the last 'q' test
+func resetScript() {
+	for _, st := range script {
+		st.latNano, st.errors = nil, 0
+	}
+}
+
+// runScript -- the 'q' mode, run the -script over and over, every request
+// counting as an operation. A failed step skips the rest of the iteration.
+func runScript(thread_num int, stats *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+
+		iter := atomic.AddInt64(&op_counter, 1)
+		if duration_secs <= -1 && iter >= object_count {
+			atomic.AddInt64(&op_counter, -1)
+			break
+		}
+		bucket := &buckets[workerBucket(thread_num, iter)]
+
+		start := time.Now().UnixNano()
+		aborted := false
+		for _, st := range script {
+			key := st.key(thread_num, iter)
+			begin := time.Now().UnixNano()
+			n, err := st.run(svc, bucket, key)
+			end := time.Now().UnixNano()
+			st.mu.Lock()
+			if err != nil {
+				st.errors++
+			} else {
+				st.latNano = append(st.latNano, end-begin)
+			}
+			st.mu.Unlock()
+			if err != nil {
+				aborted = stats.requestFailed(thread_num, &errcnt, "script", err, "step", st.text)
+				break
+			}
+			stats.addBucketOp(thread_num, *bucket, n, end-begin)
+		}
+		if aborted {
+			break
+		}
+		think(start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
+
+// logScript -- log the requests, errors and latency of every step
+func logScript(loop int) {
+	for i, st := range script {
+		lat := st.latNano
+		sort.Slice(lat, func(a, b int) bool { return lat[a] < lat[b] })
+		var avg, p99 float64
+		if len(lat) > 0 {
+			var total int64
+			for _, l := range lat {
+				total += l
+			}
+			avg = float64(total) / float64(len(lat)) / 1e6
+			p99 = float64(lat[max(0, int(math.Round(0.99*float64(len(lat))))-1)]) / 1e6
+		}
+		logInfof("Loop %d script step %d (%s): %d ops, %d errors, avg %.1fms, 99%% %.1fms",
+			loop, i+1, st.text, len(lat), st.errors, avg, p99)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdinSecrets reads the secrets given as -, one line each in the order
+// they are asked for
+var stdinSecrets *bufio.Reader
+
+// readSecret -- the key given to -a, -s or a -workers group: the key itself,
+// file:<path> to read it from a file such as a mounted secret, or - to read
+// a line of stdin, so it never appears in the process list
+func readSecret(value string) (string, error) {
+	var data string
+	switch {
+	case value == "-":
+		if stdinSecrets == nil {
+			stdinSecrets = bufio.NewReader(os.Stdin)
+		}
+		line, err := stdinSecrets.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("reading stdin: %v", err)
+		}
+		data = line
+	case strings.HasPrefix(value, "file:"):
+		content, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", err
+		}
+		data = string(content)
+	default:
+		return value, nil
+	}
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return "", fmt.Errorf("empty key read from %s", value)
+	}
+	return data, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+var seed int64
+
+// Random sources for each use, all derived from -seed. Each has a source of
+// its own so drawing more from one does not change what the others draw.
+var data_rand, arrival_rand, backoff_rand, fault_rand, sample_rand *ThreadSafeUUID
+
+// setupSeed -- derive every random source from -seed, picking the seed from
+// the clock when none was given. The -sd seed of object names is derived
+// too unless it was set.
+func setupSeed(seedSet, sdSet bool) {
+	if !seedSet {
+		seed = time.Now().UnixNano()
+	}
+	master := rand.New(rand.NewSource(seed))
+	data_rand = NewThreadSafeUUID(master.Int63())
+	arrival_rand = NewThreadSafeUUID(master.Int63())
+	backoff_rand = NewThreadSafeUUID(master.Int63())
+	fault_rand = NewThreadSafeUUID(master.Int63())
+	sample_rand = NewThreadSafeUUID(master.Int63())
+	names := master.Int63()
+	if !sdSet {
+		randomize_seed = names
+	}
+	// Record the seeds in effect rather than the flag defaults
+	for i := range runConfig {
+		switch runConfig[i].Name {
+		case "seed":
+			runConfig[i].Value = strconv.FormatInt(seed, 10)
+		case "sd":
+			runConfig[i].Value = strconv.FormatInt(randomize_seed, 10)
+		}
+	}
+}
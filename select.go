@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// fillSelectRecords -- fill buf with newline terminated csv or json records
+func fillSelectRecords(buf []byte, format string) {
+	var header string
+	if format == "csv" {
+		header = "id,name,value\n"
+	}
+	off := copy(buf, header)
+	for i := 0; off < len(buf); i++ {
+		var rec string
+		if format == "csv" {
+			rec = fmt.Sprintf("%d,name%d,%d\n", i, i%100, (i*7919)%100000)
+		} else {
+			rec = fmt.Sprintf("{\"id\":%d,\"name\":\"name%d\",\"value\":%d}\n", i, i%100, (i*7919)%100000)
+		}
+		if off+len(rec) > len(buf) {
+			// Pad the tail with newlines so the last record stays valid
+			for ; off < len(buf); off++ {
+				buf[off] = '\n'
+			}
+			break
+		}
+		off += copy(buf[off:], rec)
+	}
+}
+
+// selectInputSerialization -- describe the stored object format to S3 Select
+func selectInputSerialization() *s3.InputSerialization {
+	switch select_format {
+	case "csv":
+		return &s3.InputSerialization{CSV: &s3.CSVInput{FileHeaderInfo: aws.String(s3.FileHeaderInfoUse)}}
+	default:
+		return &s3.InputSerialization{JSON: &s3.JSONInput{Type: aws.String(s3.JSONTypeLines)}}
+	}
+}
+
+func runSelect(thread_num int, fendtime time.Time, rand *ThreadSafeUUID, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	next := func() (int64, bool) {
+		return nextCounted(loop_objects && duration_secs > -1, false)
+	}
+	runRequests(thread_num, stats, "select", next, func(objnum int64) (string, func() (int64, error)) {
+		bucket, key, _ := objectName(thread_num, objnum, rand)
+		r := &s3.SelectObjectContentInput{
+			Bucket:             bucket,
+			Key:                &key,
+			Expression:         &select_expr,
+			ExpressionType:     aws.String(s3.ExpressionTypeSql),
+			InputSerialization: selectInputSerialization(),
+			OutputSerialization: &s3.OutputSerialization{
+				JSON: &s3.JSONOutput{},
+			},
+			RequestProgress: &s3.RequestProgress{Enabled: aws.Bool(false)},
+		}
+		return *bucket, func() (int64, error) {
+			resp, err := svc.SelectObjectContent(r)
+			if err != nil {
+				return 0, err
+			}
+			returned := int64(0)
+			scanned := int64(0)
+			// The query is only complete once the event stream is drained
+			for event := range resp.EventStream.Events() {
+				switch e := event.(type) {
+				case *s3.RecordsEvent:
+					returned += int64(len(e.Payload))
+				case *s3.StatsEvent:
+					scanned = aws.Int64Value(e.Details.BytesScanned)
+				}
+			}
+			resp.EventStream.Close()
+			if err := resp.EventStream.Err(); err != nil {
+				return 0, err
+			}
+			stats.addScanned(thread_num, scanned)
+			return returned, nil
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var phase_gap time.Duration
+
+// The -settle options: wait between tests until a trickle of HEAD requests
+// is as fast as it was before the first test
+var settle bool
+var settle_rate, settle_margin float64
+var settle_window, settle_timeout time.Duration
+
+// settle_baseline -- the median -settle probe latency before the first test
+var settle_baseline time.Duration
+
+// settled -- the quiet time before the running test, for its summary
+var settled time.Duration
+
+// settleBeforePhase -- wait -phase-gap before every test but the first so
+// the server can finish the compaction and garbage collection the last test
+// left it, which would otherwise be measured as part of this one, then with
+// -settle until the server answers as fast as it did when idle
+func settleBeforePhase(first bool, loop int, phase string) {
+	settled = 0
+	if first {
+		if settle {
+			measureSettleBaseline()
+		}
+		return
+	}
+	start := time.Now()
+	if phase_gap > 0 {
+		logInfof("Loop %d waiting %s before the %s test", loop, phase_gap, phase)
+		time.Sleep(phase_gap)
+	}
+	if settle && settle_baseline > 0 {
+		waitSettled(loop, phase)
+	}
+	settled = time.Since(start)
+}
+
+// settleProbe -- the median latency of the HEAD requests sent to the first
+// bucket at -settle-rate for -settle-window. Errors replied by the server
+// still count, a missing bucket is answered as fast as an existing one.
+func settleProbe(svc *s3.S3) (time.Duration, error) {
+	n := max(1, int(settle_window.Seconds()*settle_rate))
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / settle_rate))
+	defer ticker.Stop()
+	lat := make([]time.Duration, 0, n)
+	var err error
+	for i := 0; i < n; i++ {
+		<-ticker.C
+		start := time.Now()
+		req, _ := svc.HeadBucketRequest(&s3.HeadBucketInput{Bucket: &buckets[0]})
+		err = req.Send()
+		if req.HTTPResponse != nil {
+			lat = append(lat, time.Since(start))
+		}
+	}
+	if len(lat) == 0 {
+		return 0, fmt.Errorf("no replies: %v", err)
+	}
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	return lat[len(lat)/2], nil
+}
+
+// measureSettleBaseline -- probe the server before the first test to learn
+// how fast it answers when idle
+func measureSettleBaseline() {
+	lat, err := settleProbe(newS3Client())
+	if err != nil {
+		logWarnf("Could not measure the -settle baseline, not settling between tests: %v", err)
+		return
+	}
+	settle_baseline = lat
+	logInfof("Settle baseline: median HEAD latency %s", lat)
+}
+
+// waitSettled -- probe the server until the median latency of a window is
+// back within -settle-margin percent of the baseline, or -settle-timeout
+func waitSettled(loop int, phase string) {
+	svc := newS3Client()
+	limit := time.Duration(float64(settle_baseline) * (1 + settle_margin/100))
+	deadline := time.Now().Add(settle_timeout)
+	for {
+		lat, err := settleProbe(svc)
+		if err != nil {
+			logWarnf("Loop %d settle probe before the %s test failed: %v", loop, phase, err)
+		} else if lat <= limit {
+			logInfof("Loop %d settled before the %s test: median HEAD latency %s, baseline %s", loop, phase, lat, settle_baseline)
+			return
+		} else {
+			logInfof("Loop %d waiting to settle before the %s test: median HEAD latency %s, baseline %s", loop, phase, lat, settle_baseline)
+		}
+		if time.Now().After(deadline) {
+			logWarnf("Loop %d starting the %s test unsettled after %s", loop, phase, settle_timeout)
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+var per_conn_bw_arg string
+var per_conn_bw int64
+
+// tokenBucket -- bytes one direction of a connection may move, refilled at
+// -per-conn-bw and holding a twentieth of a second of it so the traffic
+// stays smooth
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// The clock, replaced by the tests
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	burst := max(float64(rate)/20, 1500)
+	return &tokenBucket{rate: float64(rate), burst: burst, tokens: burst, last: time.Now(), now: time.Now, sleep: time.Sleep}
+}
+
+// take -- spend n bytes, waiting for the bucket to refill once overspent
+func (b *tokenBucket) take(n int) {
+	b.mu.Lock()
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+b.rate*now.Sub(b.last).Seconds())
+	b.last = now
+	b.tokens -= float64(n)
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if wait > 0 {
+		b.sleep(wait)
+	}
+}
+
+// shapedConn -- a connection reading and writing at -per-conn-bw each way,
+// like a client at the end of a slow link
+type shapedConn struct {
+	net.Conn
+	rd, wr *tokenBucket
+}
+
+func (c *shapedConn) Read(p []byte) (int, error) {
+	if len(p) > int(c.rd.burst) {
+		p = p[:int(c.rd.burst)]
+	}
+	n, err := c.Conn.Read(p)
+	c.rd.take(n)
+	return n, err
+}
+
+func (c *shapedConn) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), int(c.wr.burst))]
+		c.wr.take(len(chunk))
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// shapingDialer -- wrap a dial function to shape the connections it opens
+func shapingDialer(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &shapedConn{Conn: conn, rd: newTokenBucket(per_conn_bw), wr: newTokenBucket(per_conn_bw)}, nil
+	}
+}
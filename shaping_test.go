@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock -- a clock only moving when the bucket sleeps or the test
+// advances it
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+	c.slept += d
+}
+
+// fakeBucket -- a bucket of rate bytes per second on a fake clock
+func fakeBucket(rate int64) (*tokenBucket, *fakeClock) {
+	c := &fakeClock{now: time.Unix(0, 0)}
+	b := newTokenBucket(rate)
+	b.last = c.now
+	b.now = func() time.Time { return c.now }
+	b.sleep = c.sleep
+	return b, c
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	tests := []struct {
+		rate  int64
+		burst float64
+	}{
+		{1 << 20, 1 << 20 / 20.0},
+		{100 << 20, 100 << 20 / 20.0},
+		{20000, 1500},
+		{1, 1500},
+	}
+	for _, tt := range tests {
+		b := newTokenBucket(tt.rate)
+		if b.burst != tt.burst || b.tokens != tt.burst {
+			t.Errorf("newTokenBucket(%d) burst %g tokens %g, want %g", tt.rate, b.burst, b.tokens, tt.burst)
+		}
+	}
+}
+
+func TestTokenBucketRate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  int64
+		chunk int
+		total int
+	}{
+		{"burst sized chunks", 1 << 20, 1 << 20 / 20, 1 << 20 / 4},
+		{"small chunks", 1 << 20, 4096, 1 << 20 / 4},
+		{"chunks over the burst", 1 << 20, 1 << 17, 1 << 20 / 4},
+		{"fast", 16 << 20, 1 << 16, 4 << 20},
+		{"slow", 20000, 1500, 6000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, c := fakeBucket(tt.rate)
+			sent := 0
+			for sent < tt.total {
+				b.take(tt.chunk)
+				sent += tt.chunk
+			}
+			// The bucket starts full, so the first burst goes without waiting
+			want := time.Duration((float64(sent) - b.burst) / float64(tt.rate) * float64(time.Second))
+			if diff := c.slept - want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("%d bytes at %d/s slept %s, want %s", sent, tt.rate, c.slept, want)
+			}
+		})
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	// A burst of 100000 bytes, refilled in 50ms
+	b, c := fakeBucket(2000000)
+	b.take(int(b.burst))
+	if c.slept != 0 {
+		t.Errorf("the first burst slept %s", c.slept)
+	}
+	// Idling refills at most one burst, which then goes without waiting
+	c.now = c.now.Add(time.Second)
+	b.take(int(b.burst))
+	if c.slept != 0 {
+		t.Errorf("a burst after idling slept %s", c.slept)
+	}
+	b.take(int(b.burst))
+	if want := 50 * time.Millisecond; c.slept < want-time.Microsecond || c.slept > want+time.Microsecond {
+		t.Errorf("a second burst slept %s, want %s", c.slept, want)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import "sync/atomic"
+
+var key_shard bool
+
+// shardCounter -- the objects a thread has taken in a test, kept on a cache
+// line of its own so the threads do not contend for it
+type shardCounter struct {
+	n int64
+	_ [56]byte
+}
+
+var shard_next []shardCounter
+
+// shard_written -- the objects each thread wrote in the last PUT test, when
+// that test ran for a duration instead of to -n objects
+var shard_written []int64
+
+// resetShards -- start every thread at the first object of its shard
+func resetShards() {
+	shard_next = make([]shardCounter, threads)
+}
+
+// shardSize -- the number of objects in the shard of thread_num, or -1 if
+// the shard is unbounded
+func shardSize(thread_num int) int64 {
+	if object_count_flag && shard_written != nil {
+		return shard_written[thread_num]
+	}
+	if object_count < 0 {
+		return -1
+	}
+	return (object_count - int64(thread_num) + int64(threads) - 1) / int64(threads)
+}
+
+// nextObject -- the number of the next object for thread_num to work on,
+// false once there are no more. Without -key-shard every thread takes from
+// the shared op_counter; with it thread n takes the objects n, n+threads,
+// n+2*threads and so on from a counter of its own. When wrap is set the
+// objects are gone through again rather than running out, and when sample
+// is set only the -subset of them is gone through.
+func nextObject(thread_num int, wrap bool, sample bool) (int64, bool) {
+	if !key_shard {
+		return nextCounted(wrap, sample)
+	}
+	c := &shard_next[thread_num]
+	i := c.n
+	size := shardSize(thread_num)
+	limit := size
+	if sample {
+		limit = subsetSize(size)
+	}
+	if wrap && limit > 0 {
+		i = i % limit
+	} else if limit > -1 && i >= limit {
+		return 0, false
+	}
+	c.n++
+	if sample {
+		i = subsetObject(i, size)
+	}
+	return int64(thread_num) + i*int64(threads), true
+}
+
+// nextCounted -- the next object from the op_counter all threads share,
+// false once there are no more, going through them again with wrap and only
+// through the -subset of them with sample
+func nextCounted(wrap bool, sample bool) (int64, bool) {
+	limit := object_count
+	if sample {
+		limit = subsetSize(object_count)
+	}
+	i := atomic.AddInt64(&op_counter, 1)
+	if wrap {
+		i = i % limit
+	}
+	if limit > -1 && i >= limit {
+		atomic.AddInt64(&op_counter, -1)
+		return 0, false
+	}
+	if sample {
+		return subsetObject(i, object_count), true
+	}
+	return i, true
+}
+
+// putBackObject -- hand back the object nextObject gave thread_num, so a
+// failed PUT does not count towards the objects written
+func putBackObject(thread_num int) {
+	if !key_shard {
+		atomic.AddInt64(&op_counter, -1)
+		return
+	}
+	shard_next[thread_num].n--
+}
+
+// recordShards -- remember the objects each thread wrote in a PUT test
+// that ran for a duration, returning the total
+func recordShards() int64 {
+	shard_written = make([]int64, threads)
+	var total int64
+	for i := range shard_next {
+		shard_written[i] = shard_next[i].n
+		total += shard_written[i]
+	}
+	return total
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// setShards -- set the globals nextObject and shardSize read for a test,
+// restoring them when it ends
+func setShards(t *testing.T, shard bool, nthreads int, count int64) {
+	oldShard, oldThreads, oldCount, oldFlag := key_shard, threads, object_count, object_count_flag
+	oldWritten, oldCounter, oldSubset := shard_written, op_counter, subset
+	t.Cleanup(func() {
+		key_shard, threads, object_count, object_count_flag = oldShard, oldThreads, oldCount, oldFlag
+		shard_written, op_counter, subset = oldWritten, oldCounter, oldSubset
+	})
+	key_shard, threads, object_count, object_count_flag = shard, nthreads, count, false
+	shard_written, op_counter, subset = nil, -1, 1
+	resetShards()
+}
+
+// takeObjects -- the objects thread_num gets from nextObject, at most max of them
+func takeObjects(thread_num int, wrap bool, sample bool, max int) []int64 {
+	got := []int64{}
+	for len(got) < max {
+		i, ok := nextObject(thread_num, wrap, sample)
+		if !ok {
+			break
+		}
+		got = append(got, i)
+	}
+	return got
+}
+
+func TestShardSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		threads int
+		count   int64
+		want    []int64
+	}{
+		{"even", 4, 8, []int64{2, 2, 2, 2}},
+		{"uneven", 3, 10, []int64{4, 3, 3}},
+		{"threads over objects", 5, 3, []int64{1, 1, 1, 0, 0}},
+		{"no objects", 2, 0, []int64{0, 0}},
+		{"unbounded", 2, -1, []int64{-1, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setShards(t, true, tt.threads, tt.count)
+			got := make([]int64, tt.threads)
+			for n := range got {
+				got[n] = shardSize(n)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shardSize = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShardSizeWritten(t *testing.T) {
+	setShards(t, true, 3, 100)
+	object_count_flag = true
+	shard_written = []int64{5, 0, 7}
+	for n, want := range shard_written {
+		if got := shardSize(n); got != want {
+			t.Errorf("shardSize(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestNextObjectShard(t *testing.T) {
+	tests := []struct {
+		name    string
+		threads int
+		count   int64
+		wrap    bool
+		max     int
+		want    [][]int64
+	}{
+		{"strides", 3, 7, false, 10, [][]int64{{0, 3, 6}, {1, 4}, {2, 5}}},
+		{"threads over objects", 4, 2, false, 10, [][]int64{{0}, {1}, {}, {}}},
+		{"threads over objects wrap", 4, 2, true, 3, [][]int64{{0, 0, 0}, {1, 1, 1}, {}, {}}},
+		{"wrap", 2, 5, true, 5, [][]int64{{0, 2, 4, 0, 2}, {1, 3, 1, 3, 1}}},
+		{"unbounded", 2, -1, false, 3, [][]int64{{0, 2, 4}, {1, 3, 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setShards(t, true, tt.threads, tt.count)
+			for n, want := range tt.want {
+				if got := takeObjects(n, tt.wrap, false, tt.max); !reflect.DeepEqual(got, want) {
+					t.Errorf("thread %d got %v, want %v", n, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNextObjectShared(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int64
+		wrap  bool
+		max   int
+		want  []int64
+	}{
+		{"to the end", 3, false, 10, []int64{0, 1, 2}},
+		{"wrap", 3, true, 7, []int64{0, 1, 2, 0, 1, 2, 0}},
+		{"no objects", 0, false, 10, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setShards(t, false, 4, tt.count)
+			if got := takeObjects(0, tt.wrap, false, tt.max); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutBackObject(t *testing.T) {
+	for _, shard := range []bool{false, true} {
+		setShards(t, shard, 2, 10)
+		first, _ := nextObject(1, false, false)
+		putBackObject(1)
+		if again, _ := nextObject(1, false, false); again != first {
+			t.Errorf("key_shard=%t: got %d after putting back %d", shard, again, first)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The 'T' mode: read the objects as training data shards
+var epochs int
+var shard_range_arg string
+var shard_range int64
+var shard_parallel int
+
+// shardQueue -- the order the shards are read in, shuffled again for every
+// epoch like a data loader does
+type shardQueue struct {
+	mu    sync.Mutex
+	rand  *ThreadSafeUUID
+	loop  int
+	order []int64
+	pos   int
+	epoch int
+}
+
+func newShardQueue(loop int, rand *ThreadSafeUUID) *shardQueue {
+	q := &shardQueue{rand: rand, loop: loop, order: make([]int64, object_count)}
+	for i := range q.order {
+		q.order[i] = int64(i)
+	}
+	q.shuffle()
+	return q
+}
+
+func (q *shardQueue) shuffle() {
+	q.rand.shuffle(len(q.order), func(i, j int) { q.order[i], q.order[j] = q.order[j], q.order[i] })
+	logInfof("Loop %d starting epoch %d of %d", q.loop, q.epoch+1, epochs)
+}
+
+// next -- the next shard to read, false once -epochs passes are done
+func (q *shardQueue) next() (int64, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pos == len(q.order) {
+		if q.epoch++; q.epoch >= epochs {
+			return 0, false
+		}
+		q.pos = 0
+		q.shuffle()
+	}
+	q.pos++
+	return q.order[q.pos-1], true
+}
+
+// runShardRead -- read whole shards in -shard-range requests taken in order,
+// -shard-parallel of them at a time, the way PyTorch and TensorFlow S3 data
+// loaders read training data. Every range is an operation.
+func runShardRead(thread_num int, queue *shardQueue, stats *Stats) {
+	errcnt := 0
+	svc := newWorkerClient(thread_num)
+	for {
+		stats.arrive(thread_num)
+		if phaseOver() {
+			break
+		}
+		objnum, ok := queue.next()
+		if !ok {
+			break
+		}
+		bucket, key, size := objectName(thread_num, objnum, nil)
+
+		start := time.Now().UnixNano()
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var errs []error
+		inflight := make(chan struct{}, shard_parallel)
+		for offset := int64(0); offset < size && !phaseOver(); offset += shard_range {
+			inflight <- struct{}{}
+			wg.Add(1)
+			go func(offset int64) {
+				defer func() { <-inflight; wg.Done() }()
+				n := min(shard_range, size-offset)
+				rng := fmt.Sprintf("bytes=%d-%d", offset, offset+n-1)
+				begin := time.Now().UnixNano()
+				req, resp := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: bucket, Key: &key, Range: &rng})
+				err := req.Send()
+				if err == nil {
+					_, err = drainBody(resp.Body)
+				}
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					return
+				}
+				stats.addBucketOp(thread_num, *bucket, n, time.Now().UnixNano()-begin)
+			}(offset)
+		}
+		wg.Wait()
+		// Backing off is done by the thread, not each range it has in flight
+		aborted := false
+		for _, err := range errs {
+			if stats.requestFailed(thread_num, &errcnt, "shard read", err) {
+				aborted = true
+				break
+			}
+		}
+		if aborted {
+			break
+		}
+		think(start)
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
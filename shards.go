@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var bucket_shards int
+var shard_command string
+
+// setBucketShards -- give a bucket the 'i' mode created -bucket-shards index
+// shards by running -shard-command with {bucket} and {shards} filled in. RGW
+// takes no shard count on bucket creation and its admin API can only report
+// one, so this is radosgw-admin by default.
+func setBucketShards(bucket string) error {
+	if bucket_shards == 0 {
+		return nil
+	}
+	command := strings.NewReplacer("{bucket}", bucket, "{shards}", strconv.Itoa(bucket_shards)).Replace(shard_command)
+	out, err := exec.Command("sh", "-c", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", command, err, strings.TrimSpace(string(out)))
+	}
+	logDebugf("Set %d index shards on bucket %s", bucket_shards, bucket)
+	return nil
+}
+
+// tagBucketShards -- record the shard count with every result row and in
+// the history, unless a -tag already names it
+func tagBucketShards() {
+	if _, ok := run_tags["bucket_shards"]; ok || bucket_shards == 0 {
+		return
+	}
+	run_tags["bucket_shards"] = strconv.Itoa(bucket_shards)
+}
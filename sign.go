@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var sign_payload bool
+
+// runSign -- build and sign the requests the PUT test would send without
+// sending them, so the latency is the client CPU cost of each request
+func runSign(thread_num int, rand *ThreadSafeUUID, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	next := func() (int64, bool) {
+		return nextCounted(false, false)
+	}
+	runRequests(thread_num, stats, "sign", next, func(objnum int64) (string, func() (int64, error)) {
+		key := objectKey(objnum)
+		r := &s3.PutObjectInput{
+			Bucket:             &buckets[workerBucket(thread_num, objnum)],
+			Key:                &key,
+			Body:               payloadBody(key),
+			ContentType:        content_types.pick(rand),
+			CacheControl:       cache_controls.pick(rand),
+			ContentDisposition: content_dispositions.pick(rand),
+		}
+		return "", func() (int64, error) {
+			setContentMD5(r)
+			req, _ := svc.PutObjectRequest(r)
+			if !sign_payload {
+				req.HTTPRequest.Header.Add("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+			}
+			setChecksum(req)
+			if err := req.Sign(); err != nil {
+				return 0, localError{err}
+			}
+			return 0, nil
+		}
+	})
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
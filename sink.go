@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResultSink -- a destination for result rows. Write is called with every
+// row once the tests have finished, then Close once.
+type ResultSink interface {
+	Write(o *OutputStats) error
+	Close() error
+}
+
+// sinkFactories create a sink from the target of a "-sink kind:target" flag
+var sinkFactories = map[string]func(target string) (ResultSink, error){
+	"csv":        newCSVSink,
+	"timeseries": newTimeSeriesSink,
+	"json":       newJSONSink,
+	"log":        func(string) (ResultSink, error) { return logSink{}, nil },
+	"prometheus": func(target string) (ResultSink, error) { return &textSink{target: target, line: promLines}, nil },
+	"influx": func(target string) (ResultSink, error) {
+		return &textSink{target: target, line: influxLines, appendable: true}, nil
+	},
+}
+
+var sink_args stringListFlag
+var sinks []ResultSink
+var output_mode string
+
+// run_stamp names the files of this run when -output-mode is new
+var run_stamp = time.Now().Format("20060102-150405")
+
+// sinkPath -- the file a sink writes to, with the rotation period of a soak
+// test before the extension
+func sinkPath(name string) string {
+	name = outputPath(name)
+	if rotation_stamp == "" {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + rotation_stamp + ext
+}
+
+// outputPath -- the file a result output is written to. With -output-mode new
+// the -run-id, or else the run's start time, goes before the extension, ie
+// results-20240102-150405.csv
+func outputPath(name string) string {
+	if output_mode != "new" || name == "" {
+		return name
+	}
+	suffix := run_stamp
+	if run_id != "" {
+		suffix = run_id
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + suffix + ext
+}
+
+// writeFileAtomic -- replace a file through a temporary file and a rename, so
+// a failed write never leaves a truncated or partly overwritten file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0666)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// sinkKinds -- the registered sink kinds, for usage and error messages
+func sinkKinds() string {
+	kinds := make([]string, 0, len(sinkFactories))
+	for k := range sinkFactories {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ", ")
+}
+
+// openSinks -- create the sinks asked for by -o, -j and -sink
+func openSinks() {
+	if soak_rotate > 0 && rotation_stamp == "" {
+		rotateSinks()
+		return
+	}
+	args := make([]string, 0, len(sink_args)+2)
+	if output != "" {
+		args = append(args, "csv:"+output)
+	}
+	if json_output != "" {
+		args = append(args, "json:"+json_output)
+	}
+	args = append(args, sink_args...)
+	for _, arg := range args {
+		kind, target, _ := strings.Cut(arg, ":")
+		factory, ok := sinkFactories[kind]
+		if !ok {
+			logFatalf("Unknown sink %q, must be one of %s", kind, sinkKinds())
+		}
+		sink, err := factory(target)
+		if err != nil {
+			logFatalf("Could not open %s sink %q: %v", kind, target, err)
+		}
+		sinks = append(sinks, sink)
+	}
+}
+
+// writeRow -- send a row to every sink
+func writeRow(o *OutputStats) {
+	for _, sink := range sinks {
+		if err := sink.Write(o); err != nil {
+			logFatal("Error writing results: ", err)
+		}
+	}
+}
+
+// closeSinks -- close every sink, flushing what they buffered
+func closeSinks() {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			logFatal("Error writing results: ", err)
+		}
+	}
+	sinks = nil
+}
+
+// writeSinks -- send every row to every sink and close them
+func writeSinks(oStats []OutputStats) {
+	for i := range oStats {
+		writeRow(&oStats[i])
+	}
+	closeSinks()
+}
+
+// csvSink -- rows as CSV, with a header before the first
+type csvSink struct {
+	file   *os.File
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVSink(target string) (ResultSink, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if output_mode == "append" {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(sinkPath(target), flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	// Only a new or emptied file gets a header, appended rows follow the
+	// header of the first run
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &csvSink{file: file, w: csv.NewWriter(file), header: info.Size() > 0}, nil
+}
+
+func (s *csvSink) Write(o *OutputStats) error {
+	if !s.header {
+		o.csv_header(s.w)
+		s.header = true
+	}
+	o.csv(s.w)
+	return nil
+}
+
+func (s *csvSink) Close() error {
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// timeSeriesSink -- the interval rows of every test as CSV, each with the
+// wall clock time it started and ended at, to line up with server metrics
+type timeSeriesSink struct {
+	csvSink
+}
+
+func newTimeSeriesSink(target string) (ResultSink, error) {
+	s, err := newCSVSink(target)
+	if err != nil {
+		return nil, err
+	}
+	return &timeSeriesSink{*s.(*csvSink)}, nil
+}
+
+func (s *timeSeriesSink) Write(o *OutputStats) error {
+	// Totals, summaries, threads and buckets are not points in time
+	if _, err := strconv.Atoi(o.IntervalName); err != nil || o.Thread != -1 || o.Bucket != "" || o.Start.IsZero() {
+		return nil
+	}
+	if !s.header {
+		s.w.Write([]string{"Start", "End", "Loop", "Mode", "Ops", "IO/s", "MB/s", "Slowdowns",
+			"Min Latency(ms)", "Avg Latency(ms)", "50% Latency(ms)", "75% Latency(ms)",
+			"90% Latency(ms)", "95% Latency(ms)", "99% Latency(ms)", "Max Latency(ms)"})
+		s.header = true
+	}
+	row := []string{formatWallClock(o.Start), formatWallClock(o.End), strconv.Itoa(o.Loop), o.Mode,
+		strconv.Itoa(o.Ops), strconv.FormatFloat(o.Iops, 'f', 2, 64), strconv.FormatFloat(o.Mbps, 'f', 2, 64),
+		strconv.FormatInt(o.Slowdowns, 10)}
+	for _, lat := range []float64{o.MinLat, o.AvgLat, o.Lat50, o.Lat75, o.Lat90, o.Lat95, o.Lat99, o.MaxLat} {
+		row = append(row, strconv.FormatFloat(lat, 'f', 2, 64))
+	}
+	return s.w.Write(row)
+}
+
+// formatWallClock -- a row's start or end time in UTC to the millisecond,
+// empty when the row has none
+func formatWallClock(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// jsonSink -- all rows as one JSON array. In append mode the rows of earlier
+// runs are read back so the file stays a single valid array.
+type jsonSink struct {
+	path string
+	rows []OutputStats
+}
+
+func newJSONSink(target string) (ResultSink, error) {
+	s := &jsonSink{path: sinkPath(target), rows: make([]OutputStats, 0)}
+	if output_mode != "append" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &s.rows); err != nil {
+			return nil, fmt.Errorf("can not append to %s: %v", s.path, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *jsonSink) Write(o *OutputStats) error {
+	s.rows = append(s.rows, *o)
+	return nil
+}
+
+func (s *jsonSink) Close() error {
+	data, err := json.Marshal(s.rows)
+	if err != nil {
+		return fmt.Errorf("marshaling JSON: %v", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// logSink -- log the rows again, ie the aggregate rows at the end of the run
+type logSink struct{}
+
+func (logSink) Write(o *OutputStats) error {
+	o.log()
+	return nil
+}
+
+func (logSink) Close() error {
+	return nil
+}
+
+// sinkMetric -- a named numeric column of a row, for the metric sinks
+type sinkMetric struct {
+	name  string
+	value func(o *OutputStats) float64
+}
+
+var sinkMetrics = []sinkMetric{
+	{"seconds", func(o *OutputStats) float64 { return o.Seconds }},
+	{"ops", func(o *OutputStats) float64 { return float64(o.Ops) }},
+	{"mbps", func(o *OutputStats) float64 { return o.Mbps }},
+	{"iops", func(o *OutputStats) float64 { return o.Iops }},
+	{"min_lat_ms", func(o *OutputStats) float64 { return o.MinLat }},
+	{"avg_lat_ms", func(o *OutputStats) float64 { return o.AvgLat }},
+	{"lat99_ms", func(o *OutputStats) float64 { return o.Lat99 }},
+	{"lat95_ms", func(o *OutputStats) float64 { return o.Lat95 }},
+	{"lat50_ms", func(o *OutputStats) float64 { return o.Lat50 }},
+	{"max_lat_ms", func(o *OutputStats) float64 { return o.MaxLat }},
+	{"slowdowns", func(o *OutputStats) float64 { return float64(o.Slowdowns) }},
+	{"throttled_seconds", func(o *OutputStats) float64 { return o.Throttled }},
+	{"conflicts", func(o *OutputStats) float64 { return float64(o.Conflicts) }},
+	{"quota_rejects", func(o *OutputStats) float64 { return float64(o.QuotaRejects) }},
+	{"corrupt", func(o *OutputStats) float64 { return float64(o.Corrupt) }},
+	{"idle_percent", func(o *OutputStats) float64 { return o.Idle }},
+	{"max_gap_ms", func(o *OutputStats) float64 { return o.MaxGap }},
+}
+
+// textSink -- rows as text metric lines, written to a file or POSTed to an
+// http(s) URL (ie a Prometheus pushgateway or an InfluxDB write endpoint)
+// when closed
+type textSink struct {
+	target     string
+	line       func(buf *bytes.Buffer, o *OutputStats)
+	appendable bool // a file of points, not a snapshot to replace
+	buf        bytes.Buffer
+}
+
+func (s *textSink) Write(o *OutputStats) error {
+	s.line(&s.buf, o)
+	return nil
+}
+
+func (s *textSink) Close() error {
+	if strings.HasPrefix(s.target, "http://") || strings.HasPrefix(s.target, "https://") {
+		resp, err := http.Post(s.target, "text/plain", &s.buf)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s returned %s", s.target, resp.Status)
+		}
+		return nil
+	}
+	path := sinkPath(s.target)
+	if output_mode == "append" && s.appendable {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+		if _, err = file.Write(s.buf.Bytes()); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	return writeFileAtomic(path, s.buf.Bytes())
+}
+
+// promLines -- Prometheus text exposition, one gauge per metric and row
+func promLines(buf *bytes.Buffer, o *OutputStats) {
+	labels := fmt.Sprintf(`loop="%d",interval="%s",thread="%d",mode="%s",bucket="%s"`,
+		o.Loop, o.IntervalName, o.Thread, o.Mode, o.Bucket)
+	if o.RunID != "" {
+		labels += fmt.Sprintf(`,run_id=%s`, strconv.Quote(o.RunID))
+	}
+	for _, k := range tagKeys(o.Tags) {
+		labels += fmt.Sprintf(`,%s=%s`, k, strconv.Quote(o.Tags[k]))
+	}
+	for _, m := range sinkMetrics {
+		fmt.Fprintf(buf, "hsbench_%s{%s} %g\n", m.name, labels, m.value(o))
+	}
+}
+
+// influxLines -- InfluxDB line protocol, one point per row
+func influxLines(buf *bytes.Buffer, o *OutputStats) {
+	fmt.Fprintf(buf, "hsbench,loop=%d,interval=%s,thread=%d,mode=%s", o.Loop, o.IntervalName, o.Thread, o.Mode)
+	if o.Bucket != "" {
+		fmt.Fprintf(buf, ",bucket=%s", influxEscape(o.Bucket))
+	}
+	if o.RunID != "" {
+		fmt.Fprintf(buf, ",run_id=%s", influxEscape(o.RunID))
+	}
+	for _, k := range tagKeys(o.Tags) {
+		if o.Tags[k] != "" {
+			fmt.Fprintf(buf, ",%s=%s", k, influxEscape(o.Tags[k]))
+		}
+	}
+	for i, m := range sinkMetrics {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(buf, "%s%s=%g", sep, m.name, m.value(o))
+	}
+	// Points go at the time their interval started rather than when written
+	if !o.Start.IsZero() {
+		fmt.Fprintf(buf, " %d", o.Start.UnixNano())
+	}
+	buf.WriteString("\n")
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influxEscape -- escape a tag value for the line protocol
+func influxEscape(v string) string {
+	return influxEscaper.Replace(v)
+}
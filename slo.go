@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var slo_args stringListFlag
+
+// sloSpec -- a latency objective, ie "99% GET < 20ms"
+type sloSpec struct {
+	spec    string
+	target  float64 // share of requests that must be fast enough, 0-1
+	mode    string  // test the objective applies to, * for every test
+	maxNano int64
+}
+
+// SLOResult -- how an interval or a whole test did against one -slo
+type SLOResult struct {
+	SLO string
+	// Percentage of requests faster than the objective's latency, failed
+	// requests counting as slow
+	Compliance float64
+	// Percentage of the requests allowed to be slow that were not used up,
+	// negative once overspent
+	BudgetLeft float64
+	Met        bool
+}
+
+var slos []sloSpec
+
+var sloPattern = regexp.MustCompile(`^\s*([0-9.]+)%\s*(?:of\s+)?(\*|[A-Za-z]+)\s*<\s*(\S+)\s*$`)
+
+// parseSLO -- parse "<percent>% <test> < <latency>", the test being the
+// mode shown in the results, ie GET, or * for every test
+func parseSLO(spec string) (sloSpec, error) {
+	m := sloPattern.FindStringSubmatch(spec)
+	if m == nil {
+		return sloSpec{}, fmt.Errorf("%q is not in \"99%% GET < 20ms\" form", spec)
+	}
+	pct, err := strconv.ParseFloat(m[1], 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return sloSpec{}, fmt.Errorf("%q needs a percentage above 0%% and up to 100%%", spec)
+	}
+	lat, err := time.ParseDuration(m[3])
+	if err != nil || lat <= 0 {
+		return sloSpec{}, fmt.Errorf("%q needs a positive latency such as 20ms", spec)
+	}
+	// "99% of GETs < 20ms" reads better and means the same
+	mode := strings.ToUpper(strings.TrimSuffix(m[2], "s"))
+	return sloSpec{strings.Join(strings.Fields(spec), " "), pct / 100, mode, lat.Nanoseconds()}, nil
+}
+
+// sloResults -- judge the sorted latencies and failed requests of an
+// interval of the test mode against each -slo applying to it
+func sloResults(mode string, latNano []int64, failed int64) []SLOResult {
+	var results []SLOResult
+	for _, s := range slos {
+		events := int64(len(latNano)) + failed
+		if s.mode != "*" && s.mode != mode || events == 0 {
+			continue
+		}
+		fast := int64(sort.Search(len(latNano), func(i int) bool { return latNano[i] >= s.maxNano }))
+		allowed := (1 - s.target) * float64(events)
+		// A 100% objective has no budget to spend
+		left := 100.0
+		if slow := float64(events - fast); allowed > 0 {
+			left = 100 * (allowed - slow) / allowed
+		} else if slow > 0 {
+			left = 0
+		}
+		results = append(results, SLOResult{
+			SLO:        s.spec,
+			Compliance: 100 * float64(fast) / float64(events),
+			BudgetLeft: left,
+			Met:        float64(fast) >= s.target*float64(events),
+		})
+	}
+	return results
+}
+
+// logSLOs -- log how the row did against each -slo
+func (o *OutputStats) logSLOs() {
+	for _, r := range o.SLOs {
+		met := "met"
+		if !r.Met {
+			met = "MISSED"
+		}
+		logInfof("Loop: %d, Int: %s, Mode: %s, SLO: %s, Compliance: %.3f%%, Error budget left: %.1f%%, %s",
+			o.Loop, o.IntervalName, o.Mode, r.SLO, r.Compliance, r.BudgetLeft, met)
+	}
+}
+
+// sloFailures -- the objectives a test missed in total
+func sloFailures(total OutputStats) []string {
+	var reasons []string
+	for _, r := range total.SLOs {
+		if !r.Met {
+			reasons = append(reasons, fmt.Sprintf("SLO %s met by only %.3f%% of requests", r.SLO, r.Compliance))
+		}
+	}
+	return reasons
+}
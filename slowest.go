@@ -0,0 +1,90 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var top_slowest int
+
+// SlowOp -- one of the slowest requests of a test
+type SlowOp struct {
+	Operation string
+	Bucket    string `json:",omitempty"`
+	Key       string `json:",omitempty"`
+	Start     time.Time
+	LatencyMs float64
+	Status    int
+	RequestID string `json:",omitempty"`
+	HostID    string `json:",omitempty"`
+}
+
+// slowHeap -- min-heap on latency holding the slowest requests seen so far
+type slowHeap []SlowOp
+
+func (h slowHeap) Len() int            { return len(h) }
+func (h slowHeap) Less(i, j int) bool  { return h[i].LatencyMs < h[j].LatencyMs }
+func (h slowHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x interface{}) { *h = append(*h, x.(SlowOp)) }
+func (h *slowHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+var slowMu sync.Mutex
+var slowest slowHeap
+
+// paramString -- a string field of the request parameters, if it has one
+func paramString(r *request.Request, name string) string {
+	if v, err := awsutil.ValuesAtPath(r.Params, name); err == nil && len(v) > 0 {
+		if s, ok := v[0].(*string); ok && s != nil {
+			return *s
+		}
+	}
+	return ""
+}
+
+// recordSlowOp -- S3 complete handler keeping the -top-slowest requests of
+// the running test. Latency runs from the last attempt to the response
+// headers, so it leaves out reading the body.
+func recordSlowOp(r *request.Request) {
+	lat := float64(time.Since(r.AttemptTime).Nanoseconds()) / 1000000
+	slowMu.Lock()
+	defer slowMu.Unlock()
+	if len(slowest) >= top_slowest && lat <= slowest[0].LatencyMs {
+		return
+	}
+	op := SlowOp{
+		Operation: r.Operation.Name,
+		Bucket:    paramString(r, "Bucket"),
+		Key:       paramString(r, "Key"),
+		Start:     r.AttemptTime,
+		LatencyMs: lat,
+		RequestID: r.RequestID,
+	}
+	if r.HTTPResponse != nil {
+		op.Status = r.HTTPResponse.StatusCode
+		op.HostID = r.HTTPResponse.Header.Get("X-Amz-Id-2")
+	}
+	heap.Push(&slowest, op)
+	if len(slowest) > top_slowest {
+		heap.Pop(&slowest)
+	}
+}
+
+// takeSlowest -- the slowest requests since the last call, slowest first
+func takeSlowest() []SlowOp {
+	slowMu.Lock()
+	ops := []SlowOp(slowest)
+	slowest = nil
+	slowMu.Unlock()
+	sort.Slice(ops, func(i, j int) bool { return ops[i].LatencyMs > ops[j].LatencyMs })
+	return ops
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+var soak_rotate, soak_summary time.Duration
+
+// The sink file suffix of the current rotation, and when the next one starts
+var rotation_stamp string
+var next_rotation time.Time
+
+// digestBuckets is the number of latency buckets per doubling kept by a
+// latencyDigest, about 2% apart
+const digestBuckets = 32
+
+// latencyDigest -- a log scale latency histogram, so hours of operations can
+// be summarized in constant memory. Percentiles are accurate to a bucket.
+type latencyDigest struct {
+	count    int64
+	sumNano  int64
+	minNano  int64
+	maxNano  int64
+	counts   map[int]int64
+	maxIndex int
+}
+
+func (d *latencyDigest) add(latNano int64) {
+	if d.counts == nil {
+		d.counts = make(map[int]int64)
+	}
+	if d.count == 0 || latNano < d.minNano {
+		d.minNano = latNano
+	}
+	d.maxNano = max(d.maxNano, latNano)
+	d.count++
+	d.sumNano += latNano
+	b := 0
+	if latNano > 1000 {
+		b = int(math.Log2(float64(latNano)/1000) * digestBuckets)
+	}
+	d.counts[b]++
+	d.maxIndex = max(d.maxIndex, b)
+}
+
+// percentile -- the latency in milliseconds below which fraction p of the
+// operations fell, taken as the middle of its bucket
+func (d *latencyDigest) percentile(p float64) float64 {
+	rank := int64(math.Round(p * float64(d.count)))
+	seen := int64(0)
+	for b := 0; b <= d.maxIndex; b++ {
+		seen += d.counts[b]
+		if seen >= rank && seen > 0 {
+			nano := 1000 * math.Pow(2, (float64(b)+0.5)/digestBuckets)
+			return math.Min(math.Max(nano, float64(d.minNano)), float64(d.maxNano)) / 1e6
+		}
+	}
+	return float64(d.maxNano) / 1e6
+}
+
+// soakRollup -- the counters and latency digest of a stretch of a soak test
+type soakRollup struct {
+	is      IntervalStats
+	lat     latencyDigest
+	endNano int64
+}
+
+func (r *soakRollup) add(is *IntervalStats, endNano int64) {
+	for _, lat := range is.latNano {
+		r.lat.add(lat)
+	}
+	counters := *is
+	counters.latNano = nil
+	r.is.merge(&counters)
+	r.endNano = endNano
+}
+
+func (r *soakRollup) outputStats() OutputStats {
+	o := r.is.makeOutputStats()
+	if r.lat.count == 0 {
+		return o
+	}
+	o.Ops = int(r.lat.count)
+	o.Iops = float64(r.lat.count) / o.Seconds
+	o.MinLat = float64(r.lat.minNano) / 1e6
+	o.AvgLat = float64(r.lat.sumNano) / float64(r.lat.count) / 1e6
+	o.Lat99 = r.lat.percentile(0.99)
+	o.Lat95 = r.lat.percentile(0.95)
+	o.Lat90 = r.lat.percentile(0.90)
+	o.Lat75 = r.lat.percentile(0.75)
+	o.Lat50 = r.lat.percentile(0.5)
+	o.MaxLat = float64(r.lat.maxNano) / 1e6
+	// The digest can not tell how many requests beat an -slo
+	o.SLOs = nil
+	return o
+}
+
+// soakState -- the rollups of a test run with -soak-rotate
+type soakState struct {
+	summary     soakRollup
+	summaryFrom int64
+	total       soakRollup
+}
+
+func (stats *Stats) newSoakRollup(name string) soakRollup {
+	return soakRollup{is: stats.newIntervalStats(name, 0)}
+}
+
+// soak -- stream interval i of a soak test to the sinks, fold it into the
+// rollups and drop it, so memory stays flat however long the test runs
+func (stats *Stats) soak(i int64, o OutputStats) {
+	if time.Now().After(next_rotation) {
+		rotateSinks()
+	}
+	writeRow(&o)
+
+	ss := stats.soakState
+	begin := stats.intervalStart(i)
+	end := begin + stats.intervalDuration(i)
+	for t := range stats.threadStats {
+		ts := &stats.threadStats[t]
+		ts.mu.Lock()
+		if is := ts.at(i); is != nil {
+			ss.summary.add(is, end)
+			ss.total.add(is, end)
+		}
+		ts.trim(i + 1)
+		ts.mu.Unlock()
+	}
+	// Idle intervals count towards the duration too
+	if ss.summary.is.startNano == 0 {
+		ss.summary.is.startNano = begin
+	}
+	if ss.total.is.startNano == 0 {
+		ss.total.is.startNano = begin
+	}
+	ss.summary.is.intervalNano += end - begin
+	ss.summary.endNano = end
+	ss.total.is.intervalNano += end - begin
+
+	if time.Duration(end-ss.summaryFrom) >= soak_summary {
+		stats.soakSummary(true)
+	}
+}
+
+// soakSummary -- log the summary of the stretch since the last one and start
+// a new stretch. Summaries made while the test runs go straight to the sinks,
+// the last is returned with the test's other rows.
+func (stats *Stats) soakSummary(stream bool) (OutputStats, bool) {
+	ss := stats.soakState
+	if ss.summary.is.intervalNano == 0 {
+		return OutputStats{}, false
+	}
+	o := ss.summary.outputStats()
+	o.TargetRate = stats.targetRate(ss.summaryFrom, ss.summary.endNano)
+	o.log()
+	if stream {
+		writeRow(&o)
+	}
+	ss.summaryFrom = ss.summary.endNano
+	ss.summary = stats.newSoakRollup("SUMMARY")
+	return o, true
+}
+
+// soakOutputStats -- the rows left to write once a soak test has finished,
+// the intervals having been streamed already
+func (stats *Stats) soakOutputStats() []OutputStats {
+	<-stats.collected
+	os := make([]OutputStats, 0, 2)
+	if o, ok := stats.soakSummary(false); ok {
+		os = append(os, o)
+	}
+	o := stats.soakState.total.outputStats()
+	o.TargetRate = stats.targetRate(stats.startNano, stats.endNano)
+	o.log()
+	return append(os, o)
+}
+
+// rotateSinks -- close the sinks and reopen them on files named after the
+// current rotation period
+func rotateSinks() {
+	closeSinks()
+	now := time.Now()
+	start := now.Truncate(soak_rotate)
+	rotation_stamp = start.Format("20060102-150405")
+	next_rotation = start.Add(soak_rotate)
+	openSinks()
+	logInfof("Rotated result files to %s", rotation_stamp)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+var source_arg string
+var source_file *os.File
+var source_size int64
+var source_next int64
+
+// openSource -- open the -source file or device PUT bodies are read from.
+// A file or block device is read in object_size slices taken in turn, while
+// a character device or pipe is streamed, each body reading on from the last.
+func openSource() error {
+	path := strings.TrimPrefix(source_arg, "file:")
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if fi.Mode().IsRegular() || fi.Mode()&os.ModeDevice != 0 && fi.Mode()&os.ModeCharDevice == 0 {
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if size < object_size {
+			f.Close()
+			return fmt.Errorf("%s holds %d bytes, less than the object size %d", path, size, object_size)
+		}
+		source_size = size
+	}
+	source_file = f
+	return nil
+}
+
+// sourceSeekable -- whether a PUT body can be read again, which retries and
+// the Content-MD5 hash count on
+func sourceSeekable() bool {
+	return source_size > 0
+}
+
+// sourceBody -- the next PUT body from the -source
+func sourceBody() io.ReadSeeker {
+	if !sourceSeekable() {
+		return &streamBody{src: source_file, size: object_size}
+	}
+	slots := source_size / object_size
+	n := atomic.AddInt64(&source_next, 1) - 1
+	return io.NewSectionReader(source_file, n%slots*object_size, object_size)
+}
+
+// payloadBody -- the body of a PUT of key, read from the -source when set
+// and from the key's -data-pool buffer otherwise
+func payloadBody(key string) io.ReadSeeker {
+	if source_file != nil {
+		return sourceBody()
+	}
+	return bytes.NewReader(payloadFor(key).data[:keySize(key)])
+}
+
+// streamBody -- size bytes of a stream. It seeks only in name so the SDK
+// can work out the length; a body read again reads on in the stream.
+type streamBody struct {
+	src  io.Reader
+	size int64
+	pos  int64
+}
+
+func (s *streamBody) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.size-s.pos {
+		p = p[:s.size-s.pos]
+	}
+	n, err := s.src.Read(p)
+	s.pos += int64(n)
+	if err == io.EOF && s.pos < s.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (s *streamBody) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	}
+	if offset < 0 || offset > s.size {
+		return s.pos, errors.New("streamBody.Seek: offset out of range")
+	}
+	s.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The 'F' mode: fill the buckets to each -staircase level in turn and run
+// the -staircase-tests at every level
+var staircase_arg, staircase_tests string
+var staircase_levels []int64
+
+// staircase_level -- the -staircase level the running test measures, as
+// given, ie 10M
+var staircase_level string
+
+// parseCount -- a number of objects with an optional K, M or G postfix for
+// thousands, millions or billions
+func parseCount(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1000
+	case strings.HasSuffix(s, "M"):
+		mult = 1000 * 1000
+	case strings.HasSuffix(s, "G"):
+		mult = 1000 * 1000 * 1000
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive object count", s)
+	}
+	return n * mult, nil
+}
+
+// parseStaircase -- the comma separated -staircase levels, which must rise
+func parseStaircase(arg string) ([]int64, error) {
+	var levels []int64
+	for _, s := range strings.Split(arg, ",") {
+		n, err := parseCount(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if len(levels) > 0 && n <= levels[len(levels)-1] {
+			return nil, fmt.Errorf("the levels must rise, %s does not", s)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+// runStaircase -- the 'F' mode. Starting from empty buckets, a FILL PUT
+// test writes the objects up to each level regardless of -d, then the
+// -staircase-tests measure the buckets at that level. Rows are tagged with
+// the level as fill_level and the objects in the buckets.
+func runStaircase(loop int, first bool) []OutputStats {
+	rows := make([]OutputStats, 0)
+	saved_grow, saved_step, saved_duration := grow, grow_step, duration_secs
+	grow, grow_objects = true, 0
+	overlap_get = false
+	labels := strings.Split(staircase_arg, ",")
+	for n, level := range staircase_levels {
+		staircase_level = strings.TrimSpace(labels[n])
+		logInfof("Loop %d filling the buckets to %s objects", loop, staircase_level)
+		grow_step = level - grow_objects
+		duration_secs = -1
+		rows = append(rows, runPhase(loop, 'p', "Fp", first && n == 0)...)
+		duration_secs = saved_duration
+		if grow_objects < level {
+			logWarnf("Loop %d filled the buckets to only %d of %d objects", loop, grow_objects, level)
+		}
+		for _, r := range staircase_tests {
+			rows = append(rows, runPhase(loop, r, "F"+string(r), false)...)
+		}
+	}
+	// Later tests, ie a 'c' or 'd', cover every object written
+	object_count = grow_objects
+	grow, grow_step = saved_grow, saved_step
+	staircase_level = ""
+	return rows
+}
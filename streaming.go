@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var upload_mode, chunk_size_arg string
+var chunk_size int64
+
+const (
+	streamingPayload  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	chunkSignatureLen = 64
+	emptySHA256       = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// setUploadMode -- send a PUT body with the -upload-mode encoding. The SDK
+// sets Content-Length from the body in the sign phase, so that step is
+// replaced, and the body is swapped for an encoding one right before each
+// attempt is sent.
+func setUploadMode(req *request.Request) {
+	switch upload_mode {
+	case "chunked":
+		// Without a length the HTTP client falls back to Transfer-Encoding:
+		// chunked, as for a body of unknown length
+		req.Handlers.Sign.Swap(corehandlers.BuildContentLengthHandler.Name, request.NamedHandler{
+			Name: "hsbench.ChunkedContentLength",
+			Fn: func(r *request.Request) {
+				r.HTTPRequest.Header.Del("Content-Length")
+				r.HTTPRequest.ContentLength = -1
+			},
+		})
+		req.Handlers.Send.PushFront(func(r *request.Request) {
+			// The transport resends through GetBody when a pooled
+			// connection turns out closed
+			r.HTTPRequest.GetBody = func() (io.ReadCloser, error) {
+				r.Body.Seek(0, io.SeekStart)
+				return io.NopCloser(struct{ io.Reader }{r.Body}), nil
+			}
+			r.HTTPRequest.Body, _ = r.HTTPRequest.GetBody()
+			r.HTTPRequest.ContentLength = -1
+		})
+	case "aws-chunked":
+		req.Handlers.Sign.Swap(corehandlers.BuildContentLengthHandler.Name, request.NamedHandler{
+			Name: "hsbench.AwsChunkedContentLength",
+			Fn: func(r *request.Request) {
+				size, err := aws.SeekerLen(r.Body)
+				if err != nil {
+					r.Error = err
+					return
+				}
+				length := awsChunkedLength(size)
+				h := r.HTTPRequest.Header
+				h.Set("X-Amz-Content-Sha256", streamingPayload)
+				h.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(size, 10))
+				h.Set("Content-Encoding", "aws-chunked")
+				h.Set("Content-Length", strconv.FormatInt(length, 10))
+				r.HTTPRequest.ContentLength = length
+			},
+		})
+		req.Handlers.Send.PushFront(func(r *request.Request) {
+			r.HTTPRequest.GetBody = func() (io.ReadCloser, error) {
+				enc, err := newChunkSigner(r)
+				if err != nil {
+					return nil, err
+				}
+				return io.NopCloser(enc), nil
+			}
+			body, err := r.HTTPRequest.GetBody()
+			if err != nil {
+				r.Error = err
+				return
+			}
+			r.HTTPRequest.Body = body
+		})
+	}
+}
+
+// awsChunkedLength -- the encoded length of a body of size bytes
+func awsChunkedLength(size int64) int64 {
+	chunkLen := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))) + int64(len(";chunk-signature=")) + chunkSignatureLen + 2 + n + 2
+	}
+	length := (size / chunk_size) * chunkLen(chunk_size)
+	if rest := size % chunk_size; rest > 0 {
+		length += chunkLen(rest)
+	}
+	return length + chunkLen(0)
+}
+
+// chunkSigner -- the aws-chunked encoding of a body, each chunk signed with
+// the signature of the one before it, starting from the request signature
+type chunkSigner struct {
+	body    io.Reader
+	key     []byte
+	date    string
+	scope   string
+	prevSig string
+	chunk   []byte
+	out     []byte
+	done    bool
+}
+
+func newChunkSigner(r *request.Request) (*chunkSigner, error) {
+	auth := r.HTTPRequest.Header.Get("Authorization")
+	i := strings.LastIndex(auth, "Signature=")
+	if i < 0 {
+		return nil, fmt.Errorf("aws-chunked upload of an unsigned request")
+	}
+	creds, err := r.Config.Credentials.Get()
+	if err != nil {
+		return nil, err
+	}
+	region := r.ClientInfo.SigningRegion
+	if region == "" {
+		region = aws.StringValue(r.Config.Region)
+	}
+	date := r.HTTPRequest.Header.Get("X-Amz-Date")
+	if len(date) < 8 {
+		return nil, fmt.Errorf("aws-chunked upload without an X-Amz-Date")
+	}
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date[:8])
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	r.Body.Seek(0, io.SeekStart)
+	return &chunkSigner{
+		body:    r.Body,
+		key:     key,
+		date:    date,
+		scope:   fmt.Sprintf("%s/%s/s3/aws4_request", date[:8], region),
+		prevSig: auth[i+len("Signature="):],
+		chunk:   make([]byte, chunk_size),
+	}, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// next -- encode the next chunk, the empty final chunk once the body is read
+func (c *chunkSigner) next() error {
+	n, err := io.ReadFull(c.body, c.chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	data := c.chunk[:n]
+	sum := sha256.Sum256(data)
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256-PAYLOAD", c.date, c.scope, c.prevSig, emptySHA256, hex.EncodeToString(sum[:])}, "\n")
+	c.prevSig = hex.EncodeToString(hmacSHA256(c.key, toSign))
+	c.out = append(c.out[:0], fmt.Sprintf("%x;chunk-signature=%s\r\n", n, c.prevSig)...)
+	c.out = append(c.out, data...)
+	c.out = append(c.out, "\r\n"...)
+	c.done = n == 0
+	return nil
+}
+
+func (c *chunkSigner) Read(p []byte) (int, error) {
+	if len(c.out) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import "math/bits"
+
+var subset_arg string
+var subset float64
+
+// Large primes the subset permutation multiplies by, one of which does not
+// divide any population
+var subsetPrimes = []uint64{2654435761, 2246822519, 3266489917, 668265263, 374761393}
+
+// subsetSize -- how many of n objects the -subset covers, at least one
+func subsetSize(n int64) int64 {
+	if subset >= 1 || n <= 0 {
+		return n
+	}
+	return max(int64(float64(n)*subset), 1)
+}
+
+// subsetObject -- the i-th object of the -subset of n objects. The objects
+// are taken in a random order fixed by -seed, so the first subsetSize(n)
+// of them are a random sample with no object taken twice.
+func subsetObject(i int64, n int64) int64 {
+	if subset >= 1 || n <= 0 {
+		return i
+	}
+	un := uint64(n)
+	a := subsetPrimes[0]
+	for p, k := uint64(seed)%uint64(len(subsetPrimes)), 0; k < len(subsetPrimes); k++ {
+		a = subsetPrimes[(p+uint64(k))%uint64(len(subsetPrimes))]
+		if un%a != 0 {
+			break
+		}
+	}
+	hi, lo := bits.Mul64(a%un, uint64(i))
+	_, r := bits.Div64(hi, lo, un)
+	return int64((r + uint64(seed)%un) % un)
+}
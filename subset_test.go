@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// setSubset -- set -subset and -seed for a test, restoring them when it ends
+func setSubset(t *testing.T, fraction float64, s int64) {
+	oldSubset, oldSeed := subset, seed
+	t.Cleanup(func() {
+		subset, seed = oldSubset, oldSeed
+	})
+	subset, seed = fraction, s
+}
+
+func TestSubsetSize(t *testing.T) {
+	tests := []struct {
+		subset float64
+		n      int64
+		want   int64
+	}{
+		{1, 100, 100},
+		{0.5, 100, 50},
+		{0.25, 10, 2},
+		{0.01, 10, 1},
+		{0.5, 0, 0},
+		{0.5, -1, -1},
+	}
+	for _, tt := range tests {
+		setSubset(t, tt.subset, 0)
+		if got := subsetSize(tt.n); got != tt.want {
+			t.Errorf("subsetSize(%d) with -subset %g = %d, want %d", tt.n, tt.subset, got, tt.want)
+		}
+	}
+}
+
+func TestSubsetObjectPermutation(t *testing.T) {
+	tests := []struct {
+		name   string
+		primes []uint64
+		n      int64
+	}{
+		{"one", subsetPrimes, 1},
+		{"two", subsetPrimes, 2},
+		{"prime", subsetPrimes, 7},
+		{"hundred", subsetPrimes, 100},
+		{"power of two", subsetPrimes, 1024},
+		// The first multiplier divides n, so another one is picked
+		{"multiple of a prime", []uint64{3, 5, 7}, 9},
+		{"multiple of two primes", []uint64{3, 5, 7}, 15},
+	}
+	for _, tt := range tests {
+		for _, s := range []int64{0, 1, 2, 42, -7} {
+			t.Run(fmt.Sprintf("%s seed %d", tt.name, s), func(t *testing.T) {
+				setSubset(t, 0.5, s)
+				oldPrimes := subsetPrimes
+				t.Cleanup(func() { subsetPrimes = oldPrimes })
+				subsetPrimes = tt.primes
+				seen := make([]bool, tt.n)
+				for i := int64(0); i < tt.n; i++ {
+					o := subsetObject(i, tt.n)
+					if o < 0 || o >= tt.n {
+						t.Fatalf("subsetObject(%d, %d) = %d, out of range", i, tt.n, o)
+					}
+					if seen[o] {
+						t.Fatalf("subsetObject(%d, %d) = %d, taken twice", i, tt.n, o)
+					}
+					seen[o] = true
+				}
+			})
+		}
+	}
+}
+
+func TestSubsetObjectAll(t *testing.T) {
+	setSubset(t, 1, 42)
+	for i := int64(0); i < 10; i++ {
+		if got := subsetObject(i, 10); got != i {
+			t.Errorf("subsetObject(%d, 10) without -subset = %d", i, got)
+		}
+	}
+}
+
+func TestNextObjectSubsetWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		shard   bool
+		threads int
+		count   int64
+	}{
+		{"shared", false, 4, 10},
+		{"key shard", true, 2, 10},
+		{"key shard uneven", true, 3, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setShards(t, tt.shard, tt.threads, tt.count)
+			setSubset(t, 0.5, 42)
+			limit := subsetSize(tt.count)
+			if tt.shard {
+				limit = subsetSize(shardSize(0))
+			}
+			got := takeObjects(0, true, true, int(3*limit))
+			first := got[:limit]
+			seen := make(map[int64]bool)
+			for _, o := range first {
+				if seen[o] {
+					t.Fatalf("object %d taken twice in one pass: %v", o, got)
+				}
+				seen[o] = true
+				if o < 0 || o >= tt.count || (tt.shard && o%int64(tt.threads) != 0) {
+					t.Fatalf("object %d is not one of thread 0: %v", o, got)
+				}
+			}
+			for pass := int64(1); pass < 3; pass++ {
+				if again := got[pass*limit : (pass+1)*limit]; !reflect.DeepEqual(again, first) {
+					t.Errorf("pass %d took %v, want the sample %v again", pass, again, first)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Exit codes returned by hsbench
+const (
+	exitOK                 = 0
+	exitFatal              = 1 // unexpected runtime error, from log.Fatal
+	exitConfigError        = 2
+	exitPhaseFailure       = 3
+	exitThresholdViolation = 4
+)
+
+// PhaseSummary -- pass/fail result of one mode in one loop
+type PhaseSummary struct {
+	Loop    int
+	Mode    string
+	Passed  bool
+	Reasons []string `json:",omitempty"`
+	// Quiet time waited before the test with -phase-gap and -settle
+	GapSeconds float64 `json:",omitempty"`
+	Total      OutputStats
+	Slowest    []SlowOp        `json:",omitempty"`
+	Threads    *ThreadSpread   `json:",omitempty"`
+	Server     *ServerStats    `json:",omitempty"`
+	Failovers  []FailoverEvent `json:",omitempty"`
+	Runtime    *RuntimeStats
+}
+
+// RunSummary -- machine-readable result of the whole run
+type RunSummary struct {
+	Passed       bool
+	ExitCode     int
+	Seed         int64
+	Started      time.Time
+	Finished     time.Time
+	Capabilities *Capabilities `json:",omitempty"`
+	MinIO        *MinIOCapture `json:",omitempty"`
+	Phases       []PhaseSummary
+}
+
+var summary_output string
+var sla_max_lat99, sla_min_iops, sla_min_mbps float64
+var sla_modes_arg string
+var sla_modes map[string]bool
+
+// slaSkippedModes -- tests the -sla-* thresholds leave alone unless named in
+// -sla-modes: bucket setup and teardown, and the waits measured after
+// another test, whose rates say nothing of the object data path
+var slaSkippedModes = map[string]bool{
+	"BINIT": true, "BDEL": true, "BCLR": true, "BENC": true,
+	"BPPUT": true, "BPGET": true, "BPDEL": true,
+	"RESTORED": true, "REPLLAG": true, "DELGONE": true, "EXPIRE": true,
+}
+
+// slaApplies -- whether the -sla-* thresholds judge the test mode
+func slaApplies(mode string) bool {
+	if sla_modes != nil {
+		return sla_modes[mode]
+	}
+	return !slaSkippedModes[mode]
+}
+
+var phases []PhaseSummary
+var exit_code = exitOK
+
+// configFatal -- report an invalid configuration and exit
+func configFatal(v ...interface{}) {
+	logErrorf("%s", fmt.Sprint(v...))
+	os.Exit(exitConfigError)
+}
+
+// configFatalf -- report an invalid configuration and exit
+func configFatalf(format string, v ...interface{}) {
+	logErrorf(format, v...)
+	os.Exit(exitConfigError)
+}
+
+// abort -- record that a thread gave up before finishing its work
+func (stats *Stats) abort(thread_num int, reason string) {
+	atomic.AddInt32(&stats.aborted, 1)
+	logWarn("Thread aborting the test", stats.attrs(thread_num, "reason", reason)...)
+}
+
+// recordPhase -- judge a finished phase and remember the result for the summary
+func recordPhase(stats *Stats, os []OutputStats) {
+	p := PhaseSummary{Loop: stats.loop, Mode: stats.mode, Passed: true, GapSeconds: settled.Seconds()}
+	if len(os) > 0 && os[len(os)-1].IntervalName == "TOTAL" {
+		p.Total = os[len(os)-1]
+	} else {
+		p.Reasons = append(p.Reasons, "no total statistics")
+	}
+	failed := false
+	if p.Total.Corrupt > 0 {
+		p.Reasons = append(p.Reasons, fmt.Sprintf("%d reads failed -integrity verification", p.Total.Corrupt))
+		failed = true
+	}
+	if stats.mode == "RESTORED" {
+		if n := atomic.LoadInt64(&restore_given_up); n > 0 {
+			p.Reasons = append(p.Reasons, fmt.Sprintf("%d restores not finished after %.0fs", n, restore_timeout))
+			failed = true
+		}
+	}
+	if stats.mode == "REPLLAG" {
+		if n := atomic.LoadInt64(&replica_missing); n > 0 {
+			p.Reasons = append(p.Reasons, fmt.Sprintf("%d objects not on the replica after %s", n, replica_timeout))
+			failed = true
+		}
+	}
+	if stats.mode == "DELGONE" {
+		if n := atomic.LoadInt64(&delete_lingering); n > 0 {
+			p.Reasons = append(p.Reasons, fmt.Sprintf("%d deleted objects still visible after %s", n, delete_verify_timeout))
+			failed = true
+		}
+	}
+	if stats.mode == "LIST" && list_check {
+		if reason := listCheckFailure(); reason != "" {
+			p.Reasons = append(p.Reasons, reason)
+			failed = true
+		}
+	}
+	if aborted := atomic.LoadInt32(&stats.aborted); aborted > 0 {
+		p.Reasons = append(p.Reasons, fmt.Sprintf("%d threads aborted", aborted))
+		failed = true
+	}
+	violated := false
+	sla := slaApplies(stats.mode)
+	if sla && sla_max_lat99 > 0 && p.Total.Lat99 > sla_max_lat99 {
+		p.Reasons = append(p.Reasons, fmt.Sprintf("99%% latency %.2fms above %.2fms", p.Total.Lat99, sla_max_lat99))
+		violated = true
+	}
+	if sla && sla_min_iops > 0 && p.Total.Iops < sla_min_iops {
+		p.Reasons = append(p.Reasons, fmt.Sprintf("%.2f IO/s below %.2f", p.Total.Iops, sla_min_iops))
+		violated = true
+	}
+	if sla && sla_min_mbps > 0 && p.Total.Mbps < sla_min_mbps {
+		p.Reasons = append(p.Reasons, fmt.Sprintf("%.2f MB/s below %.2f", p.Total.Mbps, sla_min_mbps))
+		violated = true
+	}
+	if reasons := sloFailures(p.Total); len(reasons) > 0 {
+		p.Reasons = append(p.Reasons, reasons...)
+		violated = true
+	}
+	// Phase failures take precedence over threshold violations
+	if failed {
+		exit_code = exitPhaseFailure
+	} else if violated && exit_code == exitOK {
+		exit_code = exitThresholdViolation
+	}
+	p.Passed = !failed && !violated
+	if !p.Passed {
+		logWarnf("Loop %d %s test failed: %v", p.Loop, p.Mode, p.Reasons)
+	}
+	p.Server = stats.server
+	p.Slowest = takeSlowest()
+	for i, op := range p.Slowest {
+		logInfof("Loop %d %s slowest #%d: %s %s/%s at %s took %.1fms, status %d, request id %s",
+			p.Loop, p.Mode, i+1, op.Operation, op.Bucket, op.Key, op.Start.Format(time.RFC3339Nano), op.LatencyMs, op.Status, op.RequestID)
+	}
+	if p.Threads = stats.threadSpread(); p.Threads != nil {
+		t := p.Threads
+		ratio := "a thread did nothing"
+		if t.Ratio > 0 {
+			ratio = fmt.Sprintf("max/min %.2f", t.Ratio)
+		}
+		logInfof("Loop %d %s ops per thread: min %d (thread %d), median %d, max %d (thread %d), %s",
+			p.Loop, p.Mode, t.MinOps, t.SlowestThread, t.MedianOps, t.MaxOps, t.FastestThread, ratio)
+	}
+	if len(endpoints) > 1 {
+		p.Failovers = takeFailovers(stats, os)
+	}
+	p.Runtime = runtimeSince(p.Total.Ops)
+	logInfof("Loop %d %s runtime: GOMAXPROCS %d, %d allocs (%.1f/op), %.1f MB allocated, %d GCs pausing %.1fms, GC CPU %.2f%%",
+		p.Loop, p.Mode, p.Runtime.GOMAXPROCS, p.Runtime.Mallocs, p.Runtime.AllocsPerOp, p.Runtime.AllocMB,
+		p.Runtime.NumGC, p.Runtime.GCPauseMs, p.Runtime.GCCPUPercent)
+	phases = append(phases, p)
+}
+
+// writeSummary -- write the run summary JSON if requested
+func writeSummary() {
+	if summary_output == "" {
+		return
+	}
+	data, err := json.MarshalIndent(RunSummary{exit_code == exitOK, exit_code, seed,
+		run_started.UTC(), time.Now().UTC(), capabilities, minio_capture, phases}, "", "  ")
+	if err != nil {
+		logFatal("Error marshaling summary JSON: ", err)
+	}
+	if err := os.WriteFile(outputPath(summary_output), append(data, '\n'), 0644); err != nil {
+		logFatal("Error writing summary JSON file: ", err)
+	}
+}
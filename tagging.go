@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The object tagging calls, built in examples of -operation
+func init() {
+	registerOperation("get-tagging", OperationFunc(func(svc *s3.S3, bucket string, key string) (int64, error) {
+		_, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{Bucket: &bucket, Key: &key})
+		return 0, err
+	}))
+	registerOperation("put-tagging", OperationFunc(func(svc *s3.S3, bucket string, key string) (int64, error) {
+		_, err := svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+			Bucket:  &bucket,
+			Key:     &key,
+			Tagging: &s3.Tagging{TagSet: []*s3.Tag{{Key: &taggingKey, Value: &taggingValue}}},
+		})
+		return 0, err
+	}))
+}
+
+var taggingKey, taggingValue = "hsbench", "tagged"
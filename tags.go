@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var run_id string
+var tag_args stringListFlag
+
+// run_tags holds the -tag key=value pairs carried into every result row
+var run_tags map[string]string
+
+var tagKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedTags are the labels every metric row already has
+var reservedTags = map[string]bool{"loop": true, "interval": true, "thread": true, "mode": true, "bucket": true, "run_id": true}
+
+// parseTags -- the -tag arguments as a map, keys must be valid metric label
+// names so they can be passed on to Prometheus and InfluxDB as they are
+func parseTags(args []string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || !tagKeyRe.MatchString(key) {
+			return nil, fmt.Errorf("invalid tag %q, must be key=value with a key of letters, digits and underscores", arg)
+		}
+		if reservedTags[key] {
+			return nil, fmt.Errorf("tag key %q is reserved", key)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// tagKeys -- the tag keys in a stable order
+func tagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatTags -- the tags as a single k=v;k=v field
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for _, k := range tagKeys(tags) {
+		pairs = append(pairs, k+"="+tags[k])
+	}
+	return strings.Join(pairs, ";")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/binary"
 	"math/rand"
 	"sync"
 
@@ -20,15 +21,14 @@ func NewThreadSafeUUID(seed int64) *ThreadSafeUUID {
 	}
 }
 
-func (tsr *ThreadSafeUUID) generateUUIDv4() uuid.UUID {
+// keyUUID derives the random looking UUIDv4 of object objnum from seed, so
+// the name of any object can be worked out again by later tests and runs
+func keyUUID(seed, objnum int64) uuid.UUID {
 	var buf [16]byte
 
-	tsr.mu.Lock()
-	// Read random bytes into the buffer using the seeded random source
-	for i := 0; i < 16; i++ {
-		buf[i] = byte(tsr.rand.Intn(256))
-	}
-	tsr.mu.Unlock()
+	x := uint64(seed) ^ uint64(objnum)*0x9e3779b97f4a7c15
+	binary.BigEndian.PutUint64(buf[:8], splitmix64(&x))
+	binary.BigEndian.PutUint64(buf[8:], splitmix64(&x))
 
 	// Set the version (4) and variant bits
 	buf[6] = (buf[6] & 0x0f) | 0x40 // Version 4
@@ -37,3 +37,56 @@ func (tsr *ThreadSafeUUID) generateUUIDv4() uuid.UUID {
 	// Convert the buffer to a UUID
 	return uuid.UUID(buf)
 }
+
+// splitmix64 advances the state x and returns the next well mixed value
+func splitmix64(x *uint64) uint64 {
+	*x += 0x9e3779b97f4a7c15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// intn returns a random number in [0, n) from the seeded random source
+func (tsr *ThreadSafeUUID) intn(n int) int {
+	tsr.mu.Lock()
+	defer tsr.mu.Unlock()
+	return tsr.rand.Intn(n)
+}
+
+// float64 returns a random number in [0.0, 1.0) from the seeded random source
+func (tsr *ThreadSafeUUID) float64() float64 {
+	tsr.mu.Lock()
+	defer tsr.mu.Unlock()
+	return tsr.rand.Float64()
+}
+
+// expFloat64 returns an exponentially distributed number with a mean of 1
+// from the seeded random source
+func (tsr *ThreadSafeUUID) expFloat64() float64 {
+	tsr.mu.Lock()
+	defer tsr.mu.Unlock()
+	return tsr.rand.ExpFloat64()
+}
+
+// read fills buf with random bytes from the seeded random source
+func (tsr *ThreadSafeUUID) read(buf []byte) {
+	tsr.mu.Lock()
+	defer tsr.mu.Unlock()
+	tsr.rand.Read(buf)
+}
+
+// shuffle randomizes the order of n elements with the seeded random source
+func (tsr *ThreadSafeUUID) shuffle(n int, swap func(i, j int)) {
+	tsr.mu.Lock()
+	defer tsr.mu.Unlock()
+	tsr.rand.Shuffle(n, swap)
+}
+
+// zipf returns a number in [0, n) drawn from a Zipf distribution with
+// exponent s, 0 being the most likely, from the seeded random source
+func (tsr *ThreadSafeUUID) zipf(s float64, n int64) int64 {
+	tsr.mu.Lock()
+	defer tsr.mu.Unlock()
+	return int64(rand.NewZipf(tsr.rand, s, 1, uint64(n-1)).Uint64())
+}
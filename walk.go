@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var walk_delimiter string
+
+type walkDir struct {
+	bucket_num int64
+	prefix     string
+}
+
+// walkQueue -- directories still to be listed, shared by the walk threads
+type walkQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	dirs []walkDir
+	// threads currently listing a directory, which may find more
+	busy int
+	// set when the walk was cut short
+	stopped bool
+}
+
+func newWalkQueue() *walkQueue {
+	q := &walkQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	for b := int64(0); b < bucket_count; b++ {
+		q.dirs = append(q.dirs, walkDir{b, object_prefix})
+	}
+	return q
+}
+
+// pop -- take the next directory, waiting while other threads may still find
+// some. Returns false once the walk is complete.
+func (q *walkQueue) pop() (walkDir, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.dirs) == 0 && q.busy > 0 && !q.stopped {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 || q.stopped {
+		return walkDir{}, false
+	}
+	d := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	q.busy++
+	return d, true
+}
+
+// done -- finish listing a directory, queueing the subdirectories it held
+func (q *walkQueue) done(found []walkDir) {
+	q.mu.Lock()
+	if !q.stopped {
+		q.dirs = append(q.dirs, found...)
+	}
+	q.busy--
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// stop -- give up on the rest of the walk
+func (q *walkQueue) stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.dirs = nil
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// runWalk -- recursively list the buckets a directory at a time, like
+// "s3 ls --recursive" over a delimited keyspace
+func runWalk(thread_num int, queue *walkQueue, stats *Stats) {
+	svc := newWorkerClient(thread_num)
+	for {
+		if phaseOver() {
+			queue.stop()
+			break
+		}
+		dir, ok := queue.pop()
+		if !ok {
+			break
+		}
+
+		found := make([]walkDir, 0)
+		start := time.Now().UnixNano()
+		err := svc.ListObjectsV2Pages(
+			&s3.ListObjectsV2Input{
+				Bucket:    &buckets[dir.bucket_num],
+				Prefix:    &dir.prefix,
+				Delimiter: &walk_delimiter,
+				MaxKeys:   &max_keys,
+			},
+			func(p *s3.ListObjectsV2Output, last bool) bool {
+				end := time.Now().UnixNano()
+				for _, cp := range p.CommonPrefixes {
+					found = append(found, walkDir{dir.bucket_num, *cp.Prefix})
+				}
+				stats.addWalked(thread_num, int64(len(p.CommonPrefixes)), int64(len(p.Contents)))
+				stats.addOp(thread_num, 0, end-start)
+				start = time.Now().UnixNano()
+				return true
+			})
+		queue.done(found)
+
+		if err != nil {
+			stats.abort(thread_num, fmt.Sprintf("unable to list %s/%s: %v", buckets[dir.bucket_num], dir.prefix, err))
+			queue.stop()
+			break
+		}
+	}
+	stats.finish(thread_num)
+	atomic.AddInt64(&running_threads, -1)
+}
+
+// addWalked -- count the directories and keys found by a walk listing
+func (stats *Stats) addWalked(thread_num int, dirs int64, keys int64) {
+	is := stats.current(thread_num)
+	is.walkedDirs += dirs
+	is.walkedKeys += keys
+	stats.threadStats[thread_num].mu.Unlock()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var workers_file string
+
+// workerGroup -- a group of test threads with their own endpoint,
+// credentials, buckets and rate, as read from -workers
+type workerGroup struct {
+	name       string
+	threads    int
+	endpoint   string
+	access_key string
+	secret_key string
+	// indexes into buckets the group's objects are spread over, all if empty
+	buckets []int64
+	// offered rate of the group in operations per second, 0 for flat out
+	rate float64
+	// share of reads in the 'M' test, -1 for -read-pct
+	read_pct float64
+
+	mu       sync.Mutex
+	nextNano int64
+}
+
+var worker_groups []*workerGroup
+
+// thread_groups -- the group of each thread, nil without -workers
+var thread_groups []*workerGroup
+
+// readWorkers -- parse a -workers file of "<name> <key>=<value> ..." lines,
+// one per group, skipping blank lines and # comments. The keys are threads,
+// endpoint, access-key and secret-key (taking file:<path> like -a and -s),
+// buckets (a comma separated list of bucket numbers), rate and read-pct.
+func readWorkers(name string) ([]*workerGroup, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups := make([]*workerGroup, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		g := &workerGroup{name: fields[0], read_pct: -1}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected <key>=<value>, got %q", line, field)
+			}
+			if err := g.set(key, value); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		if g.threads < 1 {
+			return nil, fmt.Errorf("line %d: group %s needs threads=<count>", line, g.name)
+		}
+		if (g.access_key == "") != (g.secret_key == "") {
+			return nil, fmt.Errorf("line %d: group %s needs both access-key and secret-key", line, g.name)
+		}
+		groups = append(groups, g)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no worker groups in %s", name)
+	}
+	return groups, nil
+}
+
+func (g *workerGroup) set(key string, value string) error {
+	var err error
+	switch key {
+	case "threads":
+		g.threads, err = strconv.Atoi(value)
+	case "endpoint":
+		g.endpoint = value
+	case "access-key":
+		g.access_key, err = readSecret(value)
+	case "secret-key":
+		g.secret_key, err = readSecret(value)
+	case "buckets":
+		for _, b := range strings.Split(value, ",") {
+			n, perr := strconv.ParseInt(b, 10, 64)
+			if perr != nil || n < 0 || n >= bucket_count {
+				return fmt.Errorf("bucket %q is not a number below -b %d", b, bucket_count)
+			}
+			g.buckets = append(g.buckets, n)
+		}
+	case "rate":
+		if g.rate, err = strconv.ParseFloat(value, 64); err == nil && g.rate < 0 {
+			err = fmt.Errorf("can not be negative")
+		}
+	case "read-pct":
+		if g.read_pct, err = strconv.ParseFloat(value, 64); err == nil && (g.read_pct < 0 || g.read_pct > 100) {
+			err = fmt.Errorf("must be between 0 and 100")
+		}
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %v", key, value, err)
+	}
+	return nil
+}
+
+// setupWorkers -- deal the threads out to the -workers groups in file
+// order, the groups setting the number of threads
+func setupWorkers(groups []*workerGroup) {
+	worker_groups = groups
+	thread_groups = nil
+	for _, g := range groups {
+		for i := 0; i < g.threads; i++ {
+			thread_groups = append(thread_groups, g)
+		}
+	}
+	threads = len(thread_groups)
+}
+
+// workerGroupOf -- the group of thread_num, nil without -workers
+func workerGroupOf(thread_num int) *workerGroup {
+	if thread_groups == nil {
+		return nil
+	}
+	return thread_groups[thread_num]
+}
+
+// newWorkerClient -- the S3 client of test thread thread_num, talking to
+// its group's endpoint with its group's credentials
+func newWorkerClient(thread_num int) *s3.S3 {
+	g := workerGroupOf(thread_num)
+	if g == nil || g.endpoint == "" && g.access_key == "" {
+		return newS3Client()
+	}
+	c := cfg.Copy()
+	if g.endpoint != "" {
+		c.Endpoint = aws.String(g.endpoint)
+	}
+	if g.access_key != "" {
+		c.Credentials = credentials.NewStaticCredentials(g.access_key, g.secret_key, "")
+	}
+	return newS3ClientWith(c, g.endpoint == "" && len(endpoints) > 1)
+}
+
+// workerBucket -- the bucket number of object objnum for thread_num, taken
+// from its group's buckets when the group has some
+func workerBucket(thread_num int, objnum int64) int64 {
+	if g := workerGroupOf(thread_num); g != nil && len(g.buckets) > 0 {
+		return g.buckets[objnum%int64(len(g.buckets))]
+	}
+	return objnum % bucket_count
+}
+
+// workerReadPct -- the share of reads of thread_num in the 'M' test
+func workerReadPct(thread_num int) float64 {
+	if g := workerGroupOf(thread_num); g != nil && g.read_pct >= 0 {
+		return g.read_pct
+	}
+	return read_pct
+}
+
+// resetWorkers -- start the group rates afresh for a new test
+func resetWorkers() {
+	for _, g := range worker_groups {
+		g.mu.Lock()
+		g.nextNano = 0
+		g.mu.Unlock()
+	}
+}
+
+// paceWorker -- wait for the next arrival of thread_num's group when the
+// group has a rate, spacing the group's operations by the -arrival
+// distribution
+func paceWorker(thread_num int) {
+	g := workerGroupOf(thread_num)
+	if g == nil || g.rate <= 0 {
+		return
+	}
+	g.mu.Lock()
+	now := time.Now().UnixNano()
+	if g.nextNano < now {
+		g.nextNano = now
+	}
+	at := g.nextNano
+	g.nextNano += interArrival(g.rate)
+	g.mu.Unlock()
+	sleepUntil(at)
+}